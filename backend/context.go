@@ -6,7 +6,8 @@ import (
 )
 
 const (
-	CTX_KEY_CONVERTER = "CONVERTER"
+	CTX_KEY_METADATA = "METADATA"
+	CTX_KEY_SESSION  = "SESSION"
 )
 
 type IContext interface {
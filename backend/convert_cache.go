@@ -0,0 +1,350 @@
+package backend
+
+import (
+	"container/list"
+	"fmt"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+
+	"sqlproxy/sqlparser"
+	"sqlproxy/sqlparser/dependency/querypb"
+)
+
+const DefaultConvertCacheSize = 1024
+
+// convertResult 缓存的转换结果：转换后的SQL，以及转换后的参数对应原参数的下标，
+// params不为空时参数取自params（语句中的字面量），否则取自执行时的绑定参数
+type convertResult struct {
+	sql    string
+	argIdx []int
+	params []interface{}
+	rules  []string // 匹配的自定义规则
+	err    error
+}
+
+// convertInfo 转换过程的信息，用于转换日志
+type convertInfo struct {
+	rules  []string
+	cached bool // 是否命中了缓存
+}
+
+func (r *convertResult) bind(args []interface{}) []interface{} {
+	src := args
+	if r.params != nil {
+		src = r.params
+	}
+	if len(r.argIdx) == 0 {
+		return nil
+	}
+	newArgs := make([]interface{}, len(r.argIdx))
+	for i, idx := range r.argIdx {
+		newArgs[i] = src[idx]
+	}
+	return newArgs
+}
+
+type cacheEntry struct {
+	key    string
+	result *convertResult
+}
+
+// ConvertCacheStats 转换缓存的统计信息
+type ConvertCacheStats struct {
+	Size     int   `json:"size"`
+	Capacity int   `json:"capacity"`
+	Hits     int64 `json:"hits"`
+	Misses   int64 `json:"misses"`
+}
+
+// ConvertCache 按原始SQL和归一化后的SQL缓存转换结果的LRU，表结构或转换规则重新加载后清空
+type ConvertCache struct {
+	capacity int
+	hits     int64
+	misses   int64
+
+	mu      sync.Mutex
+	gen     int64 // 每次清空加1，清空前开始的转换不再写入
+	entries map[string]*list.Element
+	lru     *list.List
+}
+
+// NewConvertCache size为0时使用默认大小，小于0时返回nil表示不缓存
+func NewConvertCache(size int) *ConvertCache {
+	if size < 0 {
+		return nil
+	}
+	if size == 0 {
+		size = DefaultConvertCacheSize
+	}
+	return &ConvertCache{
+		capacity: size,
+		entries:  make(map[string]*list.Element),
+		lru:      list.New(),
+	}
+}
+
+func (c *ConvertCache) get(key string) (*convertResult, bool) {
+	c.mu.Lock()
+	elem, ok := c.entries[key]
+	if ok {
+		c.lru.MoveToFront(elem)
+	}
+	c.mu.Unlock()
+	if !ok {
+		atomic.AddInt64(&c.misses, 1)
+		return nil, false
+	}
+	atomic.AddInt64(&c.hits, 1)
+	return elem.Value.(*cacheEntry).result, true
+}
+
+func (c *ConvertCache) put(gen int64, key string, result *convertResult) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if gen != c.gen {
+		return
+	}
+	if elem, ok := c.entries[key]; ok {
+		elem.Value.(*cacheEntry).result = result
+		c.lru.MoveToFront(elem)
+		return
+	}
+	c.entries[key] = c.lru.PushFront(&cacheEntry{key: key, result: result})
+	for c.lru.Len() > c.capacity {
+		oldest := c.lru.Back()
+		c.lru.Remove(oldest)
+		delete(c.entries, oldest.Value.(*cacheEntry).key)
+	}
+}
+
+func (c *ConvertCache) generation() int64 {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.gen
+}
+
+// Purge 清空缓存，统计信息保留
+func (c *ConvertCache) Purge() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.gen++
+	c.entries = make(map[string]*list.Element)
+	c.lru.Init()
+}
+
+func (c *ConvertCache) Stats() ConvertCacheStats {
+	c.mu.Lock()
+	size := c.lru.Len()
+	c.mu.Unlock()
+	return ConvertCacheStats{
+		Size:     size,
+		Capacity: c.capacity,
+		Hits:     atomic.LoadInt64(&c.hits),
+		Misses:   atomic.LoadInt64(&c.misses),
+	}
+}
+
+// Convert 先按原始SQL查找缓存；没有绑定参数的DML再按sqlparser.Normalize归一化后的SQL查找，
+// 字面量相同位置不同值的语句共用一个转换结果，字面量作为绑定参数传给目标库。
+// 未命中时才通过load获取当前的转换器及是否可以归一化
+func (c *ConvertCache) Convert(load func() (sqlparser.SQLConverter, bool), query string, args ...interface{}) (string, []interface{}, error) {
+	sql, newArgs, _, err := c.convert(load, query, args...)
+	return sql, newArgs, err
+}
+
+func (c *ConvertCache) convert(load func() (sqlparser.SQLConverter, bool), query string, args ...interface{}) (string, []interface{}, convertInfo, error) {
+	if result, ok := c.get(query); ok {
+		return result.sql, result.bind(args), convertInfo{rules: result.rules, cached: true}, result.err
+	}
+	gen := c.generation()
+	converter, normalize := load()
+
+	if normalize && len(args) == 0 {
+		if normalized, params, ok := normalizeQuery(query); ok {
+			key := "normalized:" + normalized
+			result, cached := c.get(key)
+			if !cached {
+				result = convertWithArgIndex(converter, normalized, len(params))
+				if result != nil && result.err == nil {
+					c.put(gen, key, result)
+				}
+			}
+			if result != nil && result.err == nil {
+				raw := &convertResult{sql: result.sql, argIdx: result.argIdx, params: params, rules: result.rules}
+				c.put(gen, query, raw)
+				return raw.sql, raw.bind(nil), convertInfo{rules: raw.rules, cached: cached}, nil
+			}
+		}
+	}
+
+	result := convertWithArgIndex(converter, query, len(args))
+	if result == nil {
+		// 转换结果与参数的值有关，不能缓存
+		sql, newArgs, rules, err := convertWithRules(converter, query, args...)
+		return sql, newArgs, convertInfo{rules: rules}, err
+	}
+	c.put(gen, query, result)
+	return result.sql, result.bind(args), convertInfo{rules: result.rules}, result.err
+}
+
+// argIndex 转换时代替实际参数的占位值，用于得到转换后的参数与原参数的对应关系
+type argIndex int
+
+// convertWithArgIndex 用占位值代替参数转换，转换后的参数中有占位值以外的值时返回nil
+func convertWithArgIndex(converter sqlparser.SQLConverter, query string, n int) *convertResult {
+	placeholders := make([]interface{}, n)
+	for i := range placeholders {
+		placeholders[i] = argIndex(i)
+	}
+	sql, newArgs, rules, err := convertWithRules(converter, query, placeholders...)
+	if err != nil {
+		return &convertResult{rules: rules, err: err}
+	}
+	result := &convertResult{sql: sql, argIdx: make([]int, len(newArgs)), rules: rules}
+	for i, arg := range newArgs {
+		idx, ok := arg.(argIndex)
+		if !ok {
+			return nil
+		}
+		result.argIdx[i] = int(idx)
+	}
+	return result
+}
+
+// convertWithRules 转换SQL，转换器是RuleConverter时同时返回匹配的规则名称
+func convertWithRules(converter sqlparser.SQLConverter, query string, args ...interface{}) (string, []interface{}, []string, error) {
+	if c, ok := converter.(*RuleConverter); ok {
+		return c.ConvertWithRules(query, args...)
+	}
+	sql, newArgs, err := converter.Convert(query, args...)
+	return sql, newArgs, nil, err
+}
+
+// normalizeQuery 将语句中的字面量替换为绑定参数:v1、:v2...，返回归一化后的SQL和字面量的值
+func normalizeQuery(query string) (string, []interface{}, bool) {
+	stmt, err := sqlparser.Parse(query)
+	if err != nil {
+		return "", nil, false
+	}
+	switch stmt.(type) {
+	case *sqlparser.Select, *sqlparser.Union, *sqlparser.Insert, *sqlparser.Update, *sqlparser.Delete:
+	default:
+		return "", nil, false
+	}
+	if literalSensitive(stmt) {
+		return "", nil, false
+	}
+
+	bindVars := make(map[string]*querypb.BindVariable)
+	sqlparser.Normalize(stmt, bindVars, "v")
+	if len(bindVars) == 0 {
+		return "", nil, false
+	}
+	// in (1, 2, 3)归一化为列表参数::vN，转换器不支持，展开为单个的参数
+	_ = sqlparser.Walk(func(node sqlparser.SQLNode) (bool, error) {
+		if cmp, ok := node.(*sqlparser.ComparisonExpr); ok {
+			if list, ok := cmp.Right.(sqlparser.ListArg); ok {
+				name := string(list[2:])
+				tuple := make(sqlparser.ValTuple, 0, len(bindVars[name].Values))
+				for i, v := range bindVars[name].Values {
+					item := fmt.Sprintf("%s_%d", name, i)
+					bindVars[item] = &querypb.BindVariable{Type: v.Type, Value: v.Value}
+					tuple = append(tuple, sqlparser.NewValArg([]byte(":"+item)))
+				}
+				cmp.Right = tuple
+			}
+		}
+		return true, nil
+	}, stmt)
+
+	// 按出现顺序重新编号，参数与编号一一对应
+	params := []interface{}{}
+	names := make(map[string]string)
+	valid := true
+	_ = sqlparser.Walk(func(node sqlparser.SQLNode) (bool, error) {
+		val, ok := node.(*sqlparser.SQLVal)
+		if !ok || val.Type != sqlparser.ValArg {
+			return true, nil
+		}
+		name := string(val.Val[1:])
+		newName, ok := names[name]
+		if !ok {
+			bv, ok := bindVars[name]
+			if !ok {
+				// 原语句中的参数，例如文本协议中的?
+				valid = false
+				return false, nil
+			}
+			v, err := bindVarValue(bv)
+			if err != nil {
+				valid = false
+				return false, nil
+			}
+			params = append(params, v)
+			newName = ":v" + strconv.Itoa(len(params))
+			names[name] = newName
+		}
+		val.Val = []byte(newName)
+		return true, nil
+	}, stmt)
+	if !valid {
+		return "", nil, false
+	}
+	return sqlparser.String(stmt), params, true
+}
+
+func bindVarValue(bv *querypb.BindVariable) (interface{}, error) {
+	switch bv.Type {
+	case querypb.Type_INT64:
+		return strconv.ParseInt(string(bv.Value), 10, 64)
+	case querypb.Type_FLOAT64:
+		return strconv.ParseFloat(string(bv.Value), 64)
+	case querypb.Type_VARBINARY:
+		return string(bv.Value), nil
+	default:
+		return nil, fmt.Errorf("unsupported bind variable type: %v", bv.Type)
+	}
+}
+
+// literalSensitive 函数参数中的字面量（日期格式、分隔符、interval等）会影响转换结果，
+// order by、group by中的整数是列的序号，select的列没有别名时列名与字面量有关，
+// 零值日期需要转换器按字面量处理，like的模式串中有转义时需要加escape，这些语句不能归一化
+func literalSensitive(stmt sqlparser.Statement) bool {
+	found := false
+	_ = sqlparser.Walk(func(node sqlparser.SQLNode) (bool, error) {
+		switch n := node.(type) {
+		case *sqlparser.FuncExpr, *sqlparser.GroupConcatExpr, *sqlparser.IntervalExpr, *sqlparser.SubstrExpr,
+			*sqlparser.ConvertExpr, *sqlparser.ConvertUsingExpr, *sqlparser.MatchExpr, *sqlparser.Order, sqlparser.GroupBy:
+			found = found || hasLiteral(node)
+			return false, nil
+		case *sqlparser.ComparisonExpr:
+			if (n.Operator == sqlparser.LikeStr || n.Operator == sqlparser.NotLikeStr) && hasLiteral(n.Right) {
+				found = true
+			}
+		case *sqlparser.AliasedExpr:
+			// 没有别名时目标库按表达式的文本生成列名
+			if n.As.IsEmpty() && hasLiteral(n.Expr) {
+				found = true
+			}
+		case *sqlparser.SQLVal:
+			if n.Type == sqlparser.StrVal && strings.HasPrefix(string(n.Val), "0000-00-00") {
+				found = true
+			}
+		}
+		return !found, nil
+	}, stmt)
+	return found
+}
+
+func hasLiteral(node sqlparser.SQLNode) bool {
+	found := false
+	_ = sqlparser.Walk(func(node sqlparser.SQLNode) (bool, error) {
+		if v, ok := node.(*sqlparser.SQLVal); ok && v.Type != sqlparser.ValArg {
+			found = true
+		}
+		return !found, nil
+	}, node)
+	return found
+}
@@ -0,0 +1,119 @@
+package backend
+
+import (
+	"sync/atomic"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"sqlproxy/config"
+	"sqlproxy/sqlparser"
+)
+
+func TestConvertCacheLRU(t *testing.T) {
+	cache := NewConvertCache(2)
+	cache.put(0, "a", &convertResult{sql: "a"})
+	cache.put(0, "b", &convertResult{sql: "b"})
+	_, ok := cache.get("a")
+	assert.True(t, ok)
+	cache.put(0, "c", &convertResult{sql: "c"})
+	_, ok = cache.get("b")
+	assert.False(t, ok)
+	_, ok = cache.get("a")
+	assert.True(t, ok)
+	assert.Equal(t, ConvertCacheStats{Size: 2, Capacity: 2, Hits: 2, Misses: 1}, cache.Stats())
+
+	gen := cache.generation()
+	cache.Purge()
+	cache.put(gen, "d", &convertResult{sql: "d"})
+	assert.Equal(t, 0, cache.Stats().Size)
+
+	assert.Nil(t, NewConvertCache(-1))
+	assert.Equal(t, DefaultConvertCacheSize, NewConvertCache(0).capacity)
+}
+
+func TestConvertCacheConvert(t *testing.T) {
+	cache := NewConvertCache(100)
+	converter := sqlparser.NewOracleConverter(nil, nil, map[string]map[string]int{"t1": {"id": 0}})
+	load := func() (sqlparser.SQLConverter, bool) { return converter, true }
+
+	testCases := []struct {
+		in, out       string
+		args, outArgs []interface{}
+	}{
+		{
+			in:      "insert into t1 (id, a, b) values (?, ?, ?)",
+			out:     `insert into "t1"("a", "b") values (:v1, :v2)`,
+			args:    []interface{}{0, "x", 3},
+			outArgs: []interface{}{"x", 3},
+		},
+		{
+			in:      "insert into t1 (id, a, b) values (?, ?, ?)",
+			out:     `insert into "t1"("a", "b") values (:v1, :v2)`,
+			args:    []interface{}{0, "y", 4},
+			outArgs: []interface{}{"y", 4},
+		},
+		{
+			in:      "select a from t1 where b = 1 and c in ('x', 'y')",
+			out:     `select "a" from "t1" where "b" = :v1 and "c" in (:v2, :v3)`,
+			outArgs: []interface{}{int64(1), "x", "y"},
+		},
+		{
+			in:      "select a from t1 where b = 2 and c in ('z', 'w')",
+			out:     `select "a" from "t1" where "b" = :v1 and "c" in (:v2, :v3)`,
+			outArgs: []interface{}{int64(2), "z", "w"},
+		},
+		{
+			in:  "select a from t1 where b = 3 order by 1",
+			out: `select "a" from "t1" where "b" = 3 order by 1 asc`,
+		},
+		{
+			in:  "select 1, a from t1 where b = 3",
+			out: `select 1, "a" from "t1" where "b" = 3`,
+		},
+		{
+			in:  "select date_format(d, '%Y-%m-%d') from t1 where b = 3",
+			out: `select to_char("d", 'yyyy-mm-dd') from "t1" where "b" = 3`,
+		},
+		{
+			in:  `select a from t1 where b = 3 and c like 'x\_y%'`,
+			out: `select "a" from "t1" where "b" = 3 and "c" like 'x\_y%' escape '\'`,
+		},
+	}
+	for _, tcase := range testCases {
+		sql, args, err := cache.Convert(load, tcase.in, tcase.args...)
+		assert.Nil(t, err)
+		assert.Equal(t, tcase.out, sql)
+		assert.Equal(t, tcase.outArgs, args)
+	}
+	// 第二条命中原始SQL，第四条命中归一化后的SQL
+	assert.Equal(t, int64(2), cache.Stats().Hits)
+
+	_, _, err := cache.Convert(load, "update t1 set a = 1 where id = 1")
+	assert.Nil(t, err)
+	_, ok := cache.get("update t1 set a = 1 where id = 1")
+	assert.True(t, ok)
+}
+
+func TestConvertCacheNormalizeOption(t *testing.T) {
+	for _, enabled := range []bool{false, true} {
+		c := &MetadataCache{cfg: config.NodeConfig{ConvertCacheNormalize: enabled}, converterName: sqlparser.MYSQL_TO_ORACLE}
+		assert.Nil(t, c.publish(&tableMetadata{}))
+		assert.Equal(t, enabled, atomic.LoadInt32(&c.normalize) == 1)
+	}
+}
+
+func TestConvertCacheInvalidate(t *testing.T) {
+	query := "select cal_name from webcal_entry where cal_id = 1"
+	_, err := testdb.Query(query)
+	assert.Nil(t, err)
+	_, err = testdb.Query(query)
+	assert.Nil(t, err)
+	stats := testdb.ConvertCacheStats()
+	assert.NotNil(t, stats)
+	assert.True(t, stats.Hits > 0)
+	assert.True(t, stats.Size > 0)
+
+	assert.Nil(t, testdb.ReloadMetadata())
+	assert.Equal(t, 0, testdb.ConvertCacheStats().Size)
+}
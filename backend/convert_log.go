@@ -0,0 +1,47 @@
+package backend
+
+import (
+	"encoding/json"
+	"time"
+
+	"sqlproxy/core/golog"
+)
+
+// Session 发起SQL的客户端连接信息，通过上下文传给转换插件，用于转换日志
+type Session struct {
+	ConnId uint32
+	User   string
+}
+
+// ConvertRecord 转换日志的一条记录，每条一行JSON
+type ConvertRecord struct {
+	Time      string   `json:"time"`
+	ConnId    uint32   `json:"conn_id"`
+	User      string   `json:"user"`
+	Node      string   `json:"node"`
+	Method    string   `json:"method"`
+	SQL       string   `json:"sql"`
+	Converted string   `json:"converted"`
+	Args      int      `json:"args"`
+	NewArgs   int      `json:"new_args"`
+	Duration  int64    `json:"duration_us"` // 转换耗时，单位微秒
+	Rules     []string `json:"rules,omitempty"`
+	Rewritten bool     `json:"rewritten,omitempty"` // 按指纹手工指定了改写的SQL
+	Cached    bool     `json:"cached,omitempty"`
+	Error     string   `json:"error,omitempty"`
+	Fallback  bool     `json:"fallback,omitempty"` // 转换失败，使用原始SQL执行
+}
+
+// writeConvertLog 没有开启转换日志时不做任何事情
+func writeConvertLog(record *ConvertRecord) {
+	if golog.GlobalConvertLogger == nil {
+		return
+	}
+	record.Time = time.Now().Format(golog.TimeFormat)
+	data, err := json.Marshal(record)
+	if err != nil {
+		golog.Error("convertSQLPlugin", "writeConvertLog", err.Error(), record.ConnId)
+		return
+	}
+	golog.OutputConvert(data)
+}
@@ -0,0 +1,66 @@
+package backend
+
+import (
+	"bytes"
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"sqlproxy/config"
+	"sqlproxy/core/golog"
+	"sqlproxy/mysql"
+)
+
+func TestConvertLog(t *testing.T) {
+	var buf bytes.Buffer
+	handler, _ := golog.NewStreamHandler(&buf)
+	golog.GlobalConvertLogger = golog.New(handler, 0)
+
+	db, err := testdb.WithSession(&Session{ConnId: 10001, User: "root"})
+	assert.Nil(t, err)
+	rs, err := db.Query("select cal_name from webcal_entry where cal_id = ?", 1)
+	assert.Nil(t, err)
+	assert.Equal(t, 1, rs.Resultset.RowNumber())
+	// 解析失败时使用原始SQL执行
+	_, err = db.Query("select cal_name from webcal_entry where")
+	assert.NotNil(t, err)
+
+	logger := golog.GlobalConvertLogger
+	golog.GlobalConvertLogger = nil
+	logger.Close()
+
+	lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+	assert.Len(t, lines, 2)
+	var record ConvertRecord
+	assert.Nil(t, json.Unmarshal([]byte(lines[0]), &record))
+	assert.Equal(t, uint32(10001), record.ConnId)
+	assert.Equal(t, "root", record.User)
+	assert.Equal(t, "uc_uniform", record.Node)
+	assert.Equal(t, "select `cal_name` from `webcal_entry` where `cal_id` = ?1", record.Converted)
+	assert.Equal(t, 1, record.Args)
+	assert.Equal(t, 1, record.NewArgs)
+	assert.False(t, record.Fallback)
+
+	record = ConvertRecord{}
+	assert.Nil(t, json.Unmarshal([]byte(lines[1]), &record))
+	assert.True(t, record.Fallback)
+	assert.NotEmpty(t, record.Error)
+	assert.Equal(t, record.SQL, record.Converted)
+}
+
+func TestConvertStrict(t *testing.T) {
+	db := NewBackendProxy(config.NodeConfig{
+		Name:          "strict",
+		DriverName:    "sqlite",
+		Datasource:    "file:strict?mode=memory&cache=shared",
+		MaxOpenConns:  1,
+		ConvertStrict: true,
+	})
+	assert.Nil(t, db.InitConnectionPool())
+	_, err := db.Query("select 1 from where")
+	sqlErr, ok := err.(*mysql.SqlError)
+	assert.True(t, ok)
+	assert.Equal(t, uint16(mysql.ER_NOT_SUPPORTED_YET), sqlErr.Code)
+}
@@ -17,171 +17,283 @@ package backend
 import (
 	"context"
 	"database/sql"
+	"errors"
 	"fmt"
+	"sqlproxy/config"
+	perrors "sqlproxy/core/errors"
 	"sqlproxy/core/golog"
+	"sqlproxy/mysql"
 	"sqlproxy/sqlparser"
+	"strings"
+	"time"
 )
 
 type convertSQLPlugin struct {
 	Context
-	db        dbQuerierWithCtx
-	converter sqlparser.SQLConverter
+	db       dbQuerierWithCtx
+	metadata *MetadataCache
 }
 
 var _ SQLPlugin = new(convertSQLPlugin)
 
 func (d *convertSQLPlugin) Prepare(query string) (*sql.Stmt, error) {
-	convertSQL, _, err := d.converter.Convert(query)
+	// 预处理时还没有参数，用bindArg代替，执行时参数原样传给目标库，转换后的参数必须与原参数一一对应
+	args := prepareArgs(query)
+	convertSQL, newArgs, err := d.convert("Prepare", query, args...)
 	if err != nil {
-		golog.Warn("convertSQLPlugin", "Prepare", err.Error(), 0)
-		convertSQL = query
+		return nil, err
+	}
+	if !sameArgs(args, newArgs) {
+		golog.Warn("convertSQLPlugin", "Prepare", "converted args are reordered or repeated", d.session().ConnId, "sql", query, "converted", convertSQL)
+		return nil, fmt.Errorf("%w: prepared statement args are reordered or repeated after conversion", perrors.ErrStmtConvert)
 	}
 	stmt, err := d.db.Prepare(convertSQL)
 	return stmt, err
 }
 
 func (d *convertSQLPlugin) Exec(query string, args ...interface{}) (sql.Result, error) {
-	convertSQL, newArgs, err := d.converter.Convert(query, args...)
+	if sqlparser.Preview(query) == sqlparser.StmtDDL {
+		// 无论按哪种方式转换、执行是否成功（部分语句执行成功时表结构也可能已经变化），都重新加载涉及的表
+		defer d.reloadTables(query)
+		if converter, ok := d.metadata.Converter().(sqlparser.DDLConverter); ok {
+			if target, newArgs, ok := Rewrites.Rewrite(d.metadata.cfg.Name, query, args...); ok {
+				return d.db.Exec(target, newArgs...)
+			}
+			return d.execDDL(converter, query, args...)
+		}
+	}
+	convertSQL, newArgs, err := d.convert("Exec", query, args...)
 	if err != nil {
-		golog.Warn("convertSQLPlugin", "Prepare", err.Error(), 0)
-		convertSQL = query
+		return nil, err
 	}
 	res, err := d.db.Exec(convertSQL, newArgs...)
 	return res, err
 }
 
+// execDDL 一条MySQL的DDL可能转换为多条语句（建表、建索引、注释等），按顺序执行，遇到错误即返回
+func (d *convertSQLPlugin) execDDL(converter sqlparser.DDLConverter, query string, args ...interface{}) (sql.Result, error) {
+	start := time.Now()
+	session := d.session()
+	record := &ConvertRecord{
+		ConnId: session.ConnId,
+		User:   session.User,
+		Node:   d.metadata.cfg.Name,
+		Method: "Exec",
+		SQL:    query,
+		Args:   len(args),
+	}
+	stmts, err := converter.ConvertDDL(query)
+	record.Duration = time.Since(start).Microseconds()
+	if err != nil {
+		golog.Warn("convertSQLPlugin", "Exec", err.Error(), session.ConnId)
+		record.Error = err.Error()
+		if err = d.convertError(err); err != nil {
+			writeConvertLog(record)
+			return nil, err
+		}
+		record.Converted, record.NewArgs, record.Fallback = query, len(args), true
+		writeConvertLog(record)
+		return d.db.Exec(query, args...)
+	}
+	record.Converted = strings.Join(stmts, ";\n")
+	writeConvertLog(record)
+	// 只修改engine、charset等选项时没有需要执行的语句
+	var res sql.Result = noopResult{}
+	for _, stmt := range stmts {
+		if res, err = d.db.Exec(stmt); err != nil {
+			golog.Error("convertSQLPlugin", "Exec", err.Error(), session.ConnId, "sql", stmt)
+			break
+		}
+	}
+	if err != nil {
+		return nil, err
+	}
+	return res, nil
+}
+
+// reloadTables DDL执行后重新加载涉及的表
+func (d *convertSQLPlugin) reloadTables(query string) {
+	if err := d.metadata.ReloadTables(ddlTables(query)...); err != nil {
+		golog.Error("convertSQLPlugin", "Exec", err.Error(), 0, "sql", query)
+	}
+}
+
+// ddlTables DDL涉及的表，rename时包括新旧两个表名
+func ddlTables(query string) []string {
+	stmt, err := sqlparser.Parse(query)
+	if err != nil {
+		return nil
+	}
+	ddl, ok := stmt.(*sqlparser.DDL)
+	if !ok {
+		return nil
+	}
+	tables := []string{}
+	for _, name := range []sqlparser.TableName{ddl.Table, ddl.NewName} {
+		if !name.IsEmpty() && !sqlparser.StringIn(name.Name.String(), tables...) {
+			tables = append(tables, name.Name.String())
+		}
+	}
+	return tables
+}
+
+type noopResult struct{}
+
+func (noopResult) LastInsertId() (int64, error) { return 0, nil }
+
+func (noopResult) RowsAffected() (int64, error) { return 0, nil }
+
 func (d *convertSQLPlugin) Query(query string, args ...interface{}) (*sql.Rows, error) {
-	convertSQL, _, err := d.converter.Convert(query)
+	convertSQL, newArgs, err := d.convert("Query", query, args...)
 	if err != nil {
-		golog.Warn("convertSQLPlugin", "Prepare", err.Error(), 0)
-		convertSQL = query
+		return nil, err
 	}
-	res, err := d.db.Query(convertSQL, args...)
+	res, err := d.db.Query(convertSQL, newArgs...)
 	return res, err
 }
 
 func (d *convertSQLPlugin) QueryRow(query string, args ...interface{}) *sql.Row {
-	convertSQL, _, err := d.converter.Convert(query)
+	convertSQL, newArgs, err := d.convert("QueryRow", query, args...)
 	if err != nil {
-		golog.Warn("convertSQLPlugin", "Prepare", err.Error(), 0)
-		convertSQL = query
+		convertSQL, newArgs = query, args
 	}
-	res := d.db.QueryRow(convertSQL, args...)
+	res := d.db.QueryRow(convertSQL, newArgs...)
 	return res
 }
 
-func (d *convertSQLPlugin) Begin() (*sql.Tx, error) {
-	return d.db.(txer).Begin()
-}
+// convert 转换SQL，按指纹手工指定了改写的语句直接使用改写后的SQL，解析失败等情况使用原始SQL执行，
+// 明确无法转换的语句（例如带外连接的多表update）直接返回错误，避免在目标库上执行不兼容的SQL；
+// node配置了convert_strict时，任何转换失败都返回错误
+func (d *convertSQLPlugin) convert(method, query string, args ...interface{}) (string, []interface{}, error) {
+	start := time.Now()
+	session := d.session()
+	record := &ConvertRecord{
+		ConnId: session.ConnId,
+		User:   session.User,
+		Node:   d.metadata.cfg.Name,
+		Method: method,
+		SQL:    query,
+		Args:   len(args),
+	}
+	defer func() {
+		record.Duration = time.Since(start).Microseconds()
+		writeConvertLog(record)
+	}()
 
-func (d *convertSQLPlugin) Commit() error {
-	return d.db.(txEnder).Commit()
+	if target, newArgs, ok := d.rewrite(method, query, args...); ok {
+		golog.Debug("convertSQLPlugin", method, "rewrite by fingerprint", session.ConnId, "sql", query, "target", target)
+		record.Converted, record.NewArgs, record.Rewritten = target, len(newArgs), true
+		return target, newArgs, nil
+	}
+	convertSQL, newArgs, info, err := d.metadata.convert(query, args...)
+	record.Rules, record.Cached = info.rules, info.cached
+	if err == nil {
+		record.Converted, record.NewArgs = convertSQL, len(newArgs)
+		return convertSQL, newArgs, nil
+	}
+	golog.Warn("convertSQLPlugin", method, err.Error(), session.ConnId)
+	record.Error = err.Error()
+	if err = d.convertError(err); err != nil {
+		return "", nil, err
+	}
+	record.Converted, record.NewArgs, record.Fallback = query, len(args), true
+	return query, args, nil
 }
 
-func (d *convertSQLPlugin) Rollback() error {
-	return d.db.(txEnder).Rollback()
+// rewrite 按指纹查找手工指定的改写，预处理时还没有参数，绑定参数按位置对应
+func (d *convertSQLPlugin) rewrite(method, query string, args ...interface{}) (string, []interface{}, bool) {
+	if method == "Prepare" {
+		target, ok := Rewrites.RewritePrepare(d.metadata.cfg.Name, query)
+		return target, args, ok
+	}
+	return Rewrites.Rewrite(d.metadata.cfg.Name, query, args...)
 }
 
-func wrapConverter(db dbQuerierWithCtx, alias, driverName string, converterName string) (dbQuerierWithCtx, error) {
-	value := db.GetContext().Value(CTX_KEY_CONVERTER)
-	if value != nil && value.(sqlparser.SQLConverter) != nil {
-		golog.Info("convertSQLPlugin", "wrapConverter", "reuse sql converter from context", 0, "alias", alias)
-		return &convertSQLPlugin{
-			db:        db,
-			converter: value.(sqlparser.SQLConverter),
-		}, nil
+// prepareArgs 预处理语句的绑定参数按位置取为bindArg(1)、bindArg(2)...，语句无法解析时为空
+func prepareArgs(query string) []interface{} {
+	params, err := queryParams(query, nil, true)
+	if err != nil {
+		return nil
 	}
+	args := make([]interface{}, countBindArgs(params))
+	for i := range args {
+		args[i] = bindArg(i + 1)
+	}
+	return args
+}
 
-	converter, err := getConverter(db, alias, driverName, converterName)
-	if converter == nil {
-		golog.Warn("convertSQLPlugin", "wrapConverter", "Unsupported converterName:"+converterName, 0, err)
-		return db, nil
+func sameArgs(args, newArgs []interface{}) bool {
+	if len(args) != len(newArgs) {
+		return false
+	}
+	for i := range args {
+		if args[i] != newArgs[i] {
+			return false
+		}
 	}
+	return true
+}
 
-	d := new(convertSQLPlugin)
-	d.db = db
-	d.converter = converter
-	d.WithContext(context.WithValue(db.GetContext(), CTX_KEY_CONVERTER, converter))
-	return d, nil
+// convertError 转换失败时返回给客户端的错误，返回nil表示使用原始SQL执行
+func (d *convertSQLPlugin) convertError(err error) error {
+	if errors.Is(err, perrors.ErrStmtConvert) {
+		return err
+	}
+	if d.metadata.cfg.ConvertStrict {
+		return mysql.NewError(mysql.ER_NOT_SUPPORTED_YET, fmt.Sprintf("convert sql for node %s failed: %v", d.metadata.cfg.Name, err))
+	}
+	return nil
+}
 
+// session 发起SQL的客户端连接，不是客户端发起的（例如加载表结构）时为空
+func (d *convertSQLPlugin) session() *Session {
+	if session, ok := d.GetContext().Value(CTX_KEY_SESSION).(*Session); ok && session != nil {
+		return session
+	}
+	return &Session{}
 }
 
-func getConverter(db dbQuerier, alias, driverName, converterName string) (sqlparser.SQLConverter, error) {
-	//支持达梦DB，查询表唯一索引和主键，用于 (on duplicate key update)  ->  (merge into ... using dual on ... when matched then update ... when not matched then insert)
-	tableUniqueIndexs := map[string]map[string][]string{}
-	incrementColumns := map[string]map[string]int{}
-	tableColumns := map[string][]string{}
-	var err error
-	if driverName == "dm" {
-		tableUniqueIndexs, err = getTableUniqueIndexs(db, alias)
-		if err != nil {
-			return nil, err
-		}
+func (d *convertSQLPlugin) Begin() (*sql.Tx, error) {
+	return d.db.(txer).Begin()
+}
 
-		tableColumns, incrementColumns, err = getTableColumns(db, alias)
-		if err != nil {
-			return nil, err
-		}
-	}
+func (d *convertSQLPlugin) Commit() error {
+	return d.db.(txEnder).Commit()
+}
 
-	golog.Info("convertSQLPlugin", "wrapConverter", fmt.Sprintf("alias: %s, converterName: %s", alias, converterName), 0)
-	converter := sqlparser.GetSQLConverter(converterName, tableUniqueIndexs, tableColumns, incrementColumns)
-	return converter, nil
+func (d *convertSQLPlugin) Rollback() error {
+	return d.db.(txEnder).Rollback()
 }
 
-func getTableUniqueIndexs(db dbQuerier, alias string) (map[string]map[string][]string, error) {
-	rows, err := db.Query(fmt.Sprintf(`select cc.table_name, cc.constraint_name, cc.column_name from dba_constraints c, dba_cons_columns cc where c.constraint_name = cc.constraint_name and c.owner = '%s' and (c.constraint_type='U' or c.constraint_type='P')`, alias))
-	if err != nil {
-		return nil, err
-	}
-	constraints := make(map[string]map[string][]string)
-	for rows.Next() {
-		var (
-			tableName  string
-			indexName  string
-			columnName string
-		)
-		if err1 := rows.Scan(&tableName, &indexName, &columnName); err1 != nil {
-			return nil, err
-		}
-		if constraints[tableName] == nil {
-			constraints[tableName] = make(map[string][]string)
+func wrapConverter(db dbQuerierWithCtx, cfg config.NodeConfig, converterName string) (dbQuerierWithCtx, error) {
+	alias := cfg.Name
+	value := db.GetContext().Value(CTX_KEY_METADATA)
+	if metadata, ok := value.(*MetadataCache); ok && metadata != nil {
+		golog.Info("convertSQLPlugin", "wrapConverter", "reuse metadata cache from context", 0, "alias", alias)
+		d := &convertSQLPlugin{
+			db:       db,
+			metadata: metadata,
 		}
-		if constraints[tableName][indexName] == nil {
-			constraints[tableName][indexName] = []string{}
-		}
-		constraints[tableName][indexName] = append(constraints[tableName][indexName], columnName)
+		d.WithContext(db.GetContext())
+		return d, nil
 	}
-	return constraints, nil
-}
 
-func getTableColumns(db dbQuerier, alias string) (map[string][]string, map[string]map[string]int, error) {
-	rows, err := db.Query(fmt.Sprintf(`select b.object_name table_name,a.name col_name, a.colid col_id,a.info2 is_incr from syscolumns a, all_objects b where a.id=b.object_id and b.object_type='table' and b.owner='%s' order by a.colid asc`, alias))
+	opts := sqlparser.ConvertOptions{
+		Pagination:     cfg.Pagination,
+		UpperCaseIdent: cfg.UpperCaseIdent,
+		DriverName:     cfg.DriverName,
+	}
+	golog.Info("convertSQLPlugin", "wrapConverter", fmt.Sprintf("alias: %s, converterName: %s", alias, converterName), 0)
+	metadata, err := NewMetadataCache(db, cfg, converterName, opts)
 	if err != nil {
-		return nil, nil, err
-	}
-	tableColumns := make(map[string][]string)
-	incrementColumns := make(map[string]map[string]int)
-	for rows.Next() {
-		var (
-			tableName  string
-			columnName string
-			colIndex   int
-			isIncr     int
-		)
-		if err1 := rows.Scan(&tableName, &columnName, &colIndex, &isIncr); err1 != nil {
-			return nil, nil, err1
-		}
-		if tableColumns[tableName] == nil {
-			tableColumns[tableName] = []string{}
-		}
-		tableColumns[tableName] = append(tableColumns[tableName], columnName)
-		if isIncr&0x01 == 0x01 {
-			if incrementColumns[tableName] == nil {
-				incrementColumns[tableName] = make(map[string]int)
-			}
-			incrementColumns[tableName][columnName] = colIndex
-		}
+		// 没有表结构时转换结果不正确，node初始化失败，不能直接使用未转换的连接
+		return nil, fmt.Errorf("load metadata for node %s: %v", alias, err)
 	}
-	return tableColumns, incrementColumns, nil
+
+	d := new(convertSQLPlugin)
+	d.db = db
+	d.metadata = metadata
+	d.WithContext(context.WithValue(db.GetContext(), CTX_KEY_METADATA, metadata))
+	return d, nil
+
 }
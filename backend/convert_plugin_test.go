@@ -0,0 +1,30 @@
+package backend
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"sqlproxy/config"
+	perrors "sqlproxy/core/errors"
+	"sqlproxy/sqlparser"
+)
+
+func TestConvertPluginPrepare(t *testing.T) {
+	metadata := &MetadataCache{cfg: config.NodeConfig{Name: "uc_uniform"}}
+	metadata.converter.Store(sqlparser.GetSQLConverter(sqlparser.MYSQL_TO_ORACLE, nil, nil, nil,
+		sqlparser.ConvertOptions{Pagination: sqlparser.PAGINATION_ROWNUM}))
+	d := &convertSQLPlugin{db: testdb.db, metadata: metadata}
+
+	// 参数与原语句一一对应
+	stmt, err := d.Prepare("select cal_name from webcal_entry where cal_id = ?")
+	assert.Nil(t, err)
+	if assert.NotNil(t, stmt) {
+		stmt.Close()
+	}
+
+	// rownum分页重复使用了offset参数，执行时的参数无法对应
+	_, err = d.Prepare("select cal_name from webcal_entry where cal_id = ? limit ?, ?")
+	assert.True(t, errors.Is(err, perrors.ErrStmtConvert))
+}
@@ -0,0 +1,4 @@
+package backend
+
+// 纯Go实现的SQLite驱动，注册的驱动名为sqlite，用于本地开发和测试
+import _ "modernc.org/sqlite"
@@ -0,0 +1,281 @@
+package backend
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"sqlproxy/config"
+	"sqlproxy/core/golog"
+	"sqlproxy/sqlparser"
+)
+
+var ErrNoMetadata = errors.New("node has no metadata cache")
+
+// tableMetadata 目标库的表结构信息，发布后不再修改，更新时复制一份
+type tableMetadata struct {
+	tableUniqueIndexs map[string]map[string][]string // table -> index -> columns
+	tableColumns      map[string][]string            // table -> columns
+	incrementColumns  map[string]map[string]int      // table -> column -> column id
+}
+
+func (m *tableMetadata) clone() *tableMetadata {
+	n := &tableMetadata{
+		tableUniqueIndexs: make(map[string]map[string][]string, len(m.tableUniqueIndexs)),
+		tableColumns:      make(map[string][]string, len(m.tableColumns)),
+		incrementColumns:  make(map[string]map[string]int, len(m.incrementColumns)),
+	}
+	for k, v := range m.tableUniqueIndexs {
+		n.tableUniqueIndexs[k] = v
+	}
+	for k, v := range m.tableColumns {
+		n.tableColumns[k] = v
+	}
+	for k, v := range m.incrementColumns {
+		n.incrementColumns[k] = v
+	}
+	return n
+}
+
+// normalizeKeys 表名和自增列名按转换器查找时的写法存储，例如Oracle的数据字典中为大写，
+// 列的列表保留目标库中的写法
+func (m *tableMetadata) normalizeKeys(opts sqlparser.ConvertOptions) *tableMetadata {
+	n := &tableMetadata{
+		tableUniqueIndexs: make(map[string]map[string][]string, len(m.tableUniqueIndexs)),
+		tableColumns:      make(map[string][]string, len(m.tableColumns)),
+		incrementColumns:  make(map[string]map[string]int, len(m.incrementColumns)),
+	}
+	for k, v := range m.tableUniqueIndexs {
+		n.tableUniqueIndexs[opts.MetadataKey(k)] = v
+	}
+	for k, v := range m.tableColumns {
+		n.tableColumns[opts.MetadataKey(k)] = v
+	}
+	for k, v := range m.incrementColumns {
+		columns := make(map[string]int, len(v))
+		for column, id := range v {
+			columns[opts.MetadataKey(column)] = id
+		}
+		n.incrementColumns[opts.MetadataKey(k)] = columns
+	}
+	return n
+}
+
+// MetadataCache 缓存node的表结构信息（唯一索引、列、自增列）及据此生成的SQL转换器：
+// 配置了metadata_refresh_interval时定时全量刷新，经过中间件执行的DDL会重新加载对应的表，
+// 也可以通过web api手动刷新
+type MetadataCache struct {
+	db            dbQuerier
+	cfg           config.NodeConfig
+	converterName string
+	opts          sqlparser.ConvertOptions
+
+	mu        sync.Mutex // 串行化加载
+	meta      *tableMetadata
+	rules     []*ConvertRule
+	converter atomic.Value // sqlparser.SQLConverter
+	normalize int32        // 是否按归一化后的SQL缓存转换结果，需要配置开启，有按语法树节点匹配的规则时不可以
+	cache     *ConvertCache
+
+	done      chan struct{} // 关闭后停止定时刷新
+	closeOnce sync.Once
+}
+
+func NewMetadataCache(db dbQuerier, cfg config.NodeConfig, converterName string, opts sqlparser.ConvertOptions) (*MetadataCache, error) {
+	c := &MetadataCache{
+		db:            db,
+		cfg:           cfg,
+		converterName: converterName,
+		opts:          opts,
+		cache:         NewConvertCache(cfg.ConvertCacheSize),
+		done:          make(chan struct{}),
+	}
+	if err := c.Reload(); err != nil {
+		return nil, err
+	}
+	if cfg.MetadataRefreshInterval > 0 {
+		go c.refresh(time.Duration(cfg.MetadataRefreshInterval) * time.Second)
+	}
+	return c, nil
+}
+
+// Converter 返回基于当前表结构的转换器
+func (c *MetadataCache) Converter() sqlparser.SQLConverter {
+	converter, _ := c.converter.Load().(sqlparser.SQLConverter)
+	return converter
+}
+
+// Convert 使用当前的转换器转换SQL，开启了缓存时优先使用缓存的转换结果
+func (c *MetadataCache) Convert(query string, args ...interface{}) (string, []interface{}, error) {
+	sql, newArgs, _, err := c.convert(query, args...)
+	return sql, newArgs, err
+}
+
+func (c *MetadataCache) convert(query string, args ...interface{}) (string, []interface{}, convertInfo, error) {
+	if c.cache == nil {
+		sql, newArgs, rules, err := convertWithRules(c.Converter(), query, args...)
+		return sql, newArgs, convertInfo{rules: rules}, err
+	}
+	return c.cache.convert(func() (sqlparser.SQLConverter, bool) {
+		return c.Converter(), atomic.LoadInt32(&c.normalize) == 1
+	}, query, args...)
+}
+
+// CacheStats 转换缓存的统计信息，没有开启缓存时返回nil
+func (c *MetadataCache) CacheStats() *ConvertCacheStats {
+	if c.cache == nil {
+		return nil
+	}
+	stats := c.cache.Stats()
+	return &stats
+}
+
+// HasIncrementColumn 表是否有自增列
+func (c *MetadataCache) HasIncrementColumn(table string) bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return len(c.meta.incrementColumns[c.tableName(table)]) > 0
+}
+
+// Reload 全量加载表结构，同时重新加载自定义的转换规则
+func (c *MetadataCache) Reload() error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	meta, err := c.load()
+	if err != nil {
+		return err
+	}
+	if c.cfg.ConvertRulesFile != "" {
+		rules, err := LoadConvertRules(c.cfg.ConvertRulesFile)
+		if err != nil {
+			return err
+		}
+		c.rules = rules
+	}
+	return c.publish(meta)
+}
+
+// ReloadTables 重新加载指定的表，DDL执行后调用，表被删除时去掉对应的信息
+func (c *MetadataCache) ReloadTables(tables ...string) error {
+	if len(tables) == 0 {
+		return nil
+	}
+	names := make([]string, len(tables))
+	for i, table := range tables {
+		names[i] = c.tableName(table)
+	}
+	tables = names
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	loaded, err := c.load(tables...)
+	if err != nil {
+		return err
+	}
+	meta := c.meta.clone()
+	for _, table := range tables {
+		delete(meta.tableUniqueIndexs, table)
+		delete(meta.tableColumns, table)
+		delete(meta.incrementColumns, table)
+		if v, ok := loaded.tableUniqueIndexs[table]; ok {
+			meta.tableUniqueIndexs[table] = v
+		}
+		if v, ok := loaded.tableColumns[table]; ok {
+			meta.tableColumns[table] = v
+		}
+		if v, ok := loaded.incrementColumns[table]; ok {
+			meta.incrementColumns[table] = v
+		}
+	}
+	golog.Info("MetadataCache", "ReloadTables", "", 0, "node", c.cfg.Name, "tables", tables)
+	return c.publish(meta)
+}
+
+// Close 停止定时刷新，node重新初始化时旧的缓存需要关闭
+func (c *MetadataCache) Close() {
+	c.closeOnce.Do(func() {
+		close(c.done)
+	})
+}
+
+func (c *MetadataCache) refresh(interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-c.done:
+			return
+		case <-ticker.C:
+			if err := c.Reload(); err != nil {
+				golog.Error("MetadataCache", "refresh", err.Error(), 0, "node", c.cfg.Name)
+			}
+		}
+	}
+}
+
+// load 加载表结构，tables为空时加载全部的表
+func (c *MetadataCache) load(tables ...string) (*tableMetadata, error) {
+	// 查询表唯一索引和主键，用于 (on duplicate key update)  ->  (merge into ... using dual on ... when matched then update ... when not matched then insert)
+	meta := &tableMetadata{
+		tableUniqueIndexs: map[string]map[string][]string{},
+		tableColumns:      map[string][]string{},
+		incrementColumns:  map[string]map[string]int{},
+	}
+	provider := getMetadataProvider(c.cfg.DriverName)
+	if provider == nil {
+		return meta, nil
+	}
+	owner := metadataOwner(c.cfg, provider)
+	var err error
+	meta.tableUniqueIndexs, err = provider.UniqueIndexs(c.db, owner, tables...)
+	if err != nil {
+		return nil, err
+	}
+	meta.tableColumns, meta.incrementColumns, err = provider.Columns(c.db, owner, tables...)
+	if err != nil {
+		return nil, err
+	}
+	return meta.normalizeKeys(c.opts), nil
+}
+
+func (c *MetadataCache) publish(meta *tableMetadata) error {
+	converter, err := newConverter(c.converterName, meta, c.opts, c.rules)
+	if err != nil {
+		return err
+	}
+	var normalize int32
+	if c.cfg.ConvertCacheNormalize {
+		normalize = 1
+	}
+	for _, rule := range c.rules {
+		if rule.pattern != nil {
+			normalize = 0
+		}
+	}
+	c.meta = meta
+	c.converter.Store(converter)
+	atomic.StoreInt32(&c.normalize, normalize)
+	// 转换器更新后再清空，清空前开始的转换结果不会写入缓存
+	if c.cache != nil {
+		c.cache.Purge()
+	}
+	return nil
+}
+
+// newConverter 按表结构创建转换器，有自定义规则时先按规则改写
+func newConverter(converterName string, meta *tableMetadata, opts sqlparser.ConvertOptions, rules []*ConvertRule) (sqlparser.SQLConverter, error) {
+	converter := sqlparser.GetSQLConverter(converterName, meta.tableUniqueIndexs, meta.tableColumns, meta.incrementColumns, opts)
+	if converter == nil {
+		return nil, fmt.Errorf("unsupported converter name: %s", converterName)
+	}
+	if len(rules) > 0 {
+		converter = NewRuleConverter(rules, converter)
+	}
+	return converter, nil
+}
+
+// tableName 目标库数据字典中表名的写法，与缓存中的表名一致
+func (c *MetadataCache) tableName(name string) string {
+	return c.opts.MetadataKey(name)
+}
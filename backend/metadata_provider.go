@@ -0,0 +1,190 @@
+package backend
+
+import (
+	"database/sql"
+	"fmt"
+	"sqlproxy/config"
+	"strings"
+	"sync"
+)
+
+// MetadataProvider 从目标库的系统表中查询SQL转换需要的表结构信息，不同数据库的系统表不同。
+// tables为空时查询owner下全部的表
+type MetadataProvider interface {
+	// UniqueIndexs 表的主键和唯一约束：table -> index -> columns
+	UniqueIndexs(db dbQuerier, owner string, tables ...string) (map[string]map[string][]string, error)
+	// Columns 表的全部列及自增列：table -> columns, table -> column -> column id
+	Columns(db dbQuerier, owner string, tables ...string) (map[string][]string, map[string]map[string]int, error)
+}
+
+var (
+	metadataProvidersMu sync.RWMutex
+	metadataProviders   = map[string]MetadataProvider{
+		"dm":       dmMetadataProvider{},
+		"oci8":     oracleMetadataProvider{},
+		"postgres": postgresMetadataProvider{},
+		"pgx":      postgresMetadataProvider{},
+		"sqlite":   sqliteMetadataProvider{},
+	}
+)
+
+// RegisterMetadataProvider 注册驱动对应的MetadataProvider，已存在时覆盖
+func RegisterMetadataProvider(driverName string, provider MetadataProvider) {
+	metadataProvidersMu.Lock()
+	defer metadataProvidersMu.Unlock()
+	metadataProviders[driverName] = provider
+}
+
+func getMetadataProvider(driverName string) MetadataProvider {
+	metadataProvidersMu.RLock()
+	defer metadataProvidersMu.RUnlock()
+	return metadataProviders[driverName]
+}
+
+// 达梦：约束来自dba_constraints，自增列是syscolumns中info2的最低位
+type dmMetadataProvider struct{}
+
+func (dmMetadataProvider) UniqueIndexs(db dbQuerier, owner string, tables ...string) (map[string]map[string][]string, error) {
+	cond, args := inClause("cc.table_name", tables, 2, questionPlaceholder)
+	query := `select cc.table_name, cc.constraint_name, cc.column_name from dba_constraints c, dba_cons_columns cc where c.constraint_name = cc.constraint_name and c.owner = ? and (c.constraint_type='U' or c.constraint_type='P')` + cond
+	return queryUniqueIndexs(db, query, append([]interface{}{owner}, args...)...)
+}
+
+func (dmMetadataProvider) Columns(db dbQuerier, owner string, tables ...string) (map[string][]string, map[string]map[string]int, error) {
+	cond, args := inClause("b.object_name", tables, 2, questionPlaceholder)
+	query := `select b.object_name table_name,a.name col_name, a.colid col_id,a.info2 is_incr from syscolumns a, all_objects b where a.id=b.object_id and b.object_type='table' and b.owner=?` + cond + ` order by a.colid asc`
+	return queryColumns(db, query, func(isIncr int) bool { return isIncr&0x01 == 0x01 }, append([]interface{}{owner}, args...)...)
+}
+
+// Oracle：约束来自all_constraints，自增列为12c及以上版本all_tab_columns中的identity_column
+type oracleMetadataProvider struct{}
+
+func (oracleMetadataProvider) UniqueIndexs(db dbQuerier, owner string, tables ...string) (map[string]map[string][]string, error) {
+	cond, args := inClause("cc.table_name", tables, 2, colonPlaceholder)
+	query := `select cc.table_name, cc.constraint_name, cc.column_name from all_constraints c, all_cons_columns cc where c.owner = cc.owner and c.constraint_name = cc.constraint_name and c.owner = :1 and c.constraint_type in ('U', 'P')` + cond + ` order by cc.table_name, cc.constraint_name, cc.position`
+	return queryUniqueIndexs(db, query, append([]interface{}{owner}, args...)...)
+}
+
+func (oracleMetadataProvider) Columns(db dbQuerier, owner string, tables ...string) (map[string][]string, map[string]map[string]int, error) {
+	cond, args := inClause("table_name", tables, 2, colonPlaceholder)
+	query := `select table_name, column_name, column_id, case identity_column when 'YES' then 1 else 0 end is_incr from all_tab_columns where owner = :1` + cond + ` order by table_name, column_id`
+	return queryColumns(db, query, func(isIncr int) bool { return isIncr == 1 }, append([]interface{}{owner}, args...)...)
+}
+
+// PostgreSQL：owner对应schema，为空时使用current_schema()，唯一索引来自pg_index（不含部分索引），自增列为identity列或默认值为nextval的serial列
+type postgresMetadataProvider struct{}
+
+func (postgresMetadataProvider) UniqueIndexs(db dbQuerier, owner string, tables ...string) (map[string]map[string][]string, error) {
+	cond, args := inClause("t.relname", tables, 2, dollarPlaceholder)
+	query := `select t.relname table_name, i.relname index_name, a.attname column_name from pg_index x join pg_class t on t.oid = x.indrelid join pg_class i on i.oid = x.indexrelid join pg_namespace n on n.oid = t.relnamespace join pg_attribute a on a.attrelid = t.oid and a.attnum = any(x.indkey) where x.indisunique and x.indpred is null and n.nspname = coalesce(nullif($1, ''), current_schema())` + cond + ` order by t.relname, i.relname, array_position(x.indkey::int2[], a.attnum)`
+	return queryUniqueIndexs(db, query, append([]interface{}{owner}, args...)...)
+}
+
+func (postgresMetadataProvider) Columns(db dbQuerier, owner string, tables ...string) (map[string][]string, map[string]map[string]int, error) {
+	cond, args := inClause("table_name", tables, 2, dollarPlaceholder)
+	query := `select table_name, column_name, ordinal_position, case when is_identity = 'YES' or column_default like 'nextval(%' then 1 else 0 end is_incr from information_schema.columns where table_schema = coalesce(nullif($1, ''), current_schema())` + cond + ` order by table_name, ordinal_position`
+	return queryColumns(db, query, func(isIncr int) bool { return isIncr == 1 }, append([]interface{}{owner}, args...)...)
+}
+
+// SQLite：没有schema，忽略owner，唯一索引和列来自pragma表函数，
+// 自增列为声明了autoincrement的单列integer主键
+type sqliteMetadataProvider struct{}
+
+func (sqliteMetadataProvider) UniqueIndexs(db dbQuerier, owner string, tables ...string) (map[string]map[string][]string, error) {
+	cond, args := inClause("m.name", tables, 1, questionPlaceholder)
+	query := `select m.name table_name, il.name index_name, ii.name column_name from sqlite_master m join pragma_index_list(m.name) il join pragma_index_info(il.name) ii where m.type = 'table' and il."unique" = 1` + cond + ` order by m.name, il.name, ii.seqno`
+	return queryUniqueIndexs(db, query, args...)
+}
+
+func (sqliteMetadataProvider) Columns(db dbQuerier, owner string, tables ...string) (map[string][]string, map[string]map[string]int, error) {
+	cond, args := inClause("m.name", tables, 1, questionPlaceholder)
+	query := `select m.name table_name, c.name column_name, c.cid, case when c.pk = 1 and lower(c.type) = 'integer' and lower(m.sql) like '%autoincrement%' and (select count(*) from pragma_table_info(m.name) k where k.pk > 0) = 1 then 1 else 0 end is_incr from sqlite_master m join pragma_table_info(m.name) c where m.type = 'table'` + cond + ` order by m.name, c.cid`
+	return queryColumns(db, query, func(isIncr int) bool { return isIncr == 1 }, args...)
+}
+
+// metadataOwner 查询表结构的owner：优先使用metadata_schema，PostgreSQL没有配置时为空，
+// 查询连接的current_schema()，node的name通常是数据库名而不是schema
+func metadataOwner(cfg config.NodeConfig, provider MetadataProvider) string {
+	if cfg.MetadataSchema != "" {
+		return cfg.MetadataSchema
+	}
+	if _, ok := provider.(postgresMetadataProvider); ok {
+		return ""
+	}
+	return cfg.Name
+}
+
+func questionPlaceholder(int) string { return "?" }
+
+func colonPlaceholder(i int) string { return fmt.Sprintf(":%d", i) }
+
+func dollarPlaceholder(i int) string { return fmt.Sprintf("$%d", i) }
+
+// inClause 生成 and column in (?, ?) 条件，start为第一个参数的序号
+func inClause(column string, values []string, start int, placeholder func(int) string) (string, []interface{}) {
+	if len(values) == 0 {
+		return "", nil
+	}
+	marks := make([]string, len(values))
+	args := make([]interface{}, len(values))
+	for i, v := range values {
+		marks[i] = placeholder(start + i)
+		args[i] = v
+	}
+	return fmt.Sprintf(" and %s in (%s)", column, strings.Join(marks, ", ")), args
+}
+
+// queryUniqueIndexs 查询结果的列依次为：表名、约束名、列名
+func queryUniqueIndexs(db dbQuerier, query string, args ...interface{}) (map[string]map[string][]string, error) {
+	rows, err := db.Query(query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	constraints := make(map[string]map[string][]string)
+	for rows.Next() {
+		var (
+			tableName  string
+			indexName  string
+			columnName string
+		)
+		if err1 := rows.Scan(&tableName, &indexName, &columnName); err1 != nil {
+			return nil, err1
+		}
+		if constraints[tableName] == nil {
+			constraints[tableName] = make(map[string][]string)
+		}
+		constraints[tableName][indexName] = append(constraints[tableName][indexName], columnName)
+	}
+	return constraints, rows.Err()
+}
+
+// queryColumns 查询结果的列依次为：表名、列名、列序号、自增标识，按列序号排序
+func queryColumns(db dbQuerier, query string, isIncrement func(int) bool, args ...interface{}) (map[string][]string, map[string]map[string]int, error) {
+	rows, err := db.Query(query, args...)
+	if err != nil {
+		return nil, nil, err
+	}
+	defer rows.Close()
+	tableColumns := make(map[string][]string)
+	incrementColumns := make(map[string]map[string]int)
+	for rows.Next() {
+		var (
+			tableName  string
+			columnName string
+			colIndex   int
+			isIncr     sql.NullInt64
+		)
+		if err1 := rows.Scan(&tableName, &columnName, &colIndex, &isIncr); err1 != nil {
+			return nil, nil, err1
+		}
+		tableColumns[tableName] = append(tableColumns[tableName], columnName)
+		if isIncrement(int(isIncr.Int64)) {
+			if incrementColumns[tableName] == nil {
+				incrementColumns[tableName] = make(map[string]int)
+			}
+			incrementColumns[tableName][columnName] = colIndex
+		}
+	}
+	return tableColumns, incrementColumns, rows.Err()
+}
@@ -0,0 +1,24 @@
+package backend
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"sqlproxy/config"
+)
+
+func TestMetadataOwner(t *testing.T) {
+	testCases := []struct {
+		cfg   config.NodeConfig
+		owner string
+	}{
+		{cfg: config.NodeConfig{Name: "demodb", DriverName: "dm"}, owner: "demodb"},
+		{cfg: config.NodeConfig{Name: "demodb", DriverName: "oci8", MetadataSchema: "APP"}, owner: "APP"},
+		{cfg: config.NodeConfig{Name: "demodb", DriverName: "postgres"}, owner: ""},
+		{cfg: config.NodeConfig{Name: "demodb", DriverName: "pgx", MetadataSchema: "app"}, owner: "app"},
+	}
+	for _, tcase := range testCases {
+		assert.Equal(t, tcase.owner, metadataOwner(tcase.cfg, getMetadataProvider(tcase.cfg.DriverName)))
+	}
+}
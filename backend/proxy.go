@@ -1,8 +1,10 @@
 package backend
 
 import (
+	"context"
 	"database/sql"
 	"errors"
+	"fmt"
 	"sqlproxy/config"
 	"sqlproxy/core/golog"
 	"sqlproxy/mysql"
@@ -17,9 +19,11 @@ var (
 )
 
 type BackendProxy struct {
-	cfg  config.NodeConfig
-	isTx bool             // 是否在事务中
-	db   dbQuerierWithCtx // 实现了sql.DB接口的对象，可以是sql.DB，也可以是其它包装后的对象
+	cfg      config.NodeConfig
+	isTx     bool             // 是否在事务中
+	pool     dbQuerier        // 连接池，WithSession时在其上重新包装
+	db       dbQuerierWithCtx // 实现了sql.DB接口的对象，可以是sql.DB，也可以是其它包装后的对象
+	metadata *MetadataCache   // 需要转换SQL的node才有
 
 }
 
@@ -53,9 +57,12 @@ func (n *BackendProxy) InitConnectionPool() error {
 
 	db, err := wrapFunctions(&PoolWrapper{dbQuerier: pool}, n.cfg)
 	if err != nil {
+		pool.Close()
 		return err
 	}
+	n.pool = pool
 	n.db = db
+	n.metadata, _ = db.GetContext().Value(CTX_KEY_METADATA).(*MetadataCache)
 
 	err = n.checkAvailable()
 	if err != nil {
@@ -66,6 +73,58 @@ func (n *BackendProxy) InitConnectionPool() error {
 	return nil
 }
 
+// WithSession 返回带有客户端连接信息的BackendProxy，与原对象共用连接池和表结构缓存，
+// 在其上开启的事务也带有连接信息
+func (n *BackendProxy) WithSession(session *Session) (*BackendProxy, error) {
+	if n.db == nil || n.pool == nil || n.isTx {
+		return n, nil
+	}
+	wrapper := &PoolWrapper{dbQuerier: n.pool}
+	wrapper.WithContext(context.WithValue(n.db.GetContext(), CTX_KEY_SESSION, session))
+	db, err := wrapFunctions(wrapper, n.cfg)
+	if err != nil {
+		return nil, err
+	}
+	return &BackendProxy{
+		cfg:      n.cfg,
+		pool:     n.pool,
+		db:       db,
+		metadata: n.metadata,
+	}, nil
+}
+
+// Close 停止node的后台任务（表结构的定时刷新），配置重新加载后旧的node需要关闭；
+// 连接池可能还在被未结束的事务使用，不在这里关闭
+func (n *BackendProxy) Close() {
+	if n.metadata != nil {
+		n.metadata.Close()
+	}
+}
+
+// ReloadMetadata 重新加载node的表结构信息
+func (n *BackendProxy) ReloadMetadata() error {
+	if n.metadata == nil {
+		return ErrNoMetadata
+	}
+	return n.metadata.Reload()
+}
+
+// DumpMetadata 导出node的表结构信息
+func (n *BackendProxy) DumpMetadata() (*SchemaDump, error) {
+	if n.metadata == nil {
+		return nil, ErrNoMetadata
+	}
+	return n.metadata.Dump(), nil
+}
+
+// ConvertCacheStats 转换缓存的统计信息，不需要转换或没有开启缓存时返回nil
+func (n *BackendProxy) ConvertCacheStats() *ConvertCacheStats {
+	if n.metadata == nil {
+		return nil
+	}
+	return n.metadata.CacheStats()
+}
+
 func (n *BackendProxy) checkAvailable() error {
 	if n.db == nil {
 		return ErrDbNullPointer
@@ -106,23 +165,16 @@ func (n *BackendProxy) Exec(query string, args ...interface{}) (*mysql.Result, e
 	}, nil
 }
 
-func (n *BackendProxy) query(query string, args ...interface{}) ([][]sql.RawBytes, []*sql.ColumnType, error) {
-	if n.db == nil {
-		return nil, nil, ErrDbNullPointer
-	}
-
-	cursor, err := n.db.Query(query, args...)
+func (n *BackendProxy) query(query string, args ...interface{}) ([][]sql.RawBytes, []*mysql.Field, error) {
+	cursor, err := n.QueryRows(query, args...)
 	if err != nil {
 		return nil, nil, err
 	}
 	defer cursor.Close()
 	golog.Debug("BackendProxy", "query", "db...", 0)
 
-	columnTypes, err := cursor.ColumnTypes()
-	if err != nil {
-		return nil, nil, err
-	}
-	columnTypeNames := make([]string, len(columnTypes))
+	columnTypes := cursor.ColumnTypes()
+	columnTypeNames := make([]string, 0, len(columnTypes))
 	for _, column := range columnTypes {
 		columnTypeNames = append(columnTypeNames, column.Name())
 	}
@@ -131,24 +183,36 @@ func (n *BackendProxy) query(query string, args ...interface{}) ([][]sql.RawByte
 
 	rows := make([][]sql.RawBytes, 0)
 	for cursor.Next() {
-		values, err := readRow(n.cfg.DriverName, columnTypes, cursor)
+		values, err := cursor.Row()
 		if err != nil {
 			return nil, nil, err
 		}
 		rows = append(rows, values)
 	}
+	if err := cursor.Err(); err != nil {
+		return nil, nil, err
+	}
 	golog.Debug("BackendProxy", "query", "rows size", 0, len(rows), time.Now().UnixNano())
 
-	return rows, columnTypes, nil
+	return rows, cursor.Fields(), nil
+}
+
+// copyRawBytes RawBytes引用的是驱动或database/sql内部的缓冲区，下一次Next时会被覆盖，
+// 读出的行要在结果集中保存，需要复制一份；null保持为nil，空串保持为非nil
+func copyRawBytes(b sql.RawBytes) sql.RawBytes {
+	if b == nil {
+		return nil
+	}
+	return append(make(sql.RawBytes, 0, len(b)), b...)
 }
 
 func (n *BackendProxy) Query(query string, args ...interface{}) (*mysql.Result, error) {
-	rows, columnTypes, err := n.query(query, args...)
+	rows, fields, err := n.query(query, args...)
 	if err != nil {
 		return nil, err
 	}
 
-	rs, err := mysql.BuildResultset(rows, columnTypes, false)
+	rs, err := mysql.BuildResultset(rows, fields, false)
 	if err != nil {
 		return nil, err
 	}
@@ -162,12 +226,12 @@ func (n *BackendProxy) Query(query string, args ...interface{}) (*mysql.Result,
 }
 
 func (n *BackendProxy) StmtQuery(query string, args ...interface{}) (*mysql.Result, error) {
-	rows, columns, err := n.query(query, args...)
+	rows, fields, err := n.query(query, args...)
 	if err != nil {
 		return nil, err
 	}
 
-	rs, err := mysql.BuildResultset(rows, columns, true)
+	rs, err := mysql.BuildResultset(rows, fields, true)
 	if err != nil {
 		return nil, err
 	}
@@ -231,6 +295,26 @@ func (n *BackendProxy) Rollback() error {
 	return err
 }
 
+// 各驱动默认使用的转换器，node配置了converter时以配置为准
+var driverConverters = map[string]string{
+	"oci8":     sqlparser.MYSQL_TO_ORACLE,
+	"dm":       sqlparser.MYSQL_TO_ORACLE,
+	"postgres": sqlparser.MYSQL_TO_POSTGRES,
+	"pgx":      sqlparser.MYSQL_TO_POSTGRES,
+	"sqlite":   sqlparser.MYSQL_TO_SQLITE,
+}
+
+// ConverterName node使用的转换器名称，不需要转换时返回空
+func ConverterName(cfg config.NodeConfig) (string, error) {
+	if cfg.Converter == "" {
+		return driverConverters[cfg.DriverName], nil
+	}
+	if !sqlparser.StringIn(cfg.Converter, sqlparser.SQLConverterNames()...) {
+		return "", fmt.Errorf("unsupported converter %s, available converters: %v", cfg.Converter, sqlparser.SQLConverterNames())
+	}
+	return cfg.Converter, nil
+}
+
 // wrapFunctions wraps the given dbQuerier with query logging functionality.
 //
 // It takes a dbQuerier and a config.NodeConfig as parameters and returns a
@@ -238,10 +322,17 @@ func (n *BackendProxy) Rollback() error {
 // that all database queries executed through it will be logged.
 func wrapFunctions(db dbQuerierWithCtx, cfg config.NodeConfig) (dbQuerierWithCtx, error) {
 	db = wrapQueryLog(db, cfg.Name)
-	switch cfg.DriverName {
-	case "oci8", "dm":
-		return wrapConverter(db, cfg.Name, cfg.DriverName, sqlparser.MYSQL_TO_ORACLE)
-	default:
-		return db, nil
+	converterName, err := ConverterName(cfg)
+	if err != nil {
+		return nil, err
+	}
+	if converterName != "" {
+		if db, err = wrapConverter(db, cfg, converterName); err != nil {
+			return nil, err
+		}
+	}
+	if cfg.DriverName == "sqlite" {
+		return wrapSQLiteResult(db), nil
 	}
+	return db, nil
 }
@@ -1,28 +1,42 @@
 package backend
 
 import (
+	"path/filepath"
 	"sqlproxy/config"
 	"testing"
 
-	_ "github.com/go-sql-driver/mysql"
 	"github.com/stretchr/testify/assert"
 )
 
 var testdb *BackendProxy
 
+// 测试使用内存中的SQLite，表结构通过中间件的DDL转换创建
+var testSchema = []string{
+	"CREATE TABLE webcal_entry (cal_id int NOT NULL AUTO_INCREMENT, cal_name varchar(80) NOT NULL DEFAULT '', PRIMARY KEY (cal_id)) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4",
+	"CREATE TABLE webcal_live_info (cal_id int NOT NULL, channelId int NOT NULL, pullurl varchar(255) NOT NULL DEFAULT '', password varchar(64) NOT NULL DEFAULT '', extraInfo text, PRIMARY KEY (cal_id), UNIQUE KEY channelId (channelId)) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4",
+	"CREATE TABLE webcal_id (type int NOT NULL, id bigint unsigned NOT NULL DEFAULT 0, PRIMARY KEY (type)) ENGINE=InnoDB",
+	"CREATE TABLE webcal_entry_recurrencerule (cal_id int NOT NULL, cal_frequency varchar(20), cal_interval int, cal_byday varchar(100), cal_bymonth varchar(50), cal_bymonthday varchar(100), cal_bysetpos varchar(50), cal_count int DEFAULT 0, cal_enddate int DEFAULT 0, PRIMARY KEY (cal_id)) ENGINE=InnoDB",
+	"INSERT INTO webcal_entry (cal_name) VALUES ('demo')",
+	"INSERT INTO webcal_id (type, id) VALUES (1, 100)",
+}
+
 func TestMain(m *testing.M) {
 	cfg := config.NodeConfig{
 		Name:         "uc_uniform",
-		DriverName:   "mysql", // "godror"
-		Datasource:   "proxy_dml:quanshitestcmnew@tcp(192.168.39.119:3306)/uc_uniform?charset=utf8mb4&readTimeout=10s&writeTimeout=10s",
+		DriverName:   "sqlite",
+		Datasource:   "file:uc_uniform?mode=memory&cache=shared",
 		MaxOpenConns: 1,
-		TestSQL:      "select * from webcal_entry limit 1;",
 	}
 	testdb = NewBackendProxy(cfg)
 	err := testdb.InitConnectionPool()
 	if err != nil {
 		panic(err)
 	}
+	for _, s := range testSchema {
+		if _, err := testdb.Exec(s); err != nil {
+			panic(err)
+		}
+	}
 	m.Run()
 }
 
@@ -68,3 +82,17 @@ func TestPrepareStatement(t *testing.T) {
 	assert.Equal(t, 1, int(rs.AffectedRows))
 	assert.True(t, rs.InsertId == 0)
 }
+
+func TestInitConnectionPoolMetadataError(t *testing.T) {
+	// 加载表结构或转换规则失败时node初始化失败，不使用未转换的连接
+	db := NewBackendProxy(config.NodeConfig{
+		Name:             "uc_broken",
+		DriverName:       "sqlite",
+		Datasource:       "file:uc_broken?mode=memory&cache=shared",
+		MaxOpenConns:     1,
+		ConvertRulesFile: filepath.Join(t.TempDir(), "missing.yaml"),
+	})
+	err := db.InitConnectionPool()
+	assert.NotNil(t, err)
+	assert.Contains(t, err.Error(), "load metadata for node uc_broken")
+}
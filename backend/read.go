@@ -43,7 +43,7 @@ func readRow(driverName string, columnTypes []*sql.ColumnType, cursor *sql.Rows)
 				values[i] = nil
 			}
 		case *sql.RawBytes:
-			values[i] = *(col.(*sql.RawBytes))
+			values[i] = copyRawBytes(*(col.(*sql.RawBytes)))
 		case *dm.DmClob:
 			bytes, err := readDmClob(col.(*dm.DmClob))
 			if err != nil {
@@ -35,7 +35,7 @@ func readRow(driverName string, columnTypes []*sql.ColumnType, cursor *sql.Rows)
 	for i, col := range cols {
 		switch col.(type) {
 		case *sql.RawBytes:
-			values[i] = *(col.(*sql.RawBytes))
+			values[i] = copyRawBytes(*(col.(*sql.RawBytes)))
 		default:
 			values[i] = nil
 		}
@@ -0,0 +1,354 @@
+package backend
+
+import (
+	"fmt"
+	"io/ioutil"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+
+	"gopkg.in/yaml.v2"
+
+	"sqlproxy/core/errors"
+	"sqlproxy/core/golog"
+	"sqlproxy/mysql"
+	"sqlproxy/sqlparser"
+)
+
+// SQLRewrite 按指纹手工指定的改写：与SQL指纹相同的语句直接替换为目标库的SQL，不再经过转换器。
+// 原语句的参数是按出现顺序排列的绑定参数和字面量，Args指定目标SQL中每个占位符对应第几个参数（从1开始），
+// 为空时按顺序传入全部参数
+type SQLRewrite struct {
+	// 只作用于指定的node，为空时作用于全部的node
+	Node string `yaml:"node,omitempty" json:"node,omitempty"`
+	// MySQL的示例语句，用于计算指纹
+	SQL string `yaml:"sql" json:"sql"`
+	// 目标库的SQL，占位符使用目标库的写法
+	Target string `yaml:"target" json:"target"`
+	Args   []int  `yaml:"args,omitempty" json:"args,omitempty"`
+
+	Fingerprint string `yaml:"-" json:"fingerprint"`
+	Md5         string `yaml:"-" json:"md5"`
+
+	// 示例语句的参数个数，in (...)和多行values在指纹中合并为?+，参数个数不同的语句不能使用同一个改写
+	params int
+}
+
+// RewriteTable 按node和指纹索引的改写表，并发安全
+type RewriteTable struct {
+	mu       sync.RWMutex
+	rewrites map[string]*SQLRewrite // node/md5 -> rewrite
+}
+
+// Rewrites 全局的改写表，由server从rewrite_sql_file加载并通过web api管理
+var Rewrites = NewRewriteTable()
+
+func NewRewriteTable() *RewriteTable {
+	return &RewriteTable{rewrites: make(map[string]*SQLRewrite)}
+}
+
+func rewriteKey(node, md5 string) string {
+	return node + "/" + md5
+}
+
+func (r *SQLRewrite) init() error {
+	r.SQL = strings.TrimSpace(r.SQL)
+	r.Target = strings.TrimSpace(r.Target)
+	if r.SQL == "" || r.Target == "" {
+		return fmt.Errorf("%w: sql and target are required", errors.ErrInvalidArgument)
+	}
+	for _, i := range r.Args {
+		if i <= 0 {
+			return fmt.Errorf("%w: args of rewrite start from 1", errors.ErrInvalidArgument)
+		}
+	}
+	params, err := queryParams(r.SQL, nil, true)
+	if err != nil {
+		return fmt.Errorf("%w: parse sql: %v", errors.ErrInvalidArgument, err)
+	}
+	r.params = len(params)
+	r.Fingerprint = mysql.GetFingerprint(r.SQL)
+	r.Md5 = mysql.GetMd5(r.Fingerprint)
+	return nil
+}
+
+// LoadSQLRewrites 从yaml文件加载改写表，文件名为空时返回空表
+func LoadSQLRewrites(fileName string) ([]*SQLRewrite, error) {
+	if fileName == "" {
+		return nil, nil
+	}
+	data, err := ioutil.ReadFile(fileName)
+	if err != nil {
+		return nil, err
+	}
+	var rewrites []*SQLRewrite
+	if err := yaml.Unmarshal(data, &rewrites); err != nil {
+		return nil, err
+	}
+	for i, r := range rewrites {
+		if err := r.init(); err != nil {
+			return nil, fmt.Errorf("rewrite %d: %v", i+1, err)
+		}
+	}
+	return rewrites, nil
+}
+
+// Reset 替换全部的改写
+func (t *RewriteTable) Reset(rewrites []*SQLRewrite) {
+	m := make(map[string]*SQLRewrite, len(rewrites))
+	for _, r := range rewrites {
+		m[rewriteKey(r.Node, r.Md5)] = r
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.rewrites = m
+}
+
+// List 按node和指纹排序的全部改写
+func (t *RewriteTable) List() []*SQLRewrite {
+	t.mu.RLock()
+	list := make([]*SQLRewrite, 0, len(t.rewrites))
+	for _, r := range t.rewrites {
+		list = append(list, r)
+	}
+	t.mu.RUnlock()
+	sort.Slice(list, func(i, j int) bool {
+		if list[i].Node != list[j].Node {
+			return list[i].Node < list[j].Node
+		}
+		return list[i].Fingerprint < list[j].Fingerprint
+	})
+	return list
+}
+
+func (t *RewriteTable) Add(r *SQLRewrite) error {
+	if err := r.init(); err != nil {
+		return err
+	}
+	key := rewriteKey(r.Node, r.Md5)
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if _, ok := t.rewrites[key]; ok {
+		return errors.ErrRewriteExist
+	}
+	t.rewrites[key] = r
+	return nil
+}
+
+func (t *RewriteTable) Delete(node, sql string) error {
+	key := rewriteKey(node, mysql.GetMd5(mysql.GetFingerprint(strings.TrimSpace(sql))))
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if _, ok := t.rewrites[key]; !ok {
+		return errors.ErrRewriteNotExist
+	}
+	delete(t.rewrites, key)
+	return nil
+}
+
+// Save 保存到yaml文件
+func (t *RewriteTable) Save(fileName string) error {
+	data, err := yaml.Marshal(t.List())
+	if err != nil {
+		return err
+	}
+	return ioutil.WriteFile(fileName, data, 0644)
+}
+
+func (t *RewriteTable) find(node, md5 string) *SQLRewrite {
+	t.mu.RLock()
+	defer t.mu.RUnlock()
+	if len(t.rewrites) == 0 {
+		return nil
+	}
+	if r, ok := t.rewrites[rewriteKey(node, md5)]; ok {
+		return r
+	}
+	return t.rewrites[rewriteKey("", md5)]
+}
+
+// Rewrite 查找node上与query指纹相同的改写，返回目标SQL和对应的参数
+func (t *RewriteTable) Rewrite(node, query string, args ...interface{}) (string, []interface{}, bool) {
+	if t.empty() {
+		return "", nil, false
+	}
+	r := t.find(node, mysql.GetMd5(mysql.GetFingerprint(query)))
+	if r == nil {
+		return "", nil, false
+	}
+	params, err := queryParams(query, args, false)
+	if err == nil {
+		err = r.checkParams(params)
+	}
+	if err != nil {
+		golog.Warn("RewriteTable", "Rewrite", err.Error(), 0, "sql", query)
+		return "", nil, false
+	}
+	newArgs, err := r.mapArgs(params)
+	if err != nil {
+		golog.Warn("RewriteTable", "Rewrite", err.Error(), 0, "sql", query)
+		return "", nil, false
+	}
+	return r.Target, newArgs, true
+}
+
+// RewritePrepare 预处理时还没有参数，绑定参数按位置对应：目标SQL的占位符必须依次对应原语句的绑定参数，
+// 执行时的参数原样传给目标SQL；需要调整顺序或者使用字面量的改写不能用于预处理
+func (t *RewriteTable) RewritePrepare(node, query string) (string, bool) {
+	if t.empty() {
+		return "", false
+	}
+	r := t.find(node, mysql.GetMd5(mysql.GetFingerprint(query)))
+	if r == nil {
+		return "", false
+	}
+	params, err := queryParams(query, nil, true)
+	if err == nil {
+		err = r.checkParams(params)
+	}
+	if err == nil {
+		var newArgs []interface{}
+		if newArgs, err = r.mapArgs(params); err == nil {
+			for i, arg := range newArgs {
+				if arg != bindArg(i+1) {
+					err = fmt.Errorf("arg %d of prepared rewrite is not bind arg %d", i+1, i+1)
+					break
+				}
+			}
+			if err == nil && len(newArgs) != countBindArgs(params) {
+				err = fmt.Errorf("prepared rewrite uses %d of %d bind args", len(newArgs), countBindArgs(params))
+			}
+		}
+	}
+	if err != nil {
+		golog.Warn("RewriteTable", "RewritePrepare", err.Error(), 0, "sql", query)
+		return "", false
+	}
+	return r.Target, true
+}
+
+// checkParams 语句的参数个数必须与示例语句相同，否则参数无法对应
+func (r *SQLRewrite) checkParams(params []interface{}) error {
+	if len(params) != r.params {
+		return fmt.Errorf("%d params, %d in the rewrite sql", len(params), r.params)
+	}
+	return nil
+}
+
+// mapArgs 按Args从原语句的参数中取出目标SQL的参数
+func (r *SQLRewrite) mapArgs(params []interface{}) ([]interface{}, error) {
+	if len(r.Args) == 0 {
+		return params, nil
+	}
+	newArgs := make([]interface{}, len(r.Args))
+	for i, n := range r.Args {
+		if n > len(params) {
+			return nil, fmt.Errorf("arg index %d out of range, %d params", n, len(params))
+		}
+		newArgs[i] = params[n-1]
+	}
+	return newArgs, nil
+}
+
+func (t *RewriteTable) empty() bool {
+	t.mu.RLock()
+	defer t.mu.RUnlock()
+	return len(t.rewrites) == 0
+}
+
+// bindArg 预处理时的绑定参数，值为绑定参数的序号（从1开始）
+type bindArg int
+
+func countBindArgs(params []interface{}) int {
+	n := 0
+	for _, p := range params {
+		if _, ok := p.(bindArg); ok {
+			n++
+		}
+	}
+	return n
+}
+
+// queryParams 按出现顺序取出语句中的绑定参数和字面量，与指纹中的?一一对应；
+// positional为true时（预处理）绑定参数取为bindArg
+func queryParams(query string, args []interface{}, positional bool) ([]interface{}, error) {
+	stmt, err := sqlparser.Parse(query)
+	if err != nil {
+		return nil, err
+	}
+	// limit n offset m解析后与limit m, n相同，语法树中offset在前，按原语句中的顺序取参数
+	offsetAfter := limitOffsetAfterRowcount(query)
+	limits := 0
+	params := []interface{}{}
+	var visit sqlparser.Visit
+	visit = func(node sqlparser.SQLNode) (bool, error) {
+		if limit, ok := node.(*sqlparser.Limit); ok && limit != nil {
+			reversed := limits < len(offsetAfter) && offsetAfter[limits]
+			limits++
+			if !reversed {
+				return true, nil
+			}
+			return false, sqlparser.Walk(visit, limit.Rowcount, limit.Offset)
+		}
+		if _, ok := node.(*sqlparser.NullVal); ok {
+			// 指纹中null也是?
+			params = append(params, nil)
+			return false, nil
+		}
+		v, ok := node.(*sqlparser.SQLVal)
+		if !ok {
+			return true, nil
+		}
+		switch v.Type {
+		case sqlparser.ValArg:
+			// 绑定参数解析后为:v1、:v2
+			n, err := strconv.Atoi(string(v.Val[2:]))
+			if err == nil && positional {
+				params = append(params, bindArg(n))
+				break
+			}
+			if err != nil || n > len(args) {
+				return false, fmt.Errorf("bind arg %s out of range", v.Val)
+			}
+			params = append(params, args[n-1])
+		case sqlparser.IntVal:
+			if i, err := strconv.ParseInt(string(v.Val), 10, 64); err == nil {
+				params = append(params, i)
+			} else {
+				params = append(params, string(v.Val))
+			}
+		case sqlparser.FloatVal:
+			if f, err := strconv.ParseFloat(string(v.Val), 64); err == nil {
+				params = append(params, f)
+			} else {
+				params = append(params, string(v.Val))
+			}
+		default:
+			params = append(params, string(v.Val))
+		}
+		return false, nil
+	}
+	err = sqlparser.Walk(visit, stmt)
+	return params, err
+}
+
+// limitOffsetAfterRowcount 按出现顺序返回语句中的每个limit是否为limit n offset m的写法，
+// 遍历语法树时limit节点的顺序与原语句中一致
+func limitOffsetAfterRowcount(query string) []bool {
+	tokenizer := sqlparser.NewStringTokenizer(query)
+	limits := []bool{}
+	for {
+		typ, _ := tokenizer.Scan()
+		switch typ {
+		case 0, sqlparser.LEX_ERROR:
+			return limits
+		case sqlparser.LIMIT:
+			limits = append(limits, false)
+		case sqlparser.OFFSET:
+			if len(limits) > 0 {
+				limits[len(limits)-1] = true
+			}
+		}
+	}
+}
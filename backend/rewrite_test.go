@@ -0,0 +1,140 @@
+package backend
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"sqlproxy/core/errors"
+)
+
+func TestRewriteTable(t *testing.T) {
+	table := NewRewriteTable()
+	assert.Nil(t, table.Add(&SQLRewrite{
+		SQL:    "select a from t1 where b = 1 and c = 'x' limit 10",
+		Target: "select a from t1_view where c = ? and b = ? and rownum <= ?",
+		Args:   []int{2, 1, 3},
+	}))
+	assert.Nil(t, table.Add(&SQLRewrite{
+		Node:   "demodb",
+		SQL:    "select a from t1 where b = 1 and c = 'x' limit 10",
+		Target: "select a from t1 where b = ? and c = ?",
+		Args:   []int{1, 2},
+	}))
+	assert.Equal(t, errors.ErrRewriteExist, table.Add(&SQLRewrite{SQL: "SELECT a FROM t1 WHERE b = 2 AND c = 'y' LIMIT 5", Target: "x"}))
+	assert.NotNil(t, table.Add(&SQLRewrite{SQL: "select 1", Target: "select 1 from dual", Args: []int{0}}))
+
+	target, args, ok := table.Rewrite("other", "select a from t1 where b = ? and c = 'y' limit 20", 7)
+	assert.True(t, ok)
+	assert.Equal(t, "select a from t1_view where c = ? and b = ? and rownum <= ?", target)
+	assert.Equal(t, []interface{}{"y", 7, int64(20)}, args)
+
+	target, args, ok = table.Rewrite("demodb", "select a from t1 where b = ? and c = ? limit ?", 7, "z", 1)
+	assert.True(t, ok)
+	assert.Equal(t, "select a from t1 where b = ? and c = ?", target)
+	assert.Equal(t, []interface{}{7, "z"}, args)
+
+	_, _, ok = table.Rewrite("demodb", "select a from t1 where b = ?", 7)
+	assert.False(t, ok)
+
+	// limit n offset m与limit m, n的指纹不同，参数按原语句中的顺序
+	assert.Nil(t, table.Add(&SQLRewrite{
+		SQL:    "select a from t1 order by a limit 10 offset 20",
+		Target: "select a from t1 order by a offset ? rows fetch next ? rows only",
+		Args:   []int{2, 1},
+	}))
+	target, args, ok = table.Rewrite("", "select a from t1 order by a limit ? offset 5", 10)
+	assert.True(t, ok)
+	assert.Equal(t, "select a from t1 order by a offset ? rows fetch next ? rows only", target)
+	assert.Equal(t, []interface{}{int64(5), 10}, args)
+
+	// 预处理时绑定参数按位置对应，调整了顺序的改写不能用于预处理
+	assert.Nil(t, table.Add(&SQLRewrite{
+		SQL:    "select a from t2 where b = ? and c = ?",
+		Target: "select a from t2_view where b = ? and c = ?",
+	}))
+	target, ok = table.RewritePrepare("", "select a from t2 where b = ? and c = ?")
+	assert.True(t, ok)
+	assert.Equal(t, "select a from t2_view where b = ? and c = ?", target)
+	_, ok = table.RewritePrepare("", "select a from t1 order by a limit ? offset ?")
+	assert.False(t, ok)
+	_, ok = table.RewritePrepare("demodb", "select a from t1 where b = ? and c = 'x' limit 10")
+	assert.False(t, ok)
+
+	// in (...)在指纹中合并为?+，参数个数与示例语句不同时不改写；null也是参数
+	assert.Nil(t, table.Add(&SQLRewrite{
+		SQL:    "select a from t3 where b in (1, 2) and c = null",
+		Target: "select a from t3 where b in (?, ?) and c is null",
+		Args:   []int{1, 2},
+	}))
+	target, args, ok = table.Rewrite("", "select a from t3 where b in (?, ?) and c = null", 5, 6)
+	assert.True(t, ok)
+	assert.Equal(t, "select a from t3 where b in (?, ?) and c is null", target)
+	assert.Equal(t, []interface{}{5, 6}, args)
+	_, _, ok = table.Rewrite("", "select a from t3 where b in (?, ?, ?) and c = null", 5, 6, 7)
+	assert.False(t, ok)
+	_, _, ok = table.Rewrite("", "select a from t3 where b in (5) and c = null")
+	assert.False(t, ok)
+
+	file := filepath.Join(t.TempDir(), "rewrites.yaml")
+	assert.Nil(t, table.Save(file))
+	rewrites, err := LoadSQLRewrites(file)
+	assert.Nil(t, err)
+	assert.Equal(t, table.List(), rewrites)
+
+	assert.Nil(t, table.Delete("demodb", "select a from t1 where b = 3 and c = 'z' limit 1"))
+	assert.Equal(t, errors.ErrRewriteNotExist, table.Delete("demodb", "select a from t1 where b = 3 and c = 'z' limit 1"))
+	assert.Len(t, table.List(), 4)
+}
+
+func TestRewriteConvert(t *testing.T) {
+	file := filepath.Join(t.TempDir(), "rewrites.yaml")
+	assert.Nil(t, os.WriteFile(file, []byte(`
+- node: uc_uniform
+  sql: select cal_name from webcal_entry where cal_id = ?
+  target: select upper(cal_name) from webcal_entry where cal_id = ?1
+`), 0644))
+	rewrites, err := LoadSQLRewrites(file)
+	assert.Nil(t, err)
+	Rewrites.Reset(rewrites)
+	defer Rewrites.Reset(nil)
+
+	rs, err := testdb.Query("select cal_name from webcal_entry where cal_id = 1")
+	assert.Nil(t, err)
+	name, err := rs.GetString(0, 0)
+	assert.Nil(t, err)
+	assert.Equal(t, "DEMO", name)
+
+	stmt, err := testdb.db.Prepare("select cal_name from webcal_entry where cal_id = ?")
+	assert.Nil(t, err)
+	defer stmt.Close()
+	assert.Nil(t, stmt.QueryRow(1).Scan(&name))
+	assert.Equal(t, "DEMO", name)
+}
+
+func TestRewriteDDLReloadMetadata(t *testing.T) {
+	file := filepath.Join(t.TempDir(), "rewrites.yaml")
+	assert.Nil(t, os.WriteFile(file, []byte(`
+- node: uc_uniform
+  sql: create table webcal_rewrite (id int)
+  target: create table webcal_rewrite (id integer, name text)
+`), 0644))
+	rewrites, err := LoadSQLRewrites(file)
+	assert.Nil(t, err)
+	Rewrites.Reset(rewrites)
+	defer Rewrites.Reset(nil)
+
+	_, err = testdb.Exec("create table webcal_rewrite (id int)")
+	assert.Nil(t, err)
+	dump, err := testdb.DumpMetadata()
+	assert.Nil(t, err)
+	var columns []string
+	for _, table := range dump.Tables {
+		if table.Name == "webcal_rewrite" {
+			columns = table.Columns
+		}
+	}
+	assert.Equal(t, []string{"id", "name"}, columns)
+}
@@ -0,0 +1,71 @@
+package backend
+
+import (
+	"database/sql"
+
+	"sqlproxy/mysql"
+)
+
+// Rows 逐行读取的后端结果集，不缓存已读的行，读完或出错后需要Close释放连接
+type Rows struct {
+	cursor      *sql.Rows
+	driverName  string
+	columnTypes []*sql.ColumnType
+}
+
+// QueryRows 执行查询并返回逐行读取的结果集，用于将大结果集直接流式写给客户端
+func (n *BackendProxy) QueryRows(query string, args ...interface{}) (*Rows, error) {
+	if n.db == nil {
+		return nil, ErrDbNullPointer
+	}
+	cursor, err := n.db.Query(query, args...)
+	if err != nil {
+		return nil, err
+	}
+	columnTypes, err := cursor.ColumnTypes()
+	if err != nil {
+		cursor.Close()
+		return nil, err
+	}
+	return &Rows{
+		cursor:      cursor,
+		driverName:  n.cfg.DriverName,
+		columnTypes: columnTypes,
+	}, nil
+}
+
+func (r *Rows) ColumnTypes() []*sql.ColumnType {
+	return r.columnTypes
+}
+
+// Fields 结果集的列定义；SQLite的整数都按64位存储，声明的TINYINT等宽度不限制取值范围，统一按BIGINT返回
+func (r *Rows) Fields() []*mysql.Field {
+	fields := mysql.BuildFields(r.driverName, r.columnTypes)
+	if r.driverName == "sqlite" {
+		for _, f := range fields {
+			switch f.Type {
+			case mysql.MYSQL_TYPE_TINY, mysql.MYSQL_TYPE_SHORT, mysql.MYSQL_TYPE_INT24, mysql.MYSQL_TYPE_LONG:
+				f.Type, f.ColumnLength = mysql.MYSQL_TYPE_LONGLONG, 20
+			}
+		}
+	}
+	return fields
+}
+
+func (r *Rows) Next() bool {
+	return r.cursor.Next()
+}
+
+// Row 当前行的值，null为nil
+func (r *Rows) Row() ([]sql.RawBytes, error) {
+	return readRow(r.driverName, r.columnTypes, r.cursor)
+}
+
+// Err 遍历过程中的错误
+func (r *Rows) Err() error {
+	return r.cursor.Err()
+}
+
+func (r *Rows) Close() error {
+	return r.cursor.Close()
+}
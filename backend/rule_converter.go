@@ -0,0 +1,180 @@
+package backend
+
+import (
+	"fmt"
+	"io/ioutil"
+	"reflect"
+	"regexp"
+
+	"gopkg.in/yaml.v2"
+
+	"sqlproxy/core/golog"
+	"sqlproxy/mysql"
+	"sqlproxy/sqlparser"
+)
+
+// ConvertRule 自定义的转换规则，用于不重新编译中间件修正个别不兼容的SQL：
+//   - 只配置fingerprint：指纹相同的SQL整条替换为replace，原SQL中的字面量不会保留，适合使用绑定参数的SQL
+//   - 配置node和pattern：语法树中类型为node、文本匹配pattern（正则）的节点替换为replace，
+//     replace中可以用$1引用pattern中的分组，同时配置fingerprint时只作用于指纹相同的SQL
+//
+// 规则的输入输出都是MySQL语法，替换后的SQL再交给转换器转换，绑定参数用?表示，替换前后参数的个数和顺序需要一致
+type ConvertRule struct {
+	Name string `yaml:"name"`
+	// SQL的指纹，也可以直接写一条示例SQL，加载时计算指纹
+	Fingerprint string `yaml:"fingerprint"`
+	// 语法树节点的类型名，例如FuncExpr、ComparisonExpr、AliasedTableExpr
+	Node    string `yaml:"node"`
+	Pattern string `yaml:"pattern"`
+	Replace string `yaml:"replace"`
+
+	md5     string
+	pattern *regexp.Regexp
+}
+
+type convertRules struct {
+	Rules []*ConvertRule `yaml:"rules"`
+}
+
+// LoadConvertRules 从yaml文件加载转换规则
+func LoadConvertRules(fileName string) ([]*ConvertRule, error) {
+	data, err := ioutil.ReadFile(fileName)
+	if err != nil {
+		return nil, err
+	}
+	var cfg convertRules
+	if err := yaml.Unmarshal(data, &cfg); err != nil {
+		return nil, err
+	}
+	for i, rule := range cfg.Rules {
+		if err := rule.compile(); err != nil {
+			return nil, fmt.Errorf("convert rule %d(%s): %v", i+1, rule.Name, err)
+		}
+	}
+	return cfg.Rules, nil
+}
+
+func (r *ConvertRule) compile() error {
+	if r.Fingerprint != "" {
+		r.md5 = mysql.GetMd5(mysql.GetFingerprint(r.Fingerprint))
+	}
+	if r.Node == "" {
+		if r.Fingerprint == "" {
+			return fmt.Errorf("fingerprint or node is required")
+		}
+		return nil
+	}
+	if r.Pattern == "" {
+		return fmt.Errorf("pattern is required for node %s", r.Node)
+	}
+	var err error
+	r.pattern, err = regexp.Compile(r.Pattern)
+	return err
+}
+
+// RuleConverter 先按自定义规则改写SQL，再交给next转换
+type RuleConverter struct {
+	rules []*ConvertRule
+	next  sqlparser.SQLConverter
+}
+
+var _ sqlparser.DDLConverter = new(RuleConverter)
+
+func NewRuleConverter(rules []*ConvertRule, next sqlparser.SQLConverter) *RuleConverter {
+	return &RuleConverter{
+		rules: rules,
+		next:  next,
+	}
+}
+
+func (c *RuleConverter) Convert(sql string, args ...interface{}) (string, []interface{}, error) {
+	sql, _ = c.rewrite(sql)
+	return c.next.Convert(sql, args...)
+}
+
+// ConvertWithRules 同Convert，另外返回匹配的规则名称，用于转换日志
+func (c *RuleConverter) ConvertWithRules(sql string, args ...interface{}) (string, []interface{}, []string, error) {
+	sql, rules := c.rewrite(sql)
+	sql, newArgs, err := c.next.Convert(sql, args...)
+	return sql, newArgs, rules, err
+}
+
+// ConvertDDL next不支持转换DDL时按改写后的SQL执行
+func (c *RuleConverter) ConvertDDL(sql string) ([]string, error) {
+	sql, _ = c.rewrite(sql)
+	if converter, ok := c.next.(sqlparser.DDLConverter); ok {
+		return converter.ConvertDDL(sql)
+	}
+	return []string{sql}, nil
+}
+
+// rewrite 按规则改写SQL，返回改写后的SQL和匹配的规则名称，没有匹配的规则时返回原始SQL
+func (c *RuleConverter) rewrite(sql string) (string, []string) {
+	md5 := mysql.GetMd5(mysql.GetFingerprint(sql))
+	var nodeRules []*ConvertRule
+	for _, rule := range c.rules {
+		if rule.md5 != "" && rule.md5 != md5 {
+			continue
+		}
+		if rule.pattern == nil {
+			golog.Info("RuleConverter", "rewrite", "replace by fingerprint", 0, "rule", rule.Name, "sql", sql)
+			return rule.Replace, []string{rule.Name}
+		}
+		nodeRules = append(nodeRules, rule)
+	}
+	if len(nodeRules) == 0 {
+		return sql, nil
+	}
+
+	stmt, err := sqlparser.Parse(sql)
+	if err != nil {
+		return sql, nil
+	}
+	var matched []string
+	format := func(buf *sqlparser.TrackedBuffer, node sqlparser.SQLNode) {
+		if replaced, rule, ok := rewriteNode(nodeRules, node); ok {
+			if !sqlparser.StringIn(rule, matched...) {
+				matched = append(matched, rule)
+			}
+			buf.WriteString(replaced)
+			return
+		}
+		formatMySQLNode(buf, node)
+	}
+	rewritten := sqlparser.NewTrackedBuffer(format).WriteNode(stmt).String()
+	if len(matched) == 0 {
+		return sql, nil
+	}
+	golog.Info("RuleConverter", "rewrite", "replace by node", 0, "sql", sql, "rewritten", rewritten)
+	return rewritten, matched
+}
+
+// rewriteNode 用第一条匹配的规则替换节点，返回替换后的文本和规则名称
+func rewriteNode(rules []*ConvertRule, node sqlparser.SQLNode) (string, string, bool) {
+	if node == nil || reflect.ValueOf(node).Kind() == reflect.Ptr && reflect.ValueOf(node).IsNil() {
+		return "", "", false
+	}
+	nodeType := reflect.Indirect(reflect.ValueOf(node)).Type().Name()
+	var text string
+	for _, rule := range rules {
+		if rule.Node != nodeType {
+			continue
+		}
+		if text == "" {
+			text = sqlparser.NewTrackedBuffer(formatMySQLNode).WriteNode(node).String()
+		}
+		if rule.pattern.MatchString(text) {
+			return rule.pattern.ReplaceAllString(text, rule.Replace), rule.Name, true
+		}
+	}
+	return "", "", false
+}
+
+// formatMySQLNode 输出MySQL语法，绑定参数输出为?
+func formatMySQLNode(buf *sqlparser.TrackedBuffer, node sqlparser.SQLNode) {
+	if v, ok := node.(*sqlparser.SQLVal); ok && v.Type == sqlparser.ValArg {
+		buf.WriteString("?")
+		return
+	}
+	node.Format(buf)
+}
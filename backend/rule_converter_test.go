@@ -0,0 +1,81 @@
+package backend
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"sqlproxy/sqlparser"
+)
+
+var testConvertRules = `
+rules:
+  - name: report
+    fingerprint: select name, count(*) from report where day > 20200101 group by name
+    replace: select name, sum(cnt) from report_daily where day > 20200101 group by name
+  - name: isnull
+    node: FuncExpr
+    pattern: ^isnull\((.+)\)$
+    replace: ($1 is null)
+  - name: drop_hint
+    fingerprint: select * from t1 use index (idx_a) where a = ?
+    node: AliasedTableExpr
+    pattern: ^t1 .*$
+    replace: t1
+`
+
+func TestRuleConverter(t *testing.T) {
+	file := filepath.Join(t.TempDir(), "rules.yaml")
+	assert.Nil(t, os.WriteFile(file, []byte(testConvertRules), 0644))
+	rules, err := LoadConvertRules(file)
+	assert.Nil(t, err)
+	assert.Len(t, rules, 3)
+
+	converter := NewRuleConverter(rules, sqlparser.NewSQLiteConverter(nil, nil, nil))
+	testCases := []struct {
+		in, out string
+		args    []interface{}
+	}{
+		{
+			in:  "SELECT name, count(*) FROM report WHERE day > 20211001 GROUP BY name",
+			out: "select `name`, sum(`cnt`) from `report_daily` where `day` > 20200101 group by `name`",
+		},
+		{
+			in:   "select a from t2 where isnull(b) and c = ?",
+			out:  "select `a` from `t2` where (`b` is null) and `c` = ?1",
+			args: []interface{}{1},
+		},
+		{
+			in:   "select * from t1 use index (idx_a) where a = ?",
+			out:  "select * from `t1` where `a` = ?1",
+			args: []interface{}{1},
+		},
+		{
+			in:  "select * from t1 use index (idx_b) where b = 1",
+			out: "select * from `t1` where `b` = 1",
+		},
+	}
+	for _, tcase := range testCases {
+		sql, args, err := converter.Convert(tcase.in, tcase.args...)
+		assert.Nil(t, err)
+		assert.Equal(t, tcase.out, sql)
+		assert.Equal(t, tcase.args, args)
+	}
+
+	_, _, matched, err := converter.ConvertWithRules("select isnull(a), isnull(b) from t2")
+	assert.Nil(t, err)
+	assert.Equal(t, []string{"isnull"}, matched)
+
+	stmts, err := converter.ConvertDDL("create table t3 (id int)")
+	assert.Nil(t, err)
+	assert.Equal(t, []string{"create table `t3` (`id` int)"}, stmts)
+}
+
+func TestLoadConvertRulesError(t *testing.T) {
+	file := filepath.Join(t.TempDir(), "rules.yaml")
+	assert.Nil(t, os.WriteFile(file, []byte("rules:\n  - name: bad\n    node: FuncExpr\n    pattern: '('\n"), 0644))
+	_, err := LoadConvertRules(file)
+	assert.NotNil(t, err)
+}
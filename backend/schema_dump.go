@@ -0,0 +1,132 @@
+package backend
+
+import (
+	"fmt"
+	"io/ioutil"
+	"sort"
+	"strings"
+
+	"gopkg.in/yaml.v2"
+
+	"sqlproxy/config"
+	"sqlproxy/sqlparser"
+)
+
+// SchemaDump 表结构信息的yaml格式，可以通过web api从node导出，用于离线转换
+type SchemaDump struct {
+	Tables []*TableDump `yaml:"tables"`
+}
+
+type TableDump struct {
+	Name             string              `yaml:"name"`
+	Columns          []string            `yaml:"columns,flow"`
+	IncrementColumns []string            `yaml:"increment_columns,flow,omitempty"`
+	UniqueIndexs     map[string][]string `yaml:"unique_indexs,omitempty"`
+}
+
+// HasTable 是否有表的结构信息，表名不区分大小写
+func (d *SchemaDump) HasTable(name string) bool {
+	if d == nil {
+		return false
+	}
+	for _, table := range d.Tables {
+		if strings.EqualFold(table.Name, name) {
+			return true
+		}
+	}
+	return false
+}
+
+// LoadSchemaDump 从yaml文件加载表结构信息
+func LoadSchemaDump(fileName string) (*SchemaDump, error) {
+	data, err := ioutil.ReadFile(fileName)
+	if err != nil {
+		return nil, err
+	}
+	var dump SchemaDump
+	if err := yaml.Unmarshal(data, &dump); err != nil {
+		return nil, err
+	}
+	return &dump, nil
+}
+
+func (d *SchemaDump) metadata() *tableMetadata {
+	meta := &tableMetadata{
+		tableUniqueIndexs: map[string]map[string][]string{},
+		tableColumns:      map[string][]string{},
+		incrementColumns:  map[string]map[string]int{},
+	}
+	if d == nil {
+		return meta
+	}
+	for _, table := range d.Tables {
+		meta.tableColumns[table.Name] = table.Columns
+		if len(table.UniqueIndexs) > 0 {
+			meta.tableUniqueIndexs[table.Name] = table.UniqueIndexs
+		}
+		for _, column := range table.IncrementColumns {
+			if meta.incrementColumns[table.Name] == nil {
+				meta.incrementColumns[table.Name] = make(map[string]int)
+			}
+			meta.incrementColumns[table.Name][column] = columnIndex(table.Columns, column)
+		}
+	}
+	return meta
+}
+
+// columnIndex 列的序号，从1开始
+func columnIndex(columns []string, column string) int {
+	for i, c := range columns {
+		if c == column {
+			return i + 1
+		}
+	}
+	return 0
+}
+
+// Dump 导出当前的表结构信息，按表名排序
+func (c *MetadataCache) Dump() *SchemaDump {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	dump := &SchemaDump{}
+	for name, columns := range c.meta.tableColumns {
+		table := &TableDump{
+			Name:         name,
+			Columns:      columns,
+			UniqueIndexs: c.meta.tableUniqueIndexs[name],
+		}
+		for _, column := range columns {
+			if _, ok := c.meta.incrementColumns[name][column]; ok {
+				table.IncrementColumns = append(table.IncrementColumns, column)
+			}
+		}
+		dump.Tables = append(dump.Tables, table)
+	}
+	sort.Slice(dump.Tables, func(i, j int) bool {
+		return dump.Tables[i].Name < dump.Tables[j].Name
+	})
+	return dump
+}
+
+// NewOfflineConverter 不连接目标库，按node的配置和导出的表结构信息创建转换器
+func NewOfflineConverter(cfg config.NodeConfig, dump *SchemaDump) (sqlparser.SQLConverter, error) {
+	converterName, err := ConverterName(cfg)
+	if err != nil {
+		return nil, err
+	}
+	if converterName == "" {
+		return nil, fmt.Errorf("no converter for driver %s", cfg.DriverName)
+	}
+	var rules []*ConvertRule
+	if cfg.ConvertRulesFile != "" {
+		if rules, err = LoadConvertRules(cfg.ConvertRulesFile); err != nil {
+			return nil, err
+		}
+	}
+	opts := sqlparser.ConvertOptions{
+		Pagination:     cfg.Pagination,
+		UpperCaseIdent: cfg.UpperCaseIdent,
+		DriverName:     cfg.DriverName,
+	}
+	return newConverter(converterName, dump.metadata().normalizeKeys(opts), opts, rules)
+}
@@ -0,0 +1,63 @@
+package backend
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"gopkg.in/yaml.v2"
+
+	"sqlproxy/config"
+)
+
+func TestSchemaDump(t *testing.T) {
+	dump, err := testdb.DumpMetadata()
+	assert.Nil(t, err)
+	var entry *TableDump
+	for _, table := range dump.Tables {
+		if table.Name == "webcal_entry" {
+			entry = table
+		}
+	}
+	assert.Equal(t, &TableDump{
+		Name:             "webcal_entry",
+		Columns:          []string{"cal_id", "cal_name"},
+		IncrementColumns: []string{"cal_id"},
+	}, entry)
+
+	data, err := yaml.Marshal(dump)
+	assert.Nil(t, err)
+	file := filepath.Join(t.TempDir(), "schema.yaml")
+	assert.Nil(t, os.WriteFile(file, data, 0644))
+	loaded, err := LoadSchemaDump(file)
+	assert.Nil(t, err)
+	assert.Equal(t, dump, loaded)
+
+	converter, err := NewOfflineConverter(config.NodeConfig{DriverName: "sqlite"}, loaded)
+	assert.Nil(t, err)
+	sql, _, err := converter.Convert("insert into webcal_entry values (0, 'x')")
+	assert.Nil(t, err)
+	assert.Equal(t, "insert into `webcal_entry`(`cal_id`, `cal_name`) values (null, 'x')", sql)
+
+	_, err = NewOfflineConverter(config.NodeConfig{DriverName: "mysql"}, nil)
+	assert.NotNil(t, err)
+}
+
+func TestSchemaDumpUpperCase(t *testing.T) {
+	// Oracle数据字典中的表名和列名为大写
+	dump := &SchemaDump{Tables: []*TableDump{{
+		Name:             "WEBCAL_ENTRY",
+		Columns:          []string{"CAL_ID", "CAL_NAME"},
+		IncrementColumns: []string{"CAL_ID"},
+		UniqueIndexs:     map[string][]string{"SYS_C0011": {"CAL_NAME"}},
+	}}}
+	converter, err := NewOfflineConverter(config.NodeConfig{DriverName: "oci8", UpperCaseIdent: true}, dump)
+	assert.Nil(t, err)
+	sql, _, err := converter.Convert("insert into webcal_entry values (1, 'x')")
+	assert.Nil(t, err)
+	assert.Equal(t, `insert into "WEBCAL_ENTRY"("CAL_NAME") values ('x')`, sql)
+	sql, _, err = converter.Convert("replace into webcal_entry(cal_name) values ('x')")
+	assert.Nil(t, err)
+	assert.Equal(t, `merge into "WEBCAL_ENTRY" "T" using (select 'x' as "CAL_NAME" from dual) "S" on ("T"."CAL_NAME" = "S"."CAL_NAME") when not matched then insert ("CAL_NAME") values ("S"."CAL_NAME")`, sql)
+}
@@ -0,0 +1,111 @@
+package backend
+
+import (
+	"database/sql"
+	"math"
+	"strconv"
+
+	"sqlproxy/sqlparser"
+)
+
+// sqliteResultPlugin SQLite的LastInsertId返回连接上最后插入的rowid，update、insert ignore忽略的行
+// 以及没有自增列的表都会返回非0的值，按MySQL的规则只在插入了带自增列的表时返回；
+// 另外SQLite的整数是有符号64位的，超出范围的uint64参数转为字符串传入
+type sqliteResultPlugin struct {
+	Context
+	db dbQuerierWithCtx
+}
+
+var _ SQLPlugin = new(sqliteResultPlugin)
+
+func (d *sqliteResultPlugin) Prepare(query string) (*sql.Stmt, error) {
+	return d.db.Prepare(query)
+}
+
+func (d *sqliteResultPlugin) Exec(query string, args ...interface{}) (sql.Result, error) {
+	res, err := d.db.Exec(query, sqliteArgs(args)...)
+	if err != nil {
+		return nil, err
+	}
+	affected, err := res.RowsAffected()
+	if err != nil {
+		return nil, err
+	}
+	if affected == 0 || !d.hasIncrementColumn(query) {
+		return sqliteResult{res}, nil
+	}
+	return res, nil
+}
+
+// hasIncrementColumn 语句是否插入了带自增列的表
+func (d *sqliteResultPlugin) hasIncrementColumn(query string) bool {
+	switch sqlparser.Preview(query) {
+	case sqlparser.StmtInsert, sqlparser.StmtReplace:
+	default:
+		return false
+	}
+	metadata, ok := d.GetContext().Value(CTX_KEY_METADATA).(*MetadataCache)
+	if !ok {
+		return true
+	}
+	stmt, err := sqlparser.Parse(query)
+	if err != nil {
+		return true
+	}
+	insert, ok := stmt.(*sqlparser.Insert)
+	if !ok {
+		return false
+	}
+	return metadata.HasIncrementColumn(insert.Table.Name.String())
+}
+
+func (d *sqliteResultPlugin) Query(query string, args ...interface{}) (*sql.Rows, error) {
+	return d.db.Query(query, sqliteArgs(args)...)
+}
+
+func (d *sqliteResultPlugin) QueryRow(query string, args ...interface{}) *sql.Row {
+	return d.db.QueryRow(query, sqliteArgs(args)...)
+}
+
+func (d *sqliteResultPlugin) Begin() (*sql.Tx, error) {
+	return dbQuerierToTxer(d.db).Begin()
+}
+
+func (d *sqliteResultPlugin) Commit() error {
+	return dbQuerierToTxEnder(d.db).Commit()
+}
+
+func (d *sqliteResultPlugin) Rollback() error {
+	return dbQuerierToTxEnder(d.db).Rollback()
+}
+
+// sqliteArgs database/sql不支持最高位为1的uint64参数，转为字符串由SQLite按列的类型亲和性处理
+func sqliteArgs(args []interface{}) []interface{} {
+	var converted []interface{}
+	for i, arg := range args {
+		if v, ok := arg.(uint64); ok && v > math.MaxInt64 {
+			if converted == nil {
+				converted = make([]interface{}, len(args))
+				copy(converted, args)
+			}
+			converted[i] = strconv.FormatUint(v, 10)
+		}
+	}
+	if converted == nil {
+		return args
+	}
+	return converted
+}
+
+// sqliteResult LastInsertId固定返回0
+type sqliteResult struct {
+	sql.Result
+}
+
+func (sqliteResult) LastInsertId() (int64, error) { return 0, nil }
+
+func wrapSQLiteResult(db dbQuerierWithCtx) SQLPlugin {
+	d := &sqliteResultPlugin{db: db}
+	d.WithContext(db.GetContext())
+	return d
+}
@@ -37,11 +37,21 @@ type Config struct {
 	LogLevel    string       `yaml:"log_level"`
 	LogSql      string       `yaml:"log_sql"`
 	SlowLogTime int          `yaml:"slow_log_time"`
+	ConvertLog  string       `yaml:"convert_log"`
 	AllowIps    string       `yaml:"allow_ips"`
 	BlsFile     string       `yaml:"blacklist_sql_file"`
+	RewriteFile string       `yaml:"rewrite_sql_file"`
 	Charset     string       `yaml:"proxy_charset"`
 	Nodes       []NodeConfig `yaml:"nodes"`
 
+	// 防火墙模式[off|learning|detect|enforce]及学习到的指纹保存的文件
+	FirewallMode string `yaml:"firewall_mode"`
+	FirewallFile string `yaml:"firewall_file"`
+
+	// 单个查询返回给客户端的最大行数和字节数，超过时中止并返回错误，0表示不限制
+	MaxResultRows int64 `yaml:"max_result_rows"`
+	MaxResultSize int64 `yaml:"max_result_size"`
+
 	SchemaList []SchemaConfig `yaml:"schema_list"`
 }
 
@@ -53,12 +63,29 @@ type UserConfig struct {
 
 // node节点对应的配置
 type NodeConfig struct {
-	Name         string `yaml:"name"`
-	DriverName   string `yaml:"driver_name"`
-	Datasource   string `yaml:"datasource"`
-	MaxOpenConns int    `yaml:"max_conns_limit"`
-	MaxLifeTime  int    `yaml:"max_life_time"`
-	TestSQL      string `yaml:"test_sql"`
+	Name           string `yaml:"name"`
+	DriverName     string `yaml:"driver_name"`
+	Datasource     string `yaml:"datasource"`
+	MaxOpenConns   int    `yaml:"max_conns_limit"`
+	MaxLifeTime    int    `yaml:"max_life_time"`
+	TestSQL        string `yaml:"test_sql"`
+	Pagination     string `yaml:"pagination"`
+	UpperCaseIdent bool   `yaml:"upper_case_ident"`
+	// SQL转换器的名称，为空时根据driver_name选择内置的转换器
+	Converter string `yaml:"converter"`
+	// 自定义转换规则文件，规则在转换器之前执行，重新加载表结构时一起重新加载
+	ConvertRulesFile string `yaml:"convert_rules_file"`
+	// 表结构信息的刷新间隔，单位秒，0表示只在启动、DDL后及手动刷新时加载
+	MetadataRefreshInterval int `yaml:"metadata_refresh_interval"`
+	// 转换结果缓存的条数，0表示使用默认值，小于0表示不缓存
+	ConvertCacheSize int `yaml:"convert_cache_size"`
+	// 没有绑定参数的语句再按归一化（字面量替换为绑定参数）后的SQL缓存转换结果；
+	// 字符串字面量变为varchar参数后，与Oracle、达梦的CHAR列比较时不再补空格，默认关闭
+	ConvertCacheNormalize bool `yaml:"convert_cache_normalize"`
+	// 转换失败时返回错误给客户端，而不是使用原始SQL执行
+	ConvertStrict bool `yaml:"convert_strict"`
+	// 查询表结构的schema（达梦、Oracle为owner），为空时达梦、Oracle使用name，PostgreSQL使用连接的current_schema()
+	MetadataSchema string `yaml:"metadata_schema"`
 }
 
 // schema对应的结构体
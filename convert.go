@@ -0,0 +1,147 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+
+	"sqlproxy/backend"
+	"sqlproxy/config"
+	"sqlproxy/convert"
+	"sqlproxy/core/golog"
+)
+
+const convertUsage = `usage: sqlproxy convert [flags] [file ...]
+
+Convert the MySQL statements in the files (or stdin) offline and report the
+result grouped by fingerprint. The input can be a sql file, the sql.log of
+sqlproxy, or a MySQL general/slow log.
+
+flags:
+`
+
+// runConvert sqlproxy convert子命令，不连接数据库，使用导出的表结构信息转换
+func runConvert(args []string) int {
+	fs := flag.NewFlagSet("convert", flag.ContinueOnError)
+	fs.Usage = func() {
+		fmt.Fprint(fs.Output(), convertUsage)
+		fs.PrintDefaults()
+	}
+	configFile := fs.String("config", "", "sqlproxy config file, use the converter settings of -node, flags set explicitly take precedence")
+	nodeName := fs.String("node", "", "the node name in the config file")
+	driver := fs.String("driver", "dm", "the driver name of the target db, used to choose the converter")
+	converter := fs.String("converter", "", "the converter name, default is chosen by -driver")
+	pagination := fs.String("pagination", "", "how to translate limit for oracle-like db [offset_fetch|rownum]")
+	upperCaseIdent := fs.Bool("upper-case-ident", false, "quote table and column names in upper case")
+	rulesFile := fs.String("rules", "", "the convert rules file")
+	schemaFile := fs.String("schema", "", "the yaml schema dump, GET /api/v1/nodes/metadata?node=<node>")
+	format := fs.String("format", convert.FormatAuto, "input format [auto|sql|sqllog|general|slow]")
+	output := fs.String("output", "text", "output format [text|json]")
+	level := fs.String("log-level", "error", "log level [debug|info|warn|error]")
+	if err := fs.Parse(args); err != nil {
+		return 2
+	}
+
+	handler, _ := golog.NewStreamHandler(os.Stderr)
+	golog.GlobalSysLogger = golog.New(handler, golog.Ltime|golog.Llevel)
+	setLogLevel(*level)
+
+	cfg := config.NodeConfig{
+		DriverName:       *driver,
+		Converter:        *converter,
+		Pagination:       *pagination,
+		UpperCaseIdent:   *upperCaseIdent,
+		ConvertRulesFile: *rulesFile,
+	}
+	if *configFile != "" {
+		proxyCfg, err := config.ParseConfigFile(*configFile)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "parse config file error: %v\n", err)
+			return 1
+		}
+		found := false
+		for _, node := range proxyCfg.Nodes {
+			if node.Name == *nodeName {
+				cfg, found = node, true
+				break
+			}
+		}
+		if !found {
+			fmt.Fprintf(os.Stderr, "node `%s` isn't exist in %s\n", *nodeName, *configFile)
+			return 1
+		}
+		// 命令行中明确指定的参数覆盖node的配置
+		fs.Visit(func(f *flag.Flag) {
+			switch f.Name {
+			case "driver":
+				cfg.DriverName = *driver
+			case "converter":
+				cfg.Converter = *converter
+			case "pagination":
+				cfg.Pagination = *pagination
+			case "upper-case-ident":
+				cfg.UpperCaseIdent = *upperCaseIdent
+			case "rules":
+				cfg.ConvertRulesFile = *rulesFile
+			}
+		})
+	}
+
+	var dump *backend.SchemaDump
+	if *schemaFile != "" {
+		var err error
+		if dump, err = backend.LoadSchemaDump(*schemaFile); err != nil {
+			fmt.Fprintf(os.Stderr, "load schema error: %v\n", err)
+			return 1
+		}
+	}
+	sqlConverter, err := backend.NewOfflineConverter(cfg, dump)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "create converter error: %v\n", err)
+		return 1
+	}
+
+	var stmts []string
+	files := fs.Args()
+	if len(files) == 0 {
+		files = []string{"-"}
+	}
+	for _, name := range files {
+		var r io.Reader = os.Stdin
+		if name != "-" {
+			f, err := os.Open(name)
+			if err != nil {
+				fmt.Fprintf(os.Stderr, "open %s error: %v\n", name, err)
+				return 1
+			}
+			defer f.Close()
+			r = f
+		}
+		fileStmts, err := convert.ReadStatements(r, *format)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "read %s error: %v\n", name, err)
+			return 1
+		}
+		stmts = append(stmts, fileStmts...)
+	}
+
+	// 没有导出表结构时不检查缺少表结构的语句
+	var tables convert.Tables
+	if dump != nil {
+		tables = dump
+	}
+	report := convert.Analyze(sqlConverter, tables, stmts)
+	if *output == "json" {
+		enc := json.NewEncoder(os.Stdout)
+		enc.SetIndent("", "  ")
+		if err := enc.Encode(report); err != nil {
+			fmt.Fprintf(os.Stderr, "output error: %v\n", err)
+			return 1
+		}
+	} else {
+		report.WriteText(os.Stdout)
+	}
+	return 0
+}
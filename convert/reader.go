@@ -0,0 +1,230 @@
+// Package convert 离线转换SQL，用于迁移前评估业务的SQL在目标库上的兼容性
+package convert
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"regexp"
+	"strings"
+
+	"sqlproxy/sqlparser"
+)
+
+// 输入的格式
+const (
+	FormatAuto    = "auto"
+	FormatSQL     = "sql"     // 分号分隔的SQL文件
+	FormatSQLLog  = "sqllog"  // 中间件的sql.log
+	FormatGeneral = "general" // MySQL的general log
+	FormatSlow    = "slow"    // MySQL的slow log
+)
+
+var (
+	// 2006/01/02 15:04:05.000 - OK - 1.2ms - 3 rows - root@demodb - 127.0.0.1:50123->0.0.0.0:9696:select ...
+	// 老版本没有行数和用户
+	sqlLogLine = regexp.MustCompile(`^\d{4}/\d\d/\d\d \d\d:\d\d:\d\d(?:\.\d+)? - (?:\w+) - [\d.]+ms - (?:\d+ rows - \S*@\S* - )?\S+->\S*?:\d+:(.*)$`)
+	// 2006/01/02 15:04:05.000 - Error - err:...,sql:select ...
+	sqlLogErrorLine = regexp.MustCompile(`^\d{4}/\d\d/\d\d \d\d:\d\d:\d\d(?:\.\d+)? - Error - err:.*?,sql:(.*)$`)
+	// 后端执行的日志是转换后的SQL，不是MySQL的语法
+	sqlLogBackendLine = regexp.MustCompile(`^\d{4}/\d\d/\d\d \d\d:\d\d:\d\d(?:\.\d+)? - [\w ]+ - \[Queries/`)
+	// 2024-01-01T10:00:00.123456Z	   12 Query	select 1
+	// 240101 10:00:00	   12 Query	select 1（5.6及以前，同一秒内的后续行没有时间）
+	generalLine = regexp.MustCompile(`^(?:\d{4}-\d\d-\d\dT\S+|\d{6}\s+\d{1,2}:\d\d:\d\d)?\s+\d+ ([A-Z][a-z]+(?: [A-Za-z]+)?)\t(.*)$`)
+)
+
+// ReadStatements 按格式读取全部的SQL语句，format为auto时根据内容判断
+func ReadStatements(r io.Reader, format string) ([]string, error) {
+	lines, err := readLines(r)
+	if err != nil {
+		return nil, err
+	}
+	if format == FormatAuto || format == "" {
+		format = DetectFormat(lines)
+	}
+	switch format {
+	case FormatSQL:
+		return splitStatements(strings.Join(lines, "\n"))
+	case FormatSQLLog:
+		return readSQLLog(lines), nil
+	case FormatGeneral:
+		return readGeneralLog(lines), nil
+	case FormatSlow:
+		return readSlowLog(lines)
+	default:
+		return nil, fmt.Errorf("unsupported input format: %s", format)
+	}
+}
+
+// DetectFormat 根据前面的内容判断输入的格式
+func DetectFormat(lines []string) string {
+	format := FormatSQL
+	n := 0
+	for _, line := range lines {
+		if strings.TrimSpace(line) == "" {
+			continue
+		}
+		switch {
+		case strings.HasPrefix(line, "# Time:") || strings.HasPrefix(line, "# User@Host:") || strings.HasPrefix(line, "# Query_time:"):
+			return FormatSlow
+		case format == FormatSQL && (sqlLogLine.MatchString(line) || sqlLogErrorLine.MatchString(line) || sqlLogBackendLine.MatchString(line)):
+			format = FormatSQLLog
+		case format == FormatSQL && generalLine.MatchString(line):
+			format = FormatGeneral
+		}
+		if n++; n >= 50 {
+			break
+		}
+	}
+	return format
+}
+
+func readLines(r io.Reader) ([]string, error) {
+	data, err := ioutil.ReadAll(bufio.NewReader(r))
+	if err != nil {
+		return nil, err
+	}
+	text := strings.ReplaceAll(string(data), "\r\n", "\n")
+	return strings.Split(strings.TrimSuffix(text, "\n"), "\n"), nil
+}
+
+// splitStatements 按分号拆分，去掉语句前后的注释和只有注释的语句
+func splitStatements(text string) ([]string, error) {
+	pieces, err := sqlparser.SplitStatementToPieces(text)
+	if err != nil {
+		return nil, err
+	}
+	stmts := make([]string, 0, len(pieces))
+	for _, piece := range pieces {
+		query, _ := sqlparser.SplitMarginComments(trimLineComments(piece))
+		if query = strings.TrimSpace(query); query == "" {
+			continue
+		}
+		stmts = append(stmts, query)
+	}
+	return stmts, nil
+}
+
+// trimLineComments 去掉语句前面以--和#开头的注释行
+func trimLineComments(stmt string) string {
+	for {
+		stmt = strings.TrimSpace(stmt)
+		if !strings.HasPrefix(stmt, "--") && !strings.HasPrefix(stmt, "#") {
+			return stmt
+		}
+		i := strings.IndexByte(stmt, '\n')
+		if i < 0 {
+			return ""
+		}
+		stmt = stmt[i+1:]
+	}
+}
+
+// statementBuilder 日志中的语句可能跨多行，不以日志头开头的行属于上一条语句
+type statementBuilder struct {
+	stmts   []string
+	current []string
+	active  bool
+}
+
+func (b *statementBuilder) start(line string, active bool) {
+	b.flush()
+	b.active = active
+	if active {
+		b.current = append(b.current, line)
+	}
+}
+
+func (b *statementBuilder) append(line string) {
+	if b.active {
+		b.current = append(b.current, line)
+	}
+}
+
+func (b *statementBuilder) flush() {
+	if len(b.current) > 0 {
+		if stmt := strings.TrimSpace(strings.Join(b.current, "\n")); stmt != "" {
+			b.stmts = append(b.stmts, stmt)
+		}
+	}
+	b.current = nil
+	b.active = false
+}
+
+func readSQLLog(lines []string) []string {
+	b := &statementBuilder{}
+	for _, line := range lines {
+		if m := sqlLogLine.FindStringSubmatch(line); m != nil {
+			b.start(m[1], true)
+		} else if m := sqlLogErrorLine.FindStringSubmatch(line); m != nil {
+			b.start(m[1], true)
+		} else if sqlLogBackendLine.MatchString(line) {
+			b.start("", false)
+		} else {
+			b.append(line)
+		}
+	}
+	b.flush()
+	return b.stmts
+}
+
+// readGeneralLog 只取Query和Execute，Prepare的语句在Execute时会带着参数值再记录一次
+func readGeneralLog(lines []string) []string {
+	b := &statementBuilder{}
+	for _, line := range lines {
+		if m := generalLine.FindStringSubmatch(line); m != nil {
+			b.start(m[2], m[1] == "Query" || m[1] == "Execute")
+		} else if isMySQLLogHeader(line) {
+			b.start("", false)
+		} else {
+			b.append(line)
+		}
+	}
+	b.flush()
+	return b.stmts
+}
+
+// readSlowLog #开头的行是注释，语句以分号结束，去掉慢日志自动加上的use和set timestamp
+func readSlowLog(lines []string) ([]string, error) {
+	stmts := []string{}
+	var current []string
+	flush := func() error {
+		if len(current) == 0 {
+			return nil
+		}
+		pieces, err := splitStatements(strings.Join(current, "\n"))
+		current = nil
+		if err != nil {
+			return err
+		}
+		for _, piece := range pieces {
+			lower := strings.ToLower(piece)
+			if strings.HasPrefix(lower, "use ") || strings.HasPrefix(lower, "set timestamp=") {
+				continue
+			}
+			stmts = append(stmts, piece)
+		}
+		return nil
+	}
+	for _, line := range lines {
+		if strings.HasPrefix(line, "#") || isMySQLLogHeader(line) {
+			if err := flush(); err != nil {
+				return nil, err
+			}
+			continue
+		}
+		current = append(current, line)
+	}
+	if err := flush(); err != nil {
+		return nil, err
+	}
+	return stmts, nil
+}
+
+// isMySQLLogHeader MySQL启动时在general log和slow log中写入的文件头
+func isMySQLLogHeader(line string) bool {
+	return strings.Contains(line, ", Version: ") ||
+		strings.HasPrefix(line, "Tcp port: ") ||
+		strings.HasPrefix(line, "Time ") && strings.Contains(line, "Id Command")
+}
@@ -0,0 +1,79 @@
+package convert
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestReadStatements(t *testing.T) {
+	testCases := []struct {
+		format string
+		in     string
+		out    []string
+	}{
+		{
+			format: FormatSQL,
+			in:     "-- comment\nselect 1;\n\ninsert into t1 values ('a;b');\n/* only comment */;\nselect 2",
+			out:    []string{"select 1", "insert into t1 values ('a;b')", "select 2"},
+		},
+		{
+			format: FormatSQLLog,
+			in: `2024/01/02 15:04:05.000 - OK - 1.2ms - 127.0.0.1:50123->0.0.0.0:9696:select a
+from t1
+2024/01/02 15:04:05.100 -   OK - [Queries/demodb] - [      Query /     0.3ms] - [select "a" from "t1"]
+2024/01/02 15:04:05.200 - Error - err:ERROR 1105 (HY000): unknown,sql:update t1 set a = 1
+2024/01/02 15:04:05.300 - ERROR - 0.5ms - [::1]:50124->[::]:9696:delete from t1
+2024/01/02 15:04:05.400 - OK - 0.8ms - 2 rows - root@demodb - 127.0.0.1:50125->0.0.0.0:9696:select b from t2 where id = ?
+2024/01/02 15:04:05.500 - OK - 0.1ms - 0 rows - root@ - 127.0.0.1:50125->0.0.0.0:9696:set names utf8mb4`,
+			out: []string{"select a\nfrom t1", "update t1 set a = 1", "delete from t1", "select b from t2 where id = ?", "set names utf8mb4"},
+		},
+		{
+			format: FormatGeneral,
+			in: `/usr/sbin/mysqld, Version: 8.0.30 (MySQL Community Server - GPL). started with:
+Tcp port: 3306  Unix socket: /var/run/mysqld/mysqld.sock
+Time                 Id Command    Argument
+2024-01-01T10:00:00.123456Z	   12 Connect	root@localhost on demo using Socket
+2024-01-01T10:00:00.223456Z	   12 Query	select a
+from t1
+2024-01-01T10:00:00.323456Z	   12 Prepare	select a from t1 where id = ?
+2024-01-01T10:00:00.423456Z	   12 Execute	select a from t1 where id = 5
+240101 10:00:01	   13 Query	select 2
+		   13 Query	select 3
+2024-01-01T10:00:00.523456Z	   12 Quit	`,
+			out: []string{"select a\nfrom t1", "select a from t1 where id = 5", "select 2", "select 3"},
+		},
+		{
+			format: FormatSlow,
+			in: `/usr/sbin/mysqld, Version: 8.0.30 (MySQL Community Server - GPL). started with:
+Tcp port: 3306  Unix socket: /var/run/mysqld/mysqld.sock
+Time                 Id Command    Argument
+# Time: 2024-01-01T10:00:00.123456Z
+# User@Host: root[root] @ localhost []  Id:    12
+# Query_time: 1.000205  Lock_time: 0.000000 Rows_sent: 1  Rows_examined: 0
+use demo;
+SET timestamp=1704103200;
+select sleep(1)
+from dual;
+# Time: 2024-01-01T10:00:02.123456Z
+# User@Host: root[root] @ localhost []  Id:    12
+# Query_time: 2.000205  Lock_time: 0.000000 Rows_sent: 1  Rows_examined: 0
+SET timestamp=1704103202;
+select sleep(2);`,
+			out: []string{"select sleep(1)\nfrom dual", "select sleep(2)"},
+		},
+	}
+	for _, tcase := range testCases {
+		lines, err := readLines(strings.NewReader(tcase.in))
+		assert.Nil(t, err)
+		assert.Equal(t, tcase.format, DetectFormat(lines))
+
+		stmts, err := ReadStatements(strings.NewReader(tcase.in), FormatAuto)
+		assert.Nil(t, err)
+		assert.Equal(t, tcase.out, stmts)
+	}
+
+	_, err := ReadStatements(strings.NewReader("select 1"), "csv")
+	assert.NotNil(t, err)
+}
@@ -0,0 +1,166 @@
+package convert
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"regexp"
+	"sort"
+	"strings"
+
+	"sqlproxy/mysql"
+	"sqlproxy/sqlparser"
+)
+
+// 语句的转换结果
+const (
+	StatusConverted    = "converted"     // 转换为目标库的写法
+	StatusUnchanged    = "unchanged"     // 不需要转换，原样执行
+	StatusParseError   = "parse_error"   // 解析失败，中间件会原样执行，需要人工确认
+	StatusConvertError = "convert_error" // 明确无法转换，中间件会返回错误
+	StatusWarning      = "warning"       // 转换结果中仍有MySQL特有的写法或者缺少表结构，结果可能不正确，需要人工确认
+)
+
+// 报告中按状态排列的顺序，需要处理的排在前面
+var statusOrder = []string{StatusConvertError, StatusParseError, StatusWarning, StatusUnchanged, StatusConverted}
+
+// 目标库都不支持的MySQL写法，转换后仍然存在说明没有转换（例如缺少唯一索引时的on duplicate key update）
+var mysqlOnlySyntax = regexp.MustCompile(`(?i)\bon\s+duplicate\s+key\s+update\b|^\s*insert\s+ignore\b|\block\s+in\s+share\s+mode\b|\bsql_calc_found_rows\b`)
+
+// Tables 有表结构信息的表，用于检查依赖表结构的转换，为nil时不检查
+type Tables interface {
+	HasTable(name string) bool
+}
+
+// Group 指纹和转换结果相同的语句
+type Group struct {
+	Fingerprint string `json:"fingerprint"`
+	Status      string `json:"status"`
+	Count       int    `json:"count"`
+	SQL         string `json:"sql"` // 第一条语句
+	Converted   string `json:"converted,omitempty"`
+	Error       string `json:"error,omitempty"`
+}
+
+type Report struct {
+	Total  int            `json:"total"`
+	Counts map[string]int `json:"counts"` // 各状态的语句数
+	Groups []*Group       `json:"groups"`
+}
+
+// Analyze 逐条转换语句，按指纹汇总
+func Analyze(converter sqlparser.SQLConverter, tables Tables, stmts []string) *Report {
+	report := &Report{Counts: make(map[string]int)}
+	groups := make(map[string]*Group)
+	for _, stmt := range stmts {
+		status, converted, err := convertStatement(converter, tables, stmt)
+		fingerprint := mysql.GetFingerprint(stmt)
+		key := status + "/" + mysql.GetMd5(fingerprint)
+		group, ok := groups[key]
+		if !ok {
+			group = &Group{
+				Fingerprint: fingerprint,
+				Status:      status,
+				SQL:         stmt,
+				Converted:   converted,
+			}
+			if err != nil {
+				group.Error = err.Error()
+			}
+			groups[key] = group
+			report.Groups = append(report.Groups, group)
+		}
+		group.Count++
+		report.Counts[status]++
+		report.Total++
+	}
+	rank := func(status string) int {
+		for i, s := range statusOrder {
+			if s == status {
+				return i
+			}
+		}
+		return len(statusOrder)
+	}
+	sort.SliceStable(report.Groups, func(i, j int) bool {
+		gi, gj := report.Groups[i], report.Groups[j]
+		if gi.Status != gj.Status {
+			return rank(gi.Status) < rank(gj.Status)
+		}
+		return gi.Count > gj.Count
+	})
+	return report
+}
+
+// convertStatement 与中间件执行时的转换方式一致：DDL使用ConvertDDL，一条DDL可能转换为多条语句
+func convertStatement(converter sqlparser.SQLConverter, tables Tables, stmt string) (string, string, error) {
+	parsed, err := sqlparser.Parse(stmt)
+	if err != nil {
+		return StatusParseError, "", err
+	}
+	var converted string
+	if ddl, ok := converter.(sqlparser.DDLConverter); ok && sqlparser.Preview(stmt) == sqlparser.StmtDDL {
+		var stmts []string
+		stmts, err = ddl.ConvertDDL(stmt)
+		converted = strings.Join(stmts, ";\n")
+	} else {
+		converted, _, err = converter.Convert(stmt)
+	}
+	if err != nil {
+		return StatusConvertError, "", err
+	}
+	if warning := checkConverted(tables, parsed, converted); warning != "" {
+		return StatusWarning, converted, errors.New(warning)
+	}
+	if converted == stmt {
+		return StatusUnchanged, "", nil
+	}
+	return StatusConverted, converted, nil
+}
+
+// checkConverted 检查转换结果是否可能不正确：仍有MySQL特有的写法，
+// 或者依赖表结构的insert（on duplicate key update、insert ignore、replace、省略列名）没有对应的表结构
+func checkConverted(tables Tables, parsed sqlparser.Statement, converted string) string {
+	if m := mysqlOnlySyntax.FindString(converted); m != "" {
+		return fmt.Sprintf("mysql-only syntax remains: %s", strings.ToLower(strings.TrimSpace(m)))
+	}
+	insert, ok := parsed.(*sqlparser.Insert)
+	if !ok || tables == nil {
+		return ""
+	}
+	if insert.OnDup == nil && insert.Ignore == "" && insert.Action != sqlparser.ReplaceStr && len(insert.Columns) > 0 {
+		return ""
+	}
+	if name := insert.Table.Name.String(); !tables.HasTable(name) {
+		return fmt.Sprintf("no metadata of table %s", name)
+	}
+	return ""
+}
+
+// WriteText 输出文本格式的报告
+func (r *Report) WriteText(w io.Writer) {
+	fmt.Fprintf(w, "total: %d statements, %d fingerprints\n", r.Total, len(r.Groups))
+	counts := make([]string, 0, len(statusOrder))
+	for _, status := range statusOrder {
+		counts = append(counts, fmt.Sprintf("%s: %d", status, r.Counts[status]))
+	}
+	fmt.Fprintln(w, strings.Join(counts, ", "))
+
+	status := ""
+	for _, g := range r.Groups {
+		if g.Status != status {
+			status = g.Status
+			fmt.Fprintf(w, "\n== %s ==\n", status)
+		}
+		fmt.Fprintf(w, "\n[%d] %s\n", g.Count, g.Fingerprint)
+		fmt.Fprintf(w, "  sql: %s\n", g.SQL)
+		if g.Converted != "" {
+			fmt.Fprintf(w, "  converted: %s\n", g.Converted)
+		}
+		if g.Error != "" && g.Status == StatusWarning {
+			fmt.Fprintf(w, "  warning: %s\n", g.Error)
+		} else if g.Error != "" {
+			fmt.Fprintf(w, "  error: %s\n", g.Error)
+		}
+	}
+}
@@ -0,0 +1,67 @@
+package convert
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"sqlproxy/sqlparser"
+)
+
+func TestAnalyze(t *testing.T) {
+	converter := sqlparser.NewPostgresConverter(map[string]map[string][]string{"t1": {"PRIMARY": {"id"}}}, nil, nil)
+	report := Analyze(converter, nil, []string{
+		"select a from t1 where id = 1",
+		"select a from t1 where id = 2",
+		"set names utf8mb4",
+		"select from where",
+		"insert into t1 (id, a) values (1, 'x') on duplicate key update a = values(a)",
+	})
+	assert.Equal(t, 5, report.Total)
+	assert.Equal(t, map[string]int{StatusConverted: 3, StatusUnchanged: 1, StatusParseError: 1}, report.Counts)
+	assert.Len(t, report.Groups, 4)
+
+	assert.Equal(t, StatusParseError, report.Groups[0].Status)
+	assert.NotEmpty(t, report.Groups[0].Error)
+	assert.Equal(t, StatusUnchanged, report.Groups[1].Status)
+	assert.Equal(t, &Group{
+		Fingerprint: "select a from t1 where id = ?",
+		Status:      StatusConverted,
+		Count:       2,
+		SQL:         "select a from t1 where id = 1",
+		Converted:   `select "a" from "t1" where "id" = 1`,
+	}, report.Groups[2])
+	assert.Equal(t, `insert into "t1"("id", "a") values (1, 'x') on conflict ("id") do update set "a" = excluded."a"`, report.Groups[3].Converted)
+
+	var buf bytes.Buffer
+	report.WriteText(&buf)
+	assert.Contains(t, buf.String(), "total: 5 statements, 4 fingerprints\n")
+	assert.Contains(t, buf.String(), "\n[2] select a from t1 where id = ?\n")
+}
+
+type testTables map[string]bool
+
+func (t testTables) HasTable(name string) bool {
+	return t[name]
+}
+
+func TestAnalyzeWarning(t *testing.T) {
+	converter := sqlparser.NewOracleConverter(map[string]map[string][]string{"t1": {"PRIMARY": {"id"}}}, nil, nil)
+	report := Analyze(converter, testTables{"t1": true}, []string{
+		"insert into t1 (id, a) values (1, 'x') on duplicate key update a = values(a)",
+		"insert into t2 (id, a) values (1, 'x') on duplicate key update a = values(a)",
+		"replace into t2 (id, a) values (1, 'x')",
+		"select a from t1 lock in share mode",
+	})
+	assert.Equal(t, map[string]int{StatusConverted: 1, StatusWarning: 3}, report.Counts)
+	assert.Equal(t, StatusWarning, report.Groups[0].Status)
+	assert.Equal(t, `insert into "t2"("id", "a") values (1, 'x')`, report.Groups[0].Converted)
+	assert.Equal(t, "no metadata of table t2", report.Groups[0].Error)
+	assert.Equal(t, "mysql-only syntax remains: lock in share mode", report.Groups[2].Error)
+
+	var buf bytes.Buffer
+	report.WriteText(&buf)
+	assert.Contains(t, buf.String(), "\n== warning ==\n")
+	assert.Contains(t, buf.String(), "  warning: no metadata of table t2\n")
+}
@@ -67,8 +67,13 @@ var (
 	ErrDateRangeCount   = errors.New("date range count is not equal")
 	ErrSlaveExist       = errors.New("slave has exist")
 	ErrSlaveNotExist    = errors.New("slave has not exist")
+	ErrNodeNotExist     = errors.New("node has not exist")
 	ErrBlackSqlExist    = errors.New("black sql has exist")
 	ErrBlackSqlNotExist = errors.New("black sql has not exist")
+	ErrRewriteExist     = errors.New("sql rewrite has exist")
+	ErrRewriteNotExist  = errors.New("sql rewrite has not exist")
+	ErrFirewallMode     = errors.New("firewall mode must be off, learning, detect or enforce")
+	ErrFirewallNotExist = errors.New("firewall fingerprint has not exist")
 	ErrInsertTooComplex = errors.New("insert is too complex")
 	ErrSQLNULL          = errors.New("sql is null")
 
@@ -233,6 +233,9 @@ func GetLevel() int {
 var GlobalSysLogger *Logger = StdLogger()
 var GlobalSqlLogger *Logger = GlobalSysLogger
 
+// GlobalConvertLogger SQL转换日志，为nil时不记录
+var GlobalConvertLogger *Logger
+
 func (l *Logger) Write(p []byte) (n int, err error) {
 	output(LevelInfo, "web", "api", string(p), 0)
 	return len(p), nil
@@ -285,6 +288,18 @@ func OutputSql(state string, format string, v ...interface{}) {
 	l.msg <- buf
 }
 
+// OutputConvert 原样输出一行转换日志，没有开启转换日志时直接返回
+func OutputConvert(record []byte) {
+	l := GlobalConvertLogger
+	if l == nil {
+		return
+	}
+	buf := l.popBuf()
+	buf = append(buf, record...)
+	buf = append(buf, '\n')
+	l.msg <- buf
+}
+
 func output(level int, module string, method string, msg string, reqId uint32, args ...interface{}) {
 	if level < GlobalSysLogger.Level() {
 		return
@@ -38,9 +38,10 @@ var logLevel = flag.String("log-level", "", "log level [debug|info|warn|error],
 var version = flag.Bool("v", false, "the version of kingshard")
 
 const (
-	sqlLogName = "sql.log"
-	sysLogName = "sys.log"
-	MaxLogSize = 1024 * 1024 * 1024
+	sqlLogName     = "sql.log"
+	convertLogName = "convert.log"
+	sysLogName     = "sys.log"
+	MaxLogSize     = 1024 * 1024 * 1024
 )
 
 var (
@@ -58,6 +59,9 @@ const banner string = `
 `
 
 func main() {
+	if len(os.Args) > 1 && os.Args[1] == "convert" {
+		os.Exit(runConvert(os.Args[2:]))
+	}
 	fmt.Print(banner)
 	runtime.GOMAXPROCS(runtime.NumCPU())
 	flag.Parse()
@@ -94,6 +98,21 @@ func main() {
 		golog.GlobalSqlLogger = golog.New(sqlFile, golog.Lfile|golog.Ltime|golog.Llevel)
 	}
 
+	// 转换日志每行一个JSON，不加时间等前缀
+	if cfg.ConvertLog == golog.LogSqlOn {
+		var convertFile golog.Handler
+		if len(cfg.LogPath) != 0 {
+			convertFile, err = golog.NewRotatingFileHandler(path.Join(cfg.LogPath, convertLogName), MaxLogSize, 1)
+		} else {
+			convertFile, err = golog.NewStreamHandler(os.Stdout)
+		}
+		if err != nil {
+			fmt.Printf("new log file error:%v\n", err.Error())
+			return
+		}
+		golog.GlobalConvertLogger = golog.New(convertFile, 0)
+	}
+
 	if *logLevel != "" {
 		setLogLevel(*logLevel)
 	} else {
@@ -139,6 +158,9 @@ func main() {
 				golog.Info("main", "main", "Got signal", 0, "signal", sig)
 				golog.GlobalSysLogger.Close()
 				golog.GlobalSqlLogger.Close()
+				if golog.GlobalConvertLogger != nil {
+					golog.GlobalConvertLogger.Close()
+				}
 				svr.Close()
 			} else if sig == syscall.SIGPIPE {
 				golog.Info("main", "main", "Ignore broken pipe signal", 0)
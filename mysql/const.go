@@ -44,6 +44,20 @@ const (
 	SERVER_PS_OUT_PARAMS               uint16 = 0x1000
 )
 
+// COM_STMT_EXECUTE中的游标类型
+const (
+	CURSOR_TYPE_NO_CURSOR  byte = 0x00
+	CURSOR_TYPE_READ_ONLY  byte = 0x01
+	CURSOR_TYPE_FOR_UPDATE byte = 0x02
+	CURSOR_TYPE_SCROLLABLE byte = 0x04
+)
+
+// COM_SET_OPTION中的选项
+const (
+	MYSQL_OPTION_MULTI_STATEMENTS_ON  uint16 = 0
+	MYSQL_OPTION_MULTI_STATEMENTS_OFF uint16 = 1
+)
+
 const (
 	COM_SLEEP byte = iota
 	COM_QUIT
@@ -265,15 +279,4 @@ var (
 		COM_BINLOG_DUMP_GTID:    "COM_BINLOG_DUMP_GTID",
 		COM_RESET_CONNECTION:    "COM_RESET_CONNECTION",
 	}
-
-	FIELD_TYPE_MAP = map[string]byte{
-		"VARCHAR":   MYSQL_TYPE_VAR_STRING,
-		"INT":       MYSQL_TYPE_LONG,
-		"DECIMAL":   MYSQL_TYPE_DECIMAL,
-		"BIGINT":    MYSQL_TYPE_LONGLONG,
-		"TINYINT":   MYSQL_TYPE_TINY,
-		"SMALLINT":  MYSQL_TYPE_SHORT,
-		"TIMESTAMP": MYSQL_TYPE_TIMESTAMP,
-		//"DATE":      MYSQL_TYPE_DATE,
-	}
 )
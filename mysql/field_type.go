@@ -0,0 +1,454 @@
+package mysql
+
+import (
+	"database/sql"
+	"math"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// MySQL的JSON类型，不在上面按顺序定义的类型中
+const MYSQL_TYPE_JSON byte = 0xf5
+
+// binary字符集，数值、时间和二进制串的列使用
+const binaryCollationId uint16 = 63
+
+// 浮点数等小数位数不固定时的decimals
+const notFixedDec uint8 = 31
+
+// 后端数据库的类型名到MySQL类型的映射，覆盖MySQL、达梦、Oracle、PostgreSQL和SQLite中常见的类型
+var columnTypeMap = map[string]byte{
+	"TINYINT":  MYSQL_TYPE_TINY,
+	"BYTE":     MYSQL_TYPE_TINY,
+	"BIT":      MYSQL_TYPE_TINY, // 达梦的BIT是布尔值
+	"BOOL":     MYSQL_TYPE_TINY,
+	"BOOLEAN":  MYSQL_TYPE_TINY,
+	"SMALLINT": MYSQL_TYPE_SHORT,
+	"INT2":     MYSQL_TYPE_SHORT,
+
+	"MEDIUMINT":      MYSQL_TYPE_INT24,
+	"INT":            MYSQL_TYPE_LONG,
+	"INTEGER":        MYSQL_TYPE_LONG,
+	"INT4":           MYSQL_TYPE_LONG,
+	"PLS_INTEGER":    MYSQL_TYPE_LONG,
+	"BINARY_INTEGER": MYSQL_TYPE_LONG,
+	"SERIAL":         MYSQL_TYPE_LONG,
+	"BIGINT":         MYSQL_TYPE_LONGLONG,
+	"INT8":           MYSQL_TYPE_LONGLONG,
+	"BIGSERIAL":      MYSQL_TYPE_LONGLONG,
+
+	"FLOAT4":           MYSQL_TYPE_FLOAT,
+	"BINARY_FLOAT":     MYSQL_TYPE_FLOAT,
+	"FLOAT":            MYSQL_TYPE_DOUBLE, // 达梦和Oracle的FLOAT精度与DOUBLE相同
+	"FLOAT8":           MYSQL_TYPE_DOUBLE,
+	"REAL":             MYSQL_TYPE_DOUBLE,
+	"DOUBLE":           MYSQL_TYPE_DOUBLE,
+	"DOUBLE PRECISION": MYSQL_TYPE_DOUBLE,
+	"BINARY_DOUBLE":    MYSQL_TYPE_DOUBLE,
+	"DECIMAL":          MYSQL_TYPE_NEWDECIMAL,
+	"DEC":              MYSQL_TYPE_NEWDECIMAL,
+	"NUMERIC":          MYSQL_TYPE_NEWDECIMAL,
+	"NUMBER":           MYSQL_TYPE_NEWDECIMAL,
+
+	"CHAR":              MYSQL_TYPE_STRING,
+	"CHARACTER":         MYSQL_TYPE_STRING,
+	"NCHAR":             MYSQL_TYPE_STRING,
+	"BPCHAR":            MYSQL_TYPE_STRING,
+	"BINARY":            MYSQL_TYPE_STRING,
+	"ENUM":              MYSQL_TYPE_STRING,
+	"SET":               MYSQL_TYPE_STRING,
+	"VARCHAR":           MYSQL_TYPE_VAR_STRING,
+	"VARCHAR2":          MYSQL_TYPE_VAR_STRING,
+	"NVARCHAR":          MYSQL_TYPE_VAR_STRING,
+	"NVARCHAR2":         MYSQL_TYPE_VAR_STRING,
+	"CHARACTER VARYING": MYSQL_TYPE_VAR_STRING,
+	"VARBINARY":         MYSQL_TYPE_VAR_STRING,
+	"RAW":               MYSQL_TYPE_VAR_STRING,
+	"UUID":              MYSQL_TYPE_VAR_STRING,
+
+	"TINYTEXT":      MYSQL_TYPE_BLOB,
+	"TEXT":          MYSQL_TYPE_BLOB,
+	"MEDIUMTEXT":    MYSQL_TYPE_BLOB,
+	"LONGTEXT":      MYSQL_TYPE_BLOB,
+	"CLOB":          MYSQL_TYPE_BLOB,
+	"NCLOB":         MYSQL_TYPE_BLOB,
+	"LONG":          MYSQL_TYPE_BLOB,
+	"LONGVARCHAR":   MYSQL_TYPE_BLOB,
+	"TINYBLOB":      MYSQL_TYPE_BLOB,
+	"BLOB":          MYSQL_TYPE_BLOB,
+	"MEDIUMBLOB":    MYSQL_TYPE_BLOB,
+	"LONGBLOB":      MYSQL_TYPE_BLOB,
+	"IMAGE":         MYSQL_TYPE_BLOB,
+	"LONGVARBINARY": MYSQL_TYPE_BLOB,
+	"LONG RAW":      MYSQL_TYPE_BLOB,
+	"BYTEA":         MYSQL_TYPE_BLOB,
+	"JSON":          MYSQL_TYPE_JSON,
+	"JSONB":         MYSQL_TYPE_JSON,
+
+	"DATE":                           MYSQL_TYPE_DATE,
+	"TIME":                           MYSQL_TYPE_TIME,
+	"TIME WITH TIME ZONE":            MYSQL_TYPE_TIME,
+	"TIMETZ":                         MYSQL_TYPE_TIME,
+	"DATETIME":                       MYSQL_TYPE_DATETIME,
+	"DATETIME WITH TIME ZONE":        MYSQL_TYPE_DATETIME,
+	"TIMESTAMP WITHOUT TIME ZONE":    MYSQL_TYPE_DATETIME,
+	"TIMESTAMP":                      MYSQL_TYPE_TIMESTAMP,
+	"TIMESTAMP WITH TIME ZONE":       MYSQL_TYPE_TIMESTAMP,
+	"TIMESTAMP WITH LOCAL TIME ZONE": MYSQL_TYPE_TIMESTAMP,
+	"TIMESTAMPTZ":                    MYSQL_TYPE_TIMESTAMP,
+	"YEAR":                           MYSQL_TYPE_YEAR,
+	"GEOMETRY":                       MYSQL_TYPE_GEOMETRY,
+}
+
+// 与columnTypeMap中含义不同的类型：Oracle的DATE带有时分秒，按DATETIME返回，达梦和PostgreSQL的DATE只有日期
+var driverColumnTypeMap = map[string]map[string]byte{
+	"oci8": {"DATE": MYSQL_TYPE_DATETIME},
+}
+
+// 二进制串和二进制大对象，字符集为binary
+var binaryColumnTypes = map[string]bool{
+	"BINARY": true, "VARBINARY": true, "RAW": true, "TINYBLOB": true, "BLOB": true, "MEDIUMBLOB": true,
+	"LONGBLOB": true, "IMAGE": true, "LONGVARBINARY": true, "LONG RAW": true, "BYTEA": true,
+}
+
+// 整数类型的显示宽度，有符号的多一位
+var intColumnLength = map[byte]uint32{
+	MYSQL_TYPE_TINY:     3,
+	MYSQL_TYPE_SHORT:    5,
+	MYSQL_TYPE_INT24:    8,
+	MYSQL_TYPE_LONG:     10,
+	MYSQL_TYPE_LONGLONG: 20,
+	MYSQL_TYPE_YEAR:     4,
+}
+
+// columnInfo 后端驱动返回的列信息，驱动没有提供的长度和精度从类型名的参数中解析，如VARCHAR(20)、NUMBER(10,2)
+type columnInfo struct {
+	driver   string // 后端的驱动名
+	name     string
+	typeName string  // 去掉参数和UNSIGNED的大写类型名，如TIMESTAMP WITH TIME ZONE
+	args     []int64 // 类型名括号中的数字参数
+	unsigned bool
+
+	length      int64
+	hasLength   bool
+	precision   int64
+	scale       int64
+	hasDecimal  bool
+	nullable    bool
+	hasNullable bool
+	scanType    reflect.Type
+}
+
+func newColumnInfo(driverName string, column *sql.ColumnType) *columnInfo {
+	col := &columnInfo{driver: driverName, name: column.Name(), scanType: column.ScanType()}
+	col.parseTypeName(column.DatabaseTypeName())
+	col.length, col.hasLength = column.Length()
+	col.precision, col.scale, col.hasDecimal = column.DecimalSize()
+	col.nullable, col.hasNullable = column.Nullable()
+	if col.scanType != nil {
+		switch col.scanType.Kind() {
+		case reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+			col.unsigned = true
+		}
+	}
+	return col
+}
+
+func (col *columnInfo) parseTypeName(name string) {
+	name = strings.ToUpper(strings.TrimSpace(name))
+	if i := strings.IndexByte(name, '('); i >= 0 {
+		if j := strings.IndexByte(name[i:], ')'); j > 0 {
+			for _, arg := range strings.Split(name[i+1:i+j], ",") {
+				if v, err := strconv.ParseInt(strings.TrimSpace(arg), 10, 64); err == nil {
+					col.args = append(col.args, v)
+				}
+			}
+			name = name[:i] + " " + name[i+j+1:]
+		}
+	}
+	words := strings.Fields(name)
+	typeWords := words[:0]
+	for _, w := range words {
+		switch w {
+		case "UNSIGNED":
+			col.unsigned = true
+		case "SIGNED", "ZEROFILL":
+		default:
+			typeWords = append(typeWords, w)
+		}
+	}
+	col.typeName = strings.Join(typeWords, " ")
+}
+
+// charLength 字符类型的长度（字符数），unknown为驱动和类型名都没有提供时的值
+func (col *columnInfo) charLength(unknown int64) int64 {
+	if col.hasLength && col.length > 0 {
+		return col.length
+	}
+	if len(col.args) > 0 && col.args[0] > 0 {
+		return col.args[0]
+	}
+	return unknown
+}
+
+// decimalSize 数值类型的精度和小数位数，ok为false表示未知
+func (col *columnInfo) decimalSize() (precision, scale int64, ok bool) {
+	if col.hasDecimal && col.precision > 0 && col.precision < math.MaxInt16 {
+		return col.precision, col.scale, true
+	}
+	switch len(col.args) {
+	case 1:
+		return col.args[0], 0, true
+	case 2:
+		return col.args[0], col.args[1], true
+	}
+	return 0, 0, false
+}
+
+// fsp 时间类型的秒的小数位数
+func (col *columnInfo) fsp() uint8 {
+	var fsp int64
+	if col.hasDecimal {
+		fsp = col.scale
+	} else if len(col.args) > 0 {
+		fsp = col.args[0]
+	}
+	if fsp < 0 || fsp > 6 {
+		return 0
+	}
+	return uint8(fsp)
+}
+
+// scanFieldType 类型名无法识别时（如表达式列）按驱动的扫描类型推断
+func scanFieldType(scanType reflect.Type) byte {
+	if scanType == nil {
+		return MYSQL_TYPE_VAR_STRING
+	}
+	if scanType == reflect.TypeOf(time.Time{}) {
+		return MYSQL_TYPE_DATETIME
+	}
+	switch scanType.Kind() {
+	case reflect.Bool:
+		return MYSQL_TYPE_TINY
+	case reflect.Int8, reflect.Uint8:
+		return MYSQL_TYPE_TINY
+	case reflect.Int16, reflect.Uint16:
+		return MYSQL_TYPE_SHORT
+	case reflect.Int32, reflect.Uint32:
+		return MYSQL_TYPE_LONG
+	case reflect.Int, reflect.Int64, reflect.Uint, reflect.Uint64:
+		return MYSQL_TYPE_LONGLONG
+	case reflect.Float32:
+		return MYSQL_TYPE_FLOAT
+	case reflect.Float64:
+		return MYSQL_TYPE_DOUBLE
+	case reflect.Slice:
+		if scanType.Elem().Kind() == reflect.Uint8 {
+			return MYSQL_TYPE_BLOB
+		}
+	}
+	return MYSQL_TYPE_VAR_STRING
+}
+
+// charsetMaxLen 字符集中一个字符的最大字节数
+func charsetMaxLen(collation CollationId) int64 {
+	name := Collations[collation]
+	switch {
+	case strings.HasPrefix(name, "utf8mb4"), strings.HasPrefix(name, "utf16"), strings.HasPrefix(name, "utf32"):
+		return 4
+	case strings.HasPrefix(name, "utf8"), strings.HasPrefix(name, "ujis"), strings.HasPrefix(name, "eucjpms"):
+		return 3
+	case strings.HasPrefix(name, "gbk"), strings.HasPrefix(name, "gb2312"), strings.HasPrefix(name, "big5"),
+		strings.HasPrefix(name, "sjis"), strings.HasPrefix(name, "cp932"), strings.HasPrefix(name, "euckr"):
+		return 2
+	}
+	return 1
+}
+
+func clampColumnLength(length int64) uint32 {
+	if length < 0 || length > math.MaxUint32 {
+		return math.MaxUint32
+	}
+	return uint32(length)
+}
+
+// buildField 根据后端的列信息生成MySQL的列定义：类型、长度、小数位数、字符集和标志位
+func buildField(col *columnInfo) *Field {
+	field := &Field{
+		Name:    []byte(col.name),
+		OrgName: []byte(col.name),
+		Charset: binaryCollationId,
+	}
+	fieldType, ok := driverColumnTypeMap[col.driver][col.typeName]
+	if !ok {
+		fieldType, ok = columnTypeMap[col.typeName]
+	}
+	if !ok {
+		fieldType = scanFieldType(col.scanType)
+	}
+	binaryString := binaryColumnTypes[col.typeName]
+
+	// 没有小数位的NUMBER在达梦和Oracle中表示整数
+	if col.typeName == "NUMBER" {
+		if precision, scale, ok := col.decimalSize(); ok && scale == 0 && precision > 0 && precision < 19 {
+			switch {
+			case precision < 3:
+				fieldType = MYSQL_TYPE_TINY
+			case precision < 5:
+				fieldType = MYSQL_TYPE_SHORT
+			case precision < 10:
+				fieldType = MYSQL_TYPE_LONG
+			default:
+				fieldType = MYSQL_TYPE_LONGLONG
+			}
+		}
+	}
+	field.Type = fieldType
+
+	switch fieldType {
+	case MYSQL_TYPE_TINY, MYSQL_TYPE_SHORT, MYSQL_TYPE_INT24, MYSQL_TYPE_LONG, MYSQL_TYPE_LONGLONG, MYSQL_TYPE_YEAR:
+		field.Flag = NUM_FLAG | BINARY_FLAG
+		field.ColumnLength = intColumnLength[fieldType]
+		if col.typeName == "BIT" || col.typeName == "BOOL" || col.typeName == "BOOLEAN" {
+			field.ColumnLength = 1
+		} else if col.unsigned {
+			field.Flag |= UNSIGNED_FLAG
+		} else if fieldType != MYSQL_TYPE_LONGLONG && fieldType != MYSQL_TYPE_YEAR {
+			field.ColumnLength++
+		}
+	case MYSQL_TYPE_FLOAT, MYSQL_TYPE_DOUBLE:
+		field.Flag = NUM_FLAG | BINARY_FLAG
+		field.ColumnLength, field.Decimal = 12, notFixedDec
+		if fieldType == MYSQL_TYPE_DOUBLE {
+			field.ColumnLength = 22
+		}
+		if col.unsigned {
+			field.Flag |= UNSIGNED_FLAG
+		}
+	case MYSQL_TYPE_NEWDECIMAL:
+		field.Flag = NUM_FLAG | BINARY_FLAG
+		precision, scale, ok := col.decimalSize()
+		if !ok || precision > 65 || scale < 0 || scale > 30 {
+			precision, scale = 65, int64(notFixedDec)
+		}
+		field.Decimal = uint8(scale)
+		field.ColumnLength = uint32(precision)
+		if scale > 0 && scale <= precision {
+			field.ColumnLength++
+		}
+		if col.unsigned {
+			field.Flag |= UNSIGNED_FLAG
+		} else {
+			field.ColumnLength++
+		}
+	case MYSQL_TYPE_DATE:
+		field.Flag = BINARY_FLAG
+		field.ColumnLength = 10
+	case MYSQL_TYPE_DATETIME, MYSQL_TYPE_TIMESTAMP, MYSQL_TYPE_TIME:
+		field.Flag = BINARY_FLAG
+		field.Decimal = col.fsp()
+		field.ColumnLength = 19
+		if fieldType == MYSQL_TYPE_TIME {
+			field.ColumnLength = 10
+		}
+		if field.Decimal > 0 {
+			field.ColumnLength += uint32(field.Decimal) + 1
+		}
+	case MYSQL_TYPE_JSON:
+		field.Flag = BLOB_FLAG | BINARY_FLAG
+		field.ColumnLength = math.MaxUint32
+	case MYSQL_TYPE_BLOB:
+		field.Flag = BLOB_FLAG
+		var length int64
+		switch col.typeName {
+		case "TINYTEXT", "TINYBLOB":
+			length = math.MaxUint8
+		case "TEXT", "BLOB":
+			length = col.charLength(math.MaxUint16)
+		case "MEDIUMTEXT", "MEDIUMBLOB":
+			length = 1<<24 - 1
+		default:
+			length = math.MaxUint32
+		}
+		field.setCharset(length, binaryString)
+	case MYSQL_TYPE_STRING, MYSQL_TYPE_VAR_STRING:
+		switch col.typeName {
+		case "ENUM":
+			field.Flag = ENUM_FLAG
+		case "SET":
+			field.Flag = SET_FLAG
+		}
+		unknown := int64(math.MaxUint16)
+		if fieldType == MYSQL_TYPE_STRING {
+			unknown = 1
+		}
+		field.setCharset(col.charLength(unknown), binaryString)
+	case MYSQL_TYPE_GEOMETRY:
+		field.Flag = BLOB_FLAG | BINARY_FLAG
+		field.ColumnLength = math.MaxUint32
+	}
+
+	if col.hasNullable && !col.nullable {
+		field.Flag |= NOT_NULL_FLAG
+	}
+	return field
+}
+
+// setCharset 字符串使用中间件的默认字符集，长度按字符集换算为字节数；二进制串使用binary字符集
+func (f *Field) setCharset(length int64, binary bool) {
+	if binary {
+		f.Flag |= BINARY_FLAG
+		f.ColumnLength = clampColumnLength(length)
+		return
+	}
+	f.Charset = uint16(DEFAULT_COLLATION_ID)
+	maxLen := charsetMaxLen(DEFAULT_COLLATION_ID)
+	if length > math.MaxUint32/maxLen {
+		f.ColumnLength = math.MaxUint32
+		return
+	}
+	f.ColumnLength = uint32(length * maxLen)
+}
+
+// isTemporalType 日期和时间类型，文本协议中的值需要是MySQL的格式
+func isTemporalType(fieldType byte) bool {
+	switch fieldType {
+	case MYSQL_TYPE_DATE, MYSQL_TYPE_NEWDATE, MYSQL_TYPE_DATETIME, MYSQL_TYPE_TIMESTAMP, MYSQL_TYPE_TIME:
+		return true
+	}
+	return false
+}
+
+// formatTemporal 驱动返回的RFC3339格式（如SQLite的2006-01-02T15:04:05Z）及带时区的时间戳
+// 转为MySQL的格式，时区部分去掉，保留原始的本地时间
+func formatTemporal(fieldType byte, v []byte) []byte {
+	if len(v) < 10 {
+		return v
+	}
+	s := string(v)
+	if len(s) > 10 && s[10] == 'T' {
+		s = s[:10] + " " + s[11:]
+	}
+	if len(s) > 19 {
+		if i := strings.IndexAny(s[19:], "Z+- "); i >= 0 {
+			s = s[:19+i]
+		}
+	}
+	hasDate := len(s) >= 10 && s[4] == '-' && s[7] == '-'
+	switch fieldType {
+	case MYSQL_TYPE_DATE, MYSQL_TYPE_NEWDATE:
+		if hasDate {
+			s = s[:10]
+		}
+	case MYSQL_TYPE_TIME:
+		if hasDate && len(s) > 11 {
+			s = s[11:]
+		}
+	}
+	if s == string(v) {
+		return v
+	}
+	return []byte(s)
+}
@@ -0,0 +1,78 @@
+package mysql
+
+import (
+	"math"
+	"reflect"
+	"testing"
+)
+
+func TestBuildField(t *testing.T) {
+	tests := []struct {
+		typeName string
+		col      columnInfo
+		fieldTyp byte
+		length   uint32
+		decimal  uint8
+		charset  uint16
+		flag     uint16
+	}{
+		{"NUMBER", columnInfo{precision: 10, scale: 2, hasDecimal: true}, MYSQL_TYPE_NEWDECIMAL, 12, 2, binaryCollationId, NUM_FLAG | BINARY_FLAG},
+		{"NUMBER(9)", columnInfo{}, MYSQL_TYPE_LONG, 11, 0, binaryCollationId, NUM_FLAG | BINARY_FLAG},
+		{"NUMBER(19,0)", columnInfo{}, MYSQL_TYPE_NEWDECIMAL, 20, 0, binaryCollationId, NUM_FLAG | BINARY_FLAG},
+		{"DECIMAL(10,0)", columnInfo{}, MYSQL_TYPE_NEWDECIMAL, 11, 0, binaryCollationId, NUM_FLAG | BINARY_FLAG},
+		{"BIGINT(64) UNSIGNED", columnInfo{hasNullable: true}, MYSQL_TYPE_LONGLONG, 20, 0, binaryCollationId, NUM_FLAG | BINARY_FLAG | UNSIGNED_FLAG | NOT_NULL_FLAG},
+		{"tinyint", columnInfo{unsigned: true}, MYSQL_TYPE_TINY, 3, 0, binaryCollationId, NUM_FLAG | BINARY_FLAG | UNSIGNED_FLAG},
+		{"BIT", columnInfo{}, MYSQL_TYPE_TINY, 1, 0, binaryCollationId, NUM_FLAG | BINARY_FLAG},
+		{"BINARY_DOUBLE", columnInfo{}, MYSQL_TYPE_DOUBLE, 22, notFixedDec, binaryCollationId, NUM_FLAG | BINARY_FLAG},
+		{"VARCHAR2", columnInfo{length: 20, hasLength: true, nullable: true, hasNullable: true}, MYSQL_TYPE_VAR_STRING, 60, 0, 33, 0},
+		{"CHAR(2)", columnInfo{}, MYSQL_TYPE_STRING, 6, 0, 33, 0},
+		{"CLOB", columnInfo{}, MYSQL_TYPE_BLOB, math.MaxUint32, 0, 33, BLOB_FLAG},
+		{"TEXT", columnInfo{length: math.MaxInt64, hasLength: true}, MYSQL_TYPE_BLOB, math.MaxUint32, 0, 33, BLOB_FLAG},
+		{"BLOB", columnInfo{}, MYSQL_TYPE_BLOB, math.MaxUint16, 0, binaryCollationId, BLOB_FLAG | BINARY_FLAG},
+		{"RAW(16)", columnInfo{}, MYSQL_TYPE_VAR_STRING, 16, 0, binaryCollationId, BINARY_FLAG},
+		{"DATE", columnInfo{}, MYSQL_TYPE_DATE, 10, 0, binaryCollationId, BINARY_FLAG},
+		{"DATE", columnInfo{driver: "dm"}, MYSQL_TYPE_DATE, 10, 0, binaryCollationId, BINARY_FLAG},
+		{"DATE", columnInfo{driver: "oci8"}, MYSQL_TYPE_DATETIME, 19, 0, binaryCollationId, BINARY_FLAG},
+		{"TIMESTAMP(6) WITH TIME ZONE", columnInfo{}, MYSQL_TYPE_TIMESTAMP, 26, 6, binaryCollationId, BINARY_FLAG},
+		{"DATETIME", columnInfo{}, MYSQL_TYPE_DATETIME, 19, 0, binaryCollationId, BINARY_FLAG},
+		{"ENUM('a','b')", columnInfo{}, MYSQL_TYPE_STRING, 3, 0, 33, ENUM_FLAG},
+		{"", columnInfo{scanType: reflect.TypeOf(int64(0))}, MYSQL_TYPE_LONGLONG, 20, 0, binaryCollationId, NUM_FLAG | BINARY_FLAG},
+		{"", columnInfo{scanType: reflect.TypeOf("")}, MYSQL_TYPE_VAR_STRING, math.MaxUint16 * 3, 0, 33, 0},
+	}
+	for _, test := range tests {
+		col := test.col
+		col.name = "c"
+		col.parseTypeName(test.typeName)
+		f := buildField(&col)
+		if f.Type != test.fieldTyp || f.ColumnLength != test.length || f.Decimal != test.decimal ||
+			f.Charset != test.charset || f.Flag != test.flag {
+			t.Fatalf("%s: type=%d length=%d decimal=%d charset=%d flag=%d", test.typeName,
+				f.Type, f.ColumnLength, f.Decimal, f.Charset, f.Flag)
+		}
+		if string(f.Name) != "c" || string(f.OrgName) != "c" {
+			t.Fatal(string(f.Name), string(f.OrgName))
+		}
+	}
+}
+
+func TestFormatTemporal(t *testing.T) {
+	tests := []struct {
+		fieldType byte
+		value     string
+		expect    string
+	}{
+		{MYSQL_TYPE_DATETIME, "2024-01-02 10:20:30", "2024-01-02 10:20:30"},
+		{MYSQL_TYPE_DATETIME, "2024-01-02T10:20:30Z", "2024-01-02 10:20:30"},
+		{MYSQL_TYPE_TIMESTAMP, "2024-01-02T10:20:30.123456+08:00", "2024-01-02 10:20:30.123456"},
+		{MYSQL_TYPE_TIMESTAMP, "2024-01-02 10:20:30 +0800 CST", "2024-01-02 10:20:30"},
+		{MYSQL_TYPE_DATE, "2024-01-02T00:00:00Z", "2024-01-02"},
+		{MYSQL_TYPE_DATETIME, "2024-01-02T10:20:30+08:00", "2024-01-02 10:20:30"},
+		{MYSQL_TYPE_TIME, "0000-01-01T10:20:30Z", "10:20:30"},
+		{MYSQL_TYPE_TIME, "-838:59:59", "-838:59:59"},
+	}
+	for _, test := range tests {
+		if v := string(formatTemporal(test.fieldType, []byte(test.value))); v != test.expect {
+			t.Fatalf("%s: %s", test.value, v)
+		}
+	}
+}
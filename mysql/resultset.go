@@ -115,7 +115,7 @@ func (p RowData) ParseBinary(f []*Field) ([]interface{}, error) {
 			if isUnsigned {
 				data[i] = uint64(p[pos])
 			} else {
-				data[i] = int64(p[pos])
+				data[i] = int64(int8(p[pos]))
 			}
 			pos++
 			continue
@@ -186,7 +186,7 @@ func (p RowData) ParseBinary(f []*Field) ([]interface{}, error) {
 		case MYSQL_TYPE_DECIMAL, MYSQL_TYPE_NEWDECIMAL, MYSQL_TYPE_VARCHAR,
 			MYSQL_TYPE_BIT, MYSQL_TYPE_ENUM, MYSQL_TYPE_SET, MYSQL_TYPE_TINY_BLOB,
 			MYSQL_TYPE_MEDIUM_BLOB, MYSQL_TYPE_LONG_BLOB, MYSQL_TYPE_BLOB,
-			MYSQL_TYPE_VAR_STRING, MYSQL_TYPE_STRING, MYSQL_TYPE_GEOMETRY:
+			MYSQL_TYPE_VAR_STRING, MYSQL_TYPE_STRING, MYSQL_TYPE_GEOMETRY, MYSQL_TYPE_JSON:
 			v, isNull, n, err = LengthEnodedString(p[pos:])
 			pos += n
 			if err != nil {
@@ -461,9 +461,12 @@ func (r *Resultset) GetStringByName(row int, name string) (string, error) {
 }
 
 // Add: 将database/sql返回的标准数据重新封装成Mysql结果集
-func BuildResultset(rows [][]sql.RawBytes, columnTypes []*sql.ColumnType, binary bool) (*Resultset, error) {
+func BuildResultset(rows [][]sql.RawBytes, fields []*Field, binary bool) (*Resultset, error) {
 
-	fields, fieldNames := buildFields(columnTypes, binary)
+	fieldNames := make(map[string]int, len(fields))
+	for i, field := range fields {
+		fieldNames[string(field.Name)] = i
+	}
 	r := &Resultset{
 		Fields:     fields,
 		FieldNames: fieldNames,
@@ -485,43 +488,39 @@ func BuildResultset(rows [][]sql.RawBytes, columnTypes []*sql.ColumnType, binary
 	}
 	return r, nil
 }
-func buildFields(columns []*sql.ColumnType, binary bool) ([]*Field, map[string]int) {
-	fields := make([]*Field, len(columns))
-	fieldNames := make(map[string]int, len(columns))
-	for i, column := range columns {
-		field := &Field{
-			Name: []byte(column.Name()),
-			//Charset: 63,
-			Flag: BINARY_FLAG,
-			Type: MYSQL_TYPE_VAR_STRING,
-		}
-		if !binary {
-			fieldTypeName := column.DatabaseTypeName()
-			if fieldType, ok := FIELD_TYPE_MAP[fieldTypeName]; ok {
-				field.Type = fieldType
-			}
-		}
-		fields[i] = field
-		fieldNames[column.Name()] = i
+
+// BuildFields 根据后端的列信息生成列定义，同名的类型在不同数据库中含义可能不同，按驱动名区分
+func BuildFields(driverName string, columnTypes []*sql.ColumnType) []*Field {
+	fields := make([]*Field, len(columnTypes))
+	for i, column := range columnTypes {
+		fields[i] = buildField(newColumnInfo(driverName, column))
 	}
-	return fields, fieldNames
+	return fields
 }
 
-func (r *Resultset) packetRowData(row []sql.RawBytes, binary bool) (RowData, error) {
+// PacketRowData 将一行编码为文本协议或二进制协议的行数据
+func PacketRowData(fields []*Field, row []sql.RawBytes, binary bool) (RowData, error) {
 	if binary {
-		return packetBinaryRowData(r.Fields, row)
-	} else {
-		return packetTextRowData(row)
+		return packetBinaryRowData(fields, row)
 	}
+	return packetTextRowData(fields, row)
+}
+
+func (r *Resultset) packetRowData(row []sql.RawBytes, binary bool) (RowData, error) {
+	return PacketRowData(r.Fields, row, binary)
 }
 
 // 转换成文本协议的结果集
-func packetTextRowData(row []sql.RawBytes) (RowData, error) {
+func packetTextRowData(fields []*Field, row []sql.RawBytes) (RowData, error) {
 	length := 0
-	for _, val := range row {
+	for i, val := range row {
 		if val == nil {
 			length++
 		} else {
+			if isTemporalType(fields[i].Type) {
+				val = formatTemporal(fields[i].Type, val)
+				row[i] = val
+			}
 			l := len(val)
 			length += LenEncIntSize(uint64(l)) + l
 		}
@@ -544,7 +543,7 @@ func packetTextRowData(row []sql.RawBytes) (RowData, error) {
 }
 
 // 转换成二进制协议的结果集
-// 由于database/sql这个标准接口层已经丢失了字段的具体数据类型，这里只能暂且都按照字符串类型来构造
+// 后端返回的都是文本值，按列定义中的类型编码，见appendBinaryValue
 func packetBinaryRowData(fields []*Field, row []sql.RawBytes) (RowData, error) {
 	length := 0
 	nullBitMapLen := (len(fields) + 7 + 2) / 8
@@ -558,7 +557,6 @@ func packetBinaryRowData(fields []*Field, row []sql.RawBytes) (RowData, error) {
 	length += nullBitMapLen + 1
 
 	data := make([]byte, 0, length)
-	pos := 0
 
 	data = append(data, 0x00)
 
@@ -566,19 +564,16 @@ func packetBinaryRowData(fields []*Field, row []sql.RawBytes) (RowData, error) {
 		data = append(data, 0x00)
 	}
 
+	var err error
 	for i, val := range row {
 		if val == nil {
 			bytePos := (i+2)/8 + 1
 			bitPos := (i + 2) % 8
 			data[bytePos] |= 1 << uint(bitPos)
-		} else {
-			data = append(data, PutLengthEncodedString(val)...)
+		} else if data, err = appendBinaryValue(data, fields[i], val); err != nil {
+			return nil, err
 		}
 	}
 
-	if len(data) != length {
-		return nil, fmt.Errorf("internal error packet row: got %v bytes but expected %v", pos, length)
-	}
-
 	return data, nil
 }
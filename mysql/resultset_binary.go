@@ -0,0 +1,194 @@
+package mysql
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+
+	"sqlproxy/core/hack"
+)
+
+// appendBinaryValue 按列的类型将后端返回的文本值编码为二进制协议中的值：
+// 整数按宽度、浮点数按IEEE 754小端编码，日期和时间按MySQL的二进制格式，其余按长度编码的字符串
+func appendBinaryValue(data []byte, field *Field, val []byte) ([]byte, error) {
+	switch field.Type {
+	case MYSQL_TYPE_TINY, MYSQL_TYPE_SHORT, MYSQL_TYPE_YEAR, MYSQL_TYPE_INT24,
+		MYSQL_TYPE_LONG, MYSQL_TYPE_LONGLONG:
+		n, err := parseBinaryInt(field, val)
+		if err != nil {
+			return nil, err
+		}
+		switch field.Type {
+		case MYSQL_TYPE_TINY:
+			return append(data, byte(n)), nil
+		case MYSQL_TYPE_SHORT, MYSQL_TYPE_YEAR:
+			return append(data, Uint16ToBytes(uint16(n))...), nil
+		case MYSQL_TYPE_INT24, MYSQL_TYPE_LONG:
+			return append(data, Uint32ToBytes(uint32(n))...), nil
+		default:
+			return append(data, Uint64ToBytes(n)...), nil
+		}
+
+	case MYSQL_TYPE_FLOAT:
+		f, err := strconv.ParseFloat(strings.TrimSpace(hack.String(val)), 32)
+		if err != nil {
+			return nil, fmt.Errorf("invalid float value %q for column %s", val, field.Name)
+		}
+		return append(data, Uint32ToBytes(math.Float32bits(float32(f)))...), nil
+
+	case MYSQL_TYPE_DOUBLE:
+		f, err := strconv.ParseFloat(strings.TrimSpace(hack.String(val)), 64)
+		if err != nil {
+			return nil, fmt.Errorf("invalid double value %q for column %s", val, field.Name)
+		}
+		return append(data, Uint64ToBytes(math.Float64bits(f))...), nil
+
+	case MYSQL_TYPE_DATE, MYSQL_TYPE_NEWDATE, MYSQL_TYPE_DATETIME, MYSQL_TYPE_TIMESTAMP:
+		return appendBinaryDateTime(data, field, formatTemporal(field.Type, val))
+
+	case MYSQL_TYPE_TIME:
+		return appendBinaryTime(data, field, formatTemporal(field.Type, val))
+
+	default:
+		return append(data, PutLengthEncodedString(val)...), nil
+	}
+}
+
+// 整数类型按宽度的取值范围
+var intRanges = map[byte][2]int64{
+	MYSQL_TYPE_TINY:  {math.MinInt8, math.MaxInt8},
+	MYSQL_TYPE_SHORT: {math.MinInt16, math.MaxInt16},
+	MYSQL_TYPE_YEAR:  {0, math.MaxUint16},
+	MYSQL_TYPE_INT24: {math.MinInt32, math.MaxInt32},
+	MYSQL_TYPE_LONG:  {math.MinInt32, math.MaxInt32},
+}
+
+// parseBinaryInt 解析整数列的值，布尔值转为0和1，超出列宽度的值返回错误
+func parseBinaryInt(field *Field, val []byte) (uint64, error) {
+	s := strings.TrimSpace(hack.String(val))
+	switch strings.ToLower(s) {
+	case "true":
+		return 1, nil
+	case "false":
+		return 0, nil
+	}
+	unsigned := field.Flag&UNSIGNED_FLAG > 0
+	if unsigned {
+		n, err := strconv.ParseUint(s, 10, 64)
+		if err == nil {
+			if r, ok := intRanges[field.Type]; ok && field.Type != MYSQL_TYPE_YEAR && n > uint64(r[1])*2+1 {
+				return 0, fmt.Errorf("value %s out of range for column %s", s, field.Name)
+			}
+			return n, nil
+		}
+	} else {
+		n, err := strconv.ParseInt(s, 10, 64)
+		if err == nil {
+			if r, ok := intRanges[field.Type]; ok && (n < r[0] || n > r[1]) {
+				return 0, fmt.Errorf("value %s out of range for column %s", s, field.Name)
+			}
+			return uint64(n), nil
+		}
+	}
+	// 达梦等数据库的NUMBER可能返回1.0这样的写法
+	if f, err := strconv.ParseFloat(s, 64); err == nil && f == math.Trunc(f) {
+		return parseBinaryInt(field, []byte(strconv.FormatFloat(f, 'f', -1, 64)))
+	}
+	return 0, fmt.Errorf("invalid integer value %q for column %s", s, field.Name)
+}
+
+// appendBinaryDateTime 编码为长度加年月日时分秒微秒，零值长度为0，没有时间和微秒部分时省略
+func appendBinaryDateTime(data []byte, field *Field, val []byte) ([]byte, error) {
+	s := hack.String(val)
+	var year, month, day, hour, minute, second, micro int
+	n, _ := fmt.Sscanf(s, "%d-%d-%d %d:%d:%d", &year, &month, &day, &hour, &minute, &second)
+	if n != 3 && n != 6 {
+		return nil, fmt.Errorf("invalid datetime value %q for column %s", s, field.Name)
+	}
+	if i := strings.IndexByte(s, '.'); n == 6 && i > 0 {
+		var err error
+		if micro, err = parseMicrosecond(s[i+1:]); err != nil {
+			return nil, fmt.Errorf("invalid datetime value %q for column %s", s, field.Name)
+		}
+	}
+	if field.Type == MYSQL_TYPE_DATE || field.Type == MYSQL_TYPE_NEWDATE {
+		hour, minute, second, micro = 0, 0, 0, 0
+	}
+
+	var length byte
+	switch {
+	case micro > 0:
+		length = 11
+	case hour > 0 || minute > 0 || second > 0:
+		length = 7
+	case year > 0 || month > 0 || day > 0:
+		length = 4
+	}
+	data = append(data, length)
+	if length == 0 {
+		return data, nil
+	}
+	data = append(data, Uint16ToBytes(uint16(year))...)
+	data = append(data, byte(month), byte(day))
+	if length > 4 {
+		data = append(data, byte(hour), byte(minute), byte(second))
+	}
+	if length > 7 {
+		data = append(data, Uint32ToBytes(uint32(micro))...)
+	}
+	return data, nil
+}
+
+// appendBinaryTime 编码为长度加符号、天数、时分秒、微秒，零值长度为0
+func appendBinaryTime(data []byte, field *Field, val []byte) ([]byte, error) {
+	s := hack.String(val)
+	var negative byte
+	if strings.HasPrefix(s, "-") {
+		negative, s = 1, s[1:]
+	}
+	var hours, minute, second, micro int
+	if n, _ := fmt.Sscanf(s, "%d:%d:%d", &hours, &minute, &second); n != 3 {
+		return nil, fmt.Errorf("invalid time value %q for column %s", val, field.Name)
+	}
+	if i := strings.IndexByte(s, '.'); i > 0 {
+		var err error
+		if micro, err = parseMicrosecond(s[i+1:]); err != nil {
+			return nil, fmt.Errorf("invalid time value %q for column %s", val, field.Name)
+		}
+	}
+
+	var length byte
+	switch {
+	case micro > 0:
+		length = 12
+	case hours > 0 || minute > 0 || second > 0:
+		length = 8
+	}
+	data = append(data, length)
+	if length == 0 {
+		return data, nil
+	}
+	data = append(data, negative)
+	data = append(data, Uint32ToBytes(uint32(hours/24))...)
+	data = append(data, byte(hours%24), byte(minute), byte(second))
+	if length > 8 {
+		data = append(data, Uint32ToBytes(uint32(micro))...)
+	}
+	return data, nil
+}
+
+// parseMicrosecond 秒的小数部分转为微秒，超过6位的截断
+func parseMicrosecond(frac string) (int, error) {
+	if len(frac) > 6 {
+		frac = frac[:6]
+	}
+	n, err := strconv.Atoi(frac)
+	if err != nil {
+		return 0, err
+	}
+	for i := len(frac); i < 6; i++ {
+		n *= 10
+	}
+	return n, nil
+}
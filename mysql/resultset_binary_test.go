@@ -0,0 +1,76 @@
+package mysql
+
+import (
+	"database/sql"
+	"reflect"
+	"testing"
+)
+
+func TestPacketBinaryRowData(t *testing.T) {
+	tests := []struct {
+		typeName string
+		unsigned bool
+		value    sql.RawBytes
+		expect   interface{}
+	}{
+		{"TINYINT", false, sql.RawBytes("-127"), int64(-127)},
+		{"TINYINT", true, sql.RawBytes("255"), uint64(255)},
+		{"BIT", false, sql.RawBytes("true"), int64(1)},
+		{"SMALLINT", false, sql.RawBytes("-32768"), int64(-32768)},
+		{"INT", false, sql.RawBytes("-2147483648"), int64(-2147483648)},
+		{"NUMBER(9)", false, sql.RawBytes("123.0"), int64(123)},
+		{"BIGINT", true, sql.RawBytes("18446744073709551615"), uint64(18446744073709551615)},
+		{"BIGINT", false, nil, nil},
+		{"BINARY_FLOAT", false, sql.RawBytes("3.5"), float64(3.5)},
+		{"DOUBLE", false, sql.RawBytes("3.14"), float64(3.14)},
+		{"NUMBER(10,2)", false, sql.RawBytes("12.30"), []byte("12.30")},
+		{"VARCHAR2", false, sql.RawBytes("abc"), []byte("abc")},
+		{"DATE", false, sql.RawBytes("2024-01-02"), []byte("2024-01-02")},
+		{"DATETIME", false, sql.RawBytes("2024-01-02T10:20:30Z"), []byte("2024-01-02 10:20:30")},
+		{"DATETIME", false, sql.RawBytes("2024-01-02 00:00:00"), []byte("2024-01-02 00:00:00")},
+		{"DATETIME", false, sql.RawBytes("0000-00-00 00:00:00"), []byte("0000-00-00 00:00:00")},
+		{"TIMESTAMP(6) WITH TIME ZONE", false, sql.RawBytes("2024-01-02 10:20:30.5 +08:00"), []byte("2024-01-02 10:20:30.500000")},
+		{"TIME", false, sql.RawBytes("-25:01:02"), []byte("-25:01:02")},
+		{"TIME", false, sql.RawBytes("10:20:30.000123"), []byte("10:20:30.000123")},
+	}
+
+	fields := make([]*Field, len(tests))
+	row := make([]sql.RawBytes, len(tests))
+	for i, test := range tests {
+		col := &columnInfo{name: test.typeName, unsigned: test.unsigned}
+		col.parseTypeName(test.typeName)
+		fields[i] = buildField(col)
+		row[i] = test.value
+	}
+	data, err := packetBinaryRowData(fields, row)
+	if err != nil {
+		t.Fatal(err)
+	}
+	values, err := data.ParseBinary(fields)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i, test := range tests {
+		if !reflect.DeepEqual(values[i], test.expect) {
+			t.Fatalf("%s %s: %#v", test.typeName, test.value, values[i])
+		}
+	}
+}
+
+func TestPacketBinaryRowDataError(t *testing.T) {
+	for _, test := range []struct {
+		typeName string
+		value    string
+	}{
+		{"TINYINT", "300"},
+		{"INT", "abc"},
+		{"DOUBLE", "x"},
+		{"DATETIME", "yesterday"},
+	} {
+		col := &columnInfo{name: "c"}
+		col.parseTypeName(test.typeName)
+		if _, err := packetBinaryRowData([]*Field{buildField(col)}, []sql.RawBytes{sql.RawBytes(test.value)}); err == nil {
+			t.Fatal(test.typeName, test.value)
+		}
+	}
+}
@@ -300,28 +300,28 @@ func FormatBinaryDateTime(n int, data []byte) ([]byte, error) {
 
 func FormatBinaryTime(n int, data []byte) ([]byte, error) {
 	if n == 0 {
-		return []byte("0000-00-00"), nil
+		return []byte("00:00:00"), nil
 	}
 
-	var sign byte
+	var sign string
 	if data[0] == 1 {
-		sign = byte('-')
+		sign = "-"
 	}
 
 	switch n {
 	case 8:
 		return []byte(fmt.Sprintf(
-			"%c%02d:%02d:%02d",
+			"%s%02d:%02d:%02d",
 			sign,
-			uint16(data[1])*24+uint16(data[5]),
+			binary.LittleEndian.Uint32(data[1:5])*24+uint32(data[5]),
 			data[6],
 			data[7],
 		)), nil
 	case 12:
 		return []byte(fmt.Sprintf(
-			"%c%02d:%02d:%02d.%06d",
+			"%s%02d:%02d:%02d.%06d",
 			sign,
-			uint16(data[1])*24+uint16(data[5]),
+			binary.LittleEndian.Uint32(data[1:5])*24+uint32(data[5]),
 			data[6],
 			data[7],
 			binary.LittleEndian.Uint32(data[8:12]),
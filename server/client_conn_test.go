@@ -196,3 +196,23 @@ func TestClientConn_Trans(t *testing.T) {
 		t.Fatal(err)
 	}
 }
+
+func TestClientConn_FieldOrigin(t *testing.T) {
+	c := &ClientConn{db: "test"}
+	stmt, err := sqlparser.Parse(`select t.str as s, f, f + 1 from kingshard_test_proxy_stmt as t`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	fields := []*mysql.Field{{Name: []byte("s")}, {Name: []byte("f")}, {Name: []byte("f + 1")}}
+	c.setFieldOrigin(fields, stmt.(*sqlparser.Select))
+	for i, expect := range [][4]string{
+		{"test", "t", "kingshard_test_proxy_stmt", "str"},
+		{"test", "t", "kingshard_test_proxy_stmt", "f"},
+		{"", "", "", ""},
+	} {
+		f := fields[i]
+		if got := [4]string{string(f.Schema), string(f.Table), string(f.OrgTable), string(f.OrgName)}; got != expect {
+			t.Fatal(i, got)
+		}
+	}
+}
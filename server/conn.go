@@ -21,6 +21,8 @@ import (
 	"net"
 	"runtime"
 	"sync"
+	"sync/atomic"
+	"time"
 
 	"sqlproxy/backend"
 	"sqlproxy/core/golog"
@@ -46,6 +48,8 @@ type ClientConn struct {
 	collation mysql.CollationId
 	charset   string
 
+	moreResults uint16 // 多语句中不是最后一条时为SERVER_MORE_RESULTS_EXISTS，附加到结果的状态中
+
 	user string
 	db   string
 
@@ -53,10 +57,15 @@ type ClientConn struct {
 
 	txConn *backend.BackendProxy
 
+	// 带有连接信息的node，use切换库或node重新加载后重新生成
+	node        *backend.BackendProxy
+	sessionNode *backend.BackendProxy
+
 	closed bool
 
 	lastInsertId int64
 	affectedRows int64
+	resultRows   int64 // 当前语句返回或影响的行数，用于sql.log
 
 	stmtId uint32
 
@@ -67,7 +76,8 @@ type ClientConn struct {
 
 var DEFAULT_CAPABILITY uint32 = mysql.CLIENT_LONG_PASSWORD | mysql.CLIENT_LONG_FLAG |
 	mysql.CLIENT_CONNECT_WITH_DB | mysql.CLIENT_PROTOCOL_41 |
-	mysql.CLIENT_TRANSACTIONS | mysql.CLIENT_SECURE_CONNECTION
+	mysql.CLIENT_TRANSACTIONS | mysql.CLIENT_SECURE_CONNECTION |
+	mysql.CLIENT_MULTI_STATEMENTS | mysql.CLIENT_MULTI_RESULTS
 
 var baseConnId uint32 = 10000
 
@@ -85,16 +95,26 @@ func (c *ClientConn) CanAccess(db string) bool {
 // If the transaction connection is nil, it checks if the backend schema for the user exists and returns it.
 // If the backend schema does not exist, it checks if the backend node for the database exists and returns it.
 // If neither the backend schema nor the backend node exists, it returns nil.
+// The node is wrapped with the connection id and user, which are recorded in the convert log.
 //
 // Returns a pointer to backend.BackendProxy.
 func (c *ClientConn) GetBackendDB() *backend.BackendProxy {
 	if c.txConn != nil {
 		return c.txConn
 	}
-	if backend := c.proxy.GetNode(c.db); backend != nil {
-		return backend
+	node := c.proxy.GetNode(c.db)
+	if node == nil {
+		return nil
 	}
-	return nil
+	if node != c.node {
+		sessionNode, err := node.WithSession(&backend.Session{ConnId: c.connectionId, User: c.user})
+		if err != nil {
+			golog.Warn("ClientConn", "GetBackendDB", err.Error(), c.connectionId, "db", c.db)
+			return node
+		}
+		c.node, c.sessionNode = node, sessionNode
+	}
+	return c.sessionNode
 }
 
 func (c *ClientConn) IsAllowConnect() bool {
@@ -299,6 +319,7 @@ func (c *ClientConn) readHandshakeResponse() error {
 
 func (c *ClientConn) clean() {
 	golog.Info("ClientConn", "clean", "", c.connectionId)
+	c.closeCursors()
 	if c.txConn != nil {
 		c.txConn.Commit() // TODO check possible problems?
 		c.txConn = nil
@@ -343,7 +364,7 @@ func (c *ClientConn) Run() {
 			)
 		}
 
-		if err := c.dispatch(data); err != nil {
+		if err := c.dispatchWithLog(data); err != nil {
 			c.proxy.counter.IncrErrLogTotal()
 			golog.Error("ClientConn", "Run",
 				err.Error(), c.connectionId,
@@ -362,6 +383,64 @@ func (c *ClientConn) Run() {
 	}
 }
 
+// dispatchWithLog 记录COM_QUERY和COM_STMT_EXECUTE的执行时间和结果行数，
+// 执行时间达到slow_log_time的计为慢查询，log_sql为on时写入sql.log
+func (c *ClientConn) dispatchWithLog(data []byte) error {
+	sql, ok := c.commandSQL(data)
+	if !ok {
+		return c.dispatch(data)
+	}
+	c.resultRows = 0
+	start := time.Now()
+	err := c.dispatch(data)
+	c.logSQL(sql, time.Since(start), err)
+	return err
+}
+
+// commandSQL 需要记录的命令对应的SQL，预处理语句记录prepare时的SQL
+func (c *ClientConn) commandSQL(data []byte) (string, bool) {
+	switch data[0] {
+	case mysql.COM_QUERY:
+		return string(data[1:]), true
+	case mysql.COM_STMT_EXECUTE:
+		if len(data) < 5 {
+			return "", false
+		}
+		if s, ok := c.stmts[binary.LittleEndian.Uint32(data[1:5])]; ok {
+			return s.sql, true
+		}
+	}
+	return "", false
+}
+
+// logSQL sql.log的格式：时间 - 状态 - 执行时间 - 行数 - 用户@库 - 客户端地址->中间件地址:SQL
+func (c *ClientConn) logSQL(sql string, d time.Duration, err error) {
+	execTime := float64(d) / float64(time.Millisecond)
+	slowLogTime := float64(c.proxy.slowLogTime[atomic.LoadInt32(&c.proxy.slowLogTimeIndex)])
+	if execTime < slowLogTime {
+		return
+	}
+	if slowLogTime > 0 {
+		c.proxy.counter.IncrSlowLogTotal()
+	}
+	if c.proxy.logSql[atomic.LoadInt32(&c.proxy.logSqlIndex)] == golog.LogSqlOff {
+		return
+	}
+	state := "OK"
+	if err != nil {
+		state = "ERROR"
+	}
+	golog.OutputSql(state, "%.1fms - %d rows - %s@%s - %s->%s:%s",
+		execTime,
+		c.resultRows,
+		c.user,
+		c.db,
+		c.c.RemoteAddr(),
+		c.proxy.addr,
+		sql,
+	)
+}
+
 func (c *ClientConn) dispatch(data []byte) error {
 	c.proxy.counter.IncrClientQPS()
 	cmd := data[0]
@@ -390,8 +469,10 @@ func (c *ClientConn) dispatch(data []byte) error {
 		return c.handleStmtSendLongData(data)
 	case mysql.COM_STMT_RESET:
 		return c.handleStmtReset(data)
+	case mysql.COM_STMT_FETCH:
+		return c.handleStmtFetch(data)
 	case mysql.COM_SET_OPTION:
-		return c.writeEOF(0)
+		return c.handleSetOption(data)
 	default:
 		msg := fmt.Sprintf("command %d not supported now", cmd)
 		golog.Error("ClientConn", "dispatch", msg, c.connectionId)
@@ -407,6 +488,22 @@ func (c *ClientConn) handleInitDB(dbName string) error {
 	return c.handleUseDB(dbName)
 }
 
+// handleSetOption 客户端在连接后开启或关闭多语句
+func (c *ClientConn) handleSetOption(data []byte) error {
+	if len(data) < 2 {
+		return mysql.NewDefaultError(mysql.ER_MALFORMED_PACKET)
+	}
+	switch binary.LittleEndian.Uint16(data) {
+	case mysql.MYSQL_OPTION_MULTI_STATEMENTS_ON:
+		c.capability |= mysql.CLIENT_MULTI_STATEMENTS
+	case mysql.MYSQL_OPTION_MULTI_STATEMENTS_OFF:
+		c.capability &^= mysql.CLIENT_MULTI_STATEMENTS
+	default:
+		return mysql.NewDefaultError(mysql.ER_UNKNOWN_COM_ERROR)
+	}
+	return c.writeEOF(c.status)
+}
+
 func (c *ClientConn) handleQuit() error {
 	c.handleRollback()
 	c.Close()
@@ -417,6 +514,7 @@ func (c *ClientConn) writeOK(r *mysql.Result) error {
 	if r == nil {
 		r = &mysql.Result{Status: c.status}
 	}
+	status := r.Status | c.moreResults
 	data := make([]byte, 4, 32)
 
 	data = append(data, mysql.OK_HEADER)
@@ -425,12 +523,13 @@ func (c *ClientConn) writeOK(r *mysql.Result) error {
 	data = append(data, mysql.PutLengthEncodedInt(r.InsertId)...)
 
 	if c.capability&mysql.CLIENT_PROTOCOL_41 > 0 {
-		data = append(data, byte(r.Status), byte(r.Status>>8))
+		data = append(data, byte(status), byte(status>>8))
 		data = append(data, 0, 0)
 	}
 
+	c.resultRows = int64(r.AffectedRows)
 	golog.Debug("ClientConn", "writeOK", "result info", c.connectionId,
-		"status", r.Status, "affectedRows", r.AffectedRows, "insertId", r.InsertId)
+		"status", status, "affectedRows", r.AffectedRows, "insertId", r.InsertId)
 	return c.writePacket(data)
 }
 
@@ -457,6 +556,7 @@ func (c *ClientConn) writeError(e error) error {
 }
 
 func (c *ClientConn) writeEOF(status uint16) error {
+	status |= c.moreResults
 	data := make([]byte, 4, 9)
 
 	data = append(data, mysql.EOF_HEADER)
@@ -469,6 +569,7 @@ func (c *ClientConn) writeEOF(status uint16) error {
 }
 
 func (c *ClientConn) writeEOFBatch(total []byte, status uint16, direct bool) ([]byte, error) {
+	status |= c.moreResults
 	data := make([]byte, 4, 9)
 
 	data = append(data, mysql.EOF_HEADER)
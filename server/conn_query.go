@@ -48,7 +48,62 @@ func (c *ClientConn) handleQuery(sql string) (err error) {
 	}()
 	golog.Debug("ClientConn", "handleQuery", sql, c.connectionId)
 
-	sql = strings.TrimRight(sql, ";") //删除sql语句最后的分号
+	if c.capability&mysql.CLIENT_MULTI_STATEMENTS > 0 {
+		if stmts := splitMultiStatements(sql); len(stmts) > 1 {
+			return c.handleMultiStatements(stmts)
+		}
+	}
+	return c.handleStatement(strings.TrimRight(sql, ";")) //删除sql语句最后的分号
+}
+
+// splitMultiStatements 按分号拆分客户端一次发送的多条语句，去掉空语句和只有注释的语句；
+// 无法拆分时按一条语句处理
+func splitMultiStatements(sql string) []string {
+	pieces, err := sqlparser.SplitStatementToPieces(sql)
+	if err != nil {
+		return nil
+	}
+	stmts := make([]string, 0, len(pieces))
+	for _, piece := range pieces {
+		if sqlparser.StripLeadingComments(piece) != "" {
+			stmts = append(stmts, strings.TrimSpace(piece))
+		}
+	}
+	return stmts
+}
+
+// handleMultiStatements 在同一个后端连接或事务上依次执行，除最后一条外的结果都带有
+// SERVER_MORE_RESULTS_EXISTS，遇到错误时停止执行后面的语句
+func (c *ClientConn) handleMultiStatements(stmts []string) error {
+	var resultRows int64
+	defer func() {
+		c.moreResults = 0
+		c.resultRows = resultRows
+	}()
+	for i, sql := range stmts {
+		if i < len(stmts)-1 {
+			c.moreResults = mysql.SERVER_MORE_RESULTS_EXISTS
+		} else {
+			c.moreResults = 0
+		}
+		c.resultRows = 0
+		err := c.handleStatement(sql)
+		resultRows += c.resultRows
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// handleStatement 处理一条语句
+func (c *ClientConn) handleStatement(sql string) (err error) {
+	if err = c.checkBlacklist(sql); err != nil {
+		return err
+	}
+	if err = c.checkFirewall(sql); err != nil {
+		return err
+	}
 
 	var stmt sqlparser.Statement
 	stmt, err = sqlparser.Parse(sql) //解析sql语句,得到的stmt是一个interface
@@ -101,6 +156,31 @@ func (c *ClientConn) handleQuery(sql string) (err error) {
 	}
 }
 
+// checkBlacklist 指纹在黑名单中的语句直接返回错误，不发送到后端
+func (c *ClientConn) checkBlacklist(sql string) error {
+	md5, ok := c.proxy.checkBlackSql(sql)
+	if !ok {
+		return nil
+	}
+	c.proxy.counter.IncrBlacklistTotal()
+	golog.Warn("ClientConn", "checkBlacklist", "sql in blacklist", c.connectionId, "md5", md5, "sql", sql)
+	return mysql.NewError(mysql.ER_NOT_ALLOWED_COMMAND, "sql in blacklist")
+}
+
+// checkFirewall 不在用户白名单中的语句，detect模式下只记录日志，enforce模式下返回错误
+func (c *ClientConn) checkFirewall(sql string) error {
+	md5, ok := c.proxy.firewall.Check(c.user, sql)
+	if ok {
+		return nil
+	}
+	c.proxy.counter.IncrFirewallTotal()
+	golog.Warn("ClientConn", "checkFirewall", "sql not in whitelist", c.connectionId, "user", c.user, "md5", md5, "sql", sql)
+	if c.proxy.firewall.Mode() != FirewallEnforce {
+		return nil
+	}
+	return mysql.NewError(mysql.ER_SPECIFIC_ACCESS_DENIED_ERROR, "sql not in firewall whitelist")
+}
+
 func (c *ClientConn) newEmptyResultset(stmt *sqlparser.Select) *mysql.Resultset {
 	r := new(mysql.Resultset)
 	r.Fields = make([]*mysql.Field, len(stmt.SelectExprs))
@@ -19,9 +19,11 @@ import (
 	"sqlproxy/core/golog"
 	"strconv"
 
+	"sqlproxy/backend"
 	"sqlproxy/core/errors"
 	"sqlproxy/core/hack"
 	"sqlproxy/mysql"
+	"sqlproxy/sqlparser"
 )
 
 func formatValue(value interface{}) ([]byte, error) {
@@ -145,6 +147,7 @@ func (c *ClientConn) buildResultset(fields []*mysql.Field, names []string, value
 
 func (c *ClientConn) writeResultset(status uint16, r *mysql.Resultset) error {
 	c.affectedRows = int64(-1)
+	c.resultRows = int64(len(r.RowDatas))
 	total := make([]byte, 0, 4096)
 	data := make([]byte, 4, 512)
 	var err error
@@ -193,3 +196,174 @@ func (c *ClientConn) writeResultset(status uint16, r *mysql.Resultset) error {
 
 	return nil
 }
+
+// 流式输出时缓冲的字节数，超过后写给客户端
+const rowsFlushSize = 64 * 1024
+
+// rowCursor 逐行读取的后端结果集，流式输出和COM_STMT_FETCH共用，
+// 累计的行数和字节数用于max_result_rows和max_result_size的限制
+type rowCursor struct {
+	rows   *backend.Rows
+	fields []*mysql.Field
+	binary bool
+	count  int64
+	size   int64
+}
+
+func (c *ClientConn) newRowCursor(rows *backend.Rows, stmt sqlparser.SelectStatement, binary bool) *rowCursor {
+	fields := rows.Fields()
+	c.setFieldOrigin(fields, stmt)
+	return &rowCursor{rows: rows, fields: fields, binary: binary}
+}
+
+// writeRows 先写列定义，再从后端逐行读取、编码后分批写给客户端，不在内存中保留整个结果集；
+// 超过max_result_rows或max_result_size时中止并返回错误
+func (c *ClientConn) writeRows(status uint16, rows *backend.Rows, stmt sqlparser.SelectStatement, binary bool) error {
+	c.affectedRows = int64(-1)
+	c.resultRows = 0
+	cursor := c.newRowCursor(rows, stmt, binary)
+
+	total, err := c.writeFields(make([]byte, 0, 4096), cursor.fields, status)
+	if err != nil {
+		return err
+	}
+	total, _, err = c.writeCursorRows(total, cursor, 0)
+	c.resultRows = cursor.count
+	if err != nil {
+		return err
+	}
+
+	_, err = c.writeEOFBatch(total, status, true)
+	if err != nil {
+		return err
+	}
+
+	golog.Debug("ClientConn", "writeRows", "result info", c.connectionId,
+		"status", status, "rows", cursor.count, "bytes", cursor.size)
+
+	return nil
+}
+
+// writeFields 列数、列定义及其后的EOF
+func (c *ClientConn) writeFields(total []byte, fields []*mysql.Field, status uint16) ([]byte, error) {
+	data := make([]byte, 4, 512)
+	var err error
+
+	data = append(data, mysql.PutLengthEncodedInt(uint64(len(fields)))...)
+	total, err = c.writePacketBatch(total, data, false)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, v := range fields {
+		data = data[0:4]
+		data = append(data, v.Dump()...)
+		total, err = c.writePacketBatch(total, data, false)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return c.writeEOFBatch(total, status, false)
+}
+
+// writeCursorRows 从游标中最多读取limit行（0表示读完）追加到total中，超过rowsFlushSize时写给客户端；
+// eof为true表示结果集已经读完，出错时已缓冲的行会先写出
+func (c *ClientConn) writeCursorRows(total []byte, cursor *rowCursor, limit int64) ([]byte, bool, error) {
+	maxRows, maxSize := c.proxy.cfg.MaxResultRows, c.proxy.cfg.MaxResultSize
+	data := make([]byte, 4, 512)
+	var err error
+	for n := int64(0); limit == 0 || n < limit; n++ {
+		if !cursor.rows.Next() {
+			if err = cursor.rows.Err(); err != nil {
+				return nil, false, c.abortRows(total, err)
+			}
+			return total, true, nil
+		}
+		row, err := cursor.rows.Row()
+		if err != nil {
+			return nil, false, c.abortRows(total, err)
+		}
+		rowData, err := mysql.PacketRowData(cursor.fields, row, cursor.binary)
+		if err != nil {
+			return nil, false, c.abortRows(total, err)
+		}
+		cursor.count++
+		cursor.size += int64(len(rowData))
+		if maxRows > 0 && cursor.count > maxRows {
+			return nil, false, c.abortRows(total, mysql.NewError(mysql.ER_TOO_BIG_SELECT,
+				fmt.Sprintf("result set exceeds max_result_rows %d", maxRows)))
+		}
+		if maxSize > 0 && cursor.size > maxSize {
+			return nil, false, c.abortRows(total, mysql.NewError(mysql.ER_TOO_BIG_SELECT,
+				fmt.Sprintf("result set exceeds max_result_size %d bytes", maxSize)))
+		}
+
+		data = data[0:4]
+		data = append(data, rowData...)
+		total, err = c.writePacketBatch(total, data, false)
+		if err != nil {
+			return nil, false, err
+		}
+		if len(total) >= rowsFlushSize {
+			if _, err = c.writePacketBatch(total, nil, true); err != nil {
+				return nil, false, err
+			}
+			total = total[:0]
+		}
+	}
+	return total, false, nil
+}
+
+// setFieldOrigin 单表查询时按select的列填上列所属的库、表和原始列名，表达式列保持为空
+func (c *ClientConn) setFieldOrigin(fields []*mysql.Field, stmt sqlparser.SelectStatement) {
+	sel, ok := stmt.(*sqlparser.Select)
+	if !ok || len(sel.From) != 1 {
+		return
+	}
+	tableExpr, ok := sel.From[0].(*sqlparser.AliasedTableExpr)
+	if !ok {
+		return
+	}
+	tableName, ok := tableExpr.Expr.(sqlparser.TableName)
+	if !ok {
+		return
+	}
+	schema, table, alias := c.db, tableName.Name.String(), tableExpr.As.String()
+	if !tableName.Qualifier.IsEmpty() {
+		schema = tableName.Qualifier.String()
+	}
+	if alias == "" {
+		alias = table
+	}
+	setOrigin := func(f *mysql.Field, column string) {
+		f.Schema, f.Table, f.OrgTable, f.OrgName = []byte(schema), []byte(alias), []byte(table), []byte(column)
+	}
+
+	if len(sel.SelectExprs) == 1 {
+		if _, ok := sel.SelectExprs[0].(*sqlparser.StarExpr); ok {
+			for _, f := range fields {
+				setOrigin(f, string(f.Name))
+			}
+			return
+		}
+	}
+	if len(sel.SelectExprs) != len(fields) {
+		return
+	}
+	for i, expr := range sel.SelectExprs {
+		if aliased, ok := expr.(*sqlparser.AliasedExpr); ok {
+			if col, ok := aliased.Expr.(*sqlparser.ColName); ok {
+				setOrigin(fields[i], col.Name.String())
+			}
+		}
+	}
+}
+
+// abortRows 结果集写到一半出错时，先写出已缓冲的行，返回的错误由调用方作为ERR包写给客户端
+func (c *ClientConn) abortRows(total []byte, err error) error {
+	if _, werr := c.writePacketBatch(total, nil, true); werr != nil {
+		return werr
+	}
+	return err
+}
@@ -47,20 +47,14 @@ func (c *ClientConn) handleUnion(stmt *sqlparser.Union, sql string, args []inter
 		r := c.newEmptyResultset(stmt.Left.(*sqlparser.Select))
 		return c.writeResultset(c.status, r)
 	}
-	rs, err := backend.Query(sql, args...)
+	rows, err := backend.QueryRows(sql, args...)
 	if err != nil {
 		golog.Error("ClientConn", "handleSelect", err.Error(), c.connectionId)
 		return err
 	}
-	status := c.status | rs.Status
-	if rs.Resultset != nil {
-		err = c.writeResultset(status, rs.Resultset)
-	} else {
-		r := c.newEmptyResultset(stmt.Left.(*sqlparser.Select))
-		err = c.writeResultset(status, r)
-	}
+	defer rows.Close()
 
-	return err
+	return c.writeRows(c.status, rows, stmt, false)
 }
 
 // 处理select语句
@@ -75,20 +69,14 @@ func (c *ClientConn) handleSelect(stmt *sqlparser.Select, sql string, args []int
 		r := c.newEmptyResultset(stmt)
 		return c.writeResultset(c.status, r)
 	}
-	rs, err := backend.Query(sql, args...)
+	rows, err := backend.QueryRows(sql, args...)
 	if err != nil {
 		golog.Error("ClientConn", "handleSelect", err.Error(), c.connectionId)
 		return err
 	}
-	status := c.status | rs.Status
-	if rs.Resultset != nil {
-		err = c.writeResultset(status, rs.Resultset)
-	} else {
-		r := c.newEmptyResultset(stmt)
-		err = c.writeResultset(status, r)
-	}
+	defer rows.Close()
 
-	return err
+	return c.writeRows(c.status, rows, stmt, false)
 }
 
 func (c *ClientConn) handleVariableSelect(stmt *sqlparser.Select) error {
@@ -17,7 +17,6 @@ package server
 import (
 	"fmt"
 	"strings"
-	"time"
 
 	"sqlproxy/core/golog"
 	"sqlproxy/mysql"
@@ -31,29 +30,6 @@ func (c *ClientConn) handleSet(stmt *sqlparser.Set, sql string) (err error) {
 		return fmt.Errorf("must set one item once, not %s", nstring(stmt))
 	}
 
-	//log the SQL
-	startTime := time.Now().UnixNano()
-	defer func() {
-		var state string
-		if err != nil {
-			state = "ERROR"
-		} else {
-			state = "OK"
-		}
-		execTime := float64(time.Now().UnixNano()-startTime) / float64(time.Millisecond)
-		if c.proxy.logSql[c.proxy.logSqlIndex] != golog.LogSqlOff &&
-			execTime >= float64(c.proxy.slowLogTime[c.proxy.slowLogTimeIndex]) {
-			c.proxy.counter.IncrSlowLogTotal()
-			golog.OutputSql(state, "%.1fms - %s->%s:%s",
-				execTime,
-				c.c.RemoteAddr(),
-				c.proxy.addr,
-				sql,
-			)
-		}
-
-	}()
-
 	k := string(stmt.Exprs[0].Name.String())
 	switch strings.ToUpper(k) {
 	case `AUTOCOMMIT`, `@@AUTOCOMMIT`, `@@SESSION.AUTOCOMMIT`:
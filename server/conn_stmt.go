@@ -49,18 +49,43 @@ type Stmt struct {
 	s sqlparser.Statement
 
 	sql string
+
+	// 带游标执行时打开的后端结果集，由COM_STMT_FETCH读取
+	cursor *rowCursor
 }
 
 func (s *Stmt) ResetParams() {
 	s.args = make([]interface{}, s.params)
 }
 
+// closeCursor 关闭游标，释放后端连接
+func (s *Stmt) closeCursor() {
+	if s.cursor != nil {
+		s.cursor.rows.Close()
+		s.cursor = nil
+	}
+}
+
+// closeCursors 关闭连接上所有语句的游标，事务提交或回滚前需要先关闭，否则事务会一直等待结果集关闭
+func (c *ClientConn) closeCursors() {
+	for _, s := range c.stmts {
+		s.closeCursor()
+	}
+}
+
 func (c *ClientConn) handleStmtPrepare(sql string) error {
 
 	s := new(Stmt)
 
 	sql = strings.TrimRight(sql, ";")
 
+	if err := c.checkBlacklist(sql); err != nil {
+		return err
+	}
+	if err := c.checkFirewall(sql); err != nil {
+		return err
+	}
+
 	var err error
 	s.s, err = sqlparser.Parse(sql)
 	if err != nil {
@@ -167,10 +192,11 @@ func (c *ClientConn) handleStmtExecute(data []byte) error {
 
 	flag := data[pos]
 	pos++
-	//now we only support CURSOR_TYPE_NO_CURSOR flag
-	if flag != 0 {
+	//only support CURSOR_TYPE_NO_CURSOR and CURSOR_TYPE_READ_ONLY flag
+	if flag != mysql.CURSOR_TYPE_NO_CURSOR && flag != mysql.CURSOR_TYPE_READ_ONLY {
 		return mysql.NewError(mysql.ER_UNKNOWN_ERROR, fmt.Sprintf("unsupported flag %d", flag))
 	}
+	s.closeCursor()
 
 	//skip iteration-count, always 1
 	pos += 4
@@ -211,7 +237,11 @@ func (c *ClientConn) handleStmtExecute(data []byte) error {
 
 	switch stmt := s.s.(type) {
 	case *sqlparser.Select:
-		err = c.handlePrepareSelect(stmt, s.sql, s.args)
+		if flag == mysql.CURSOR_TYPE_READ_ONLY {
+			err = c.handlePrepareCursor(s, stmt)
+		} else {
+			err = c.handlePrepareSelect(stmt, s.sql, s.args)
+		}
 	case *sqlparser.Insert:
 		err = c.handlePrepareExec(s.s, s.sql, s.args)
 	case *sqlparser.Update:
@@ -230,7 +260,6 @@ func (c *ClientConn) handleStmtExecute(data []byte) error {
 }
 
 func (c *ClientConn) handlePrepareSelect(stmt *sqlparser.Select, sql string, args []interface{}) error {
-	var rs *mysql.Result
 	backend := c.GetBackendDB()
 	if backend == nil {
 		golog.Fatal("ClientConn", "handlePrepareSelect", "no backend db", c.connectionId)
@@ -238,20 +267,77 @@ func (c *ClientConn) handlePrepareSelect(stmt *sqlparser.Select, sql string, arg
 		return c.writeResultset(c.status, r)
 	}
 
-	rs, err := backend.StmtQuery(sql, args...)
+	rows, err := backend.QueryRows(sql, args...)
 	if err != nil {
 		golog.Error("ClientConn", "handlePrepareSelect", err.Error(), c.connectionId)
 		return err
 	}
+	defer rows.Close()
 
-	status := c.status | rs.Status
-	if rs.Resultset != nil {
-		err = c.writeResultset(status, rs.Resultset)
-	} else {
+	return c.writeRows(c.status, rows, stmt, true)
+}
+
+// handlePrepareCursor 打开游标，只返回列定义，行由COM_STMT_FETCH按客户端指定的行数读取
+func (c *ClientConn) handlePrepareCursor(s *Stmt, stmt *sqlparser.Select) error {
+	backend := c.GetBackendDB()
+	if backend == nil {
+		golog.Fatal("ClientConn", "handlePrepareCursor", "no backend db", c.connectionId)
 		r := c.newEmptyResultset(stmt)
-		err = c.writeResultset(status, r)
+		return c.writeResultset(c.status, r)
+	}
+
+	rows, err := backend.QueryRows(s.sql, s.args...)
+	if err != nil {
+		golog.Error("ClientConn", "handlePrepareCursor", err.Error(), c.connectionId)
+		return err
+	}
+
+	c.affectedRows = int64(-1)
+	cursor := c.newRowCursor(rows, stmt, true)
+	total, err := c.writeFields(make([]byte, 0, 1024), cursor.fields, c.status|mysql.SERVER_STATUS_CURSOR_EXISTS)
+	if err == nil {
+		_, err = c.writePacketBatch(total, nil, true)
+	}
+	if err != nil {
+		rows.Close()
+		return err
+	}
+	s.cursor = cursor
+	return nil
+}
+
+// handleStmtFetch 从游标中读取指定的行数，读完后关闭游标并在EOF中设置SERVER_STATUS_LAST_ROW_SEND
+func (c *ClientConn) handleStmtFetch(data []byte) error {
+	if len(data) < 8 {
+		return mysql.ErrMalformPacket
+	}
+
+	id := binary.LittleEndian.Uint32(data[0:4])
+	numRows := binary.LittleEndian.Uint32(data[4:8])
+
+	s, ok := c.stmts[id]
+	if !ok {
+		return mysql.NewDefaultError(mysql.ER_UNKNOWN_STMT_HANDLER,
+			strconv.FormatUint(uint64(id), 10), "stmt_fetch")
+	}
+	if s.cursor == nil {
+		return mysql.NewDefaultError(mysql.ER_STMT_HAS_NO_OPEN_CURSOR, id)
 	}
 
+	total, eof, err := c.writeCursorRows(make([]byte, 0, 4096), s.cursor, int64(numRows))
+	if err != nil {
+		s.closeCursor()
+		return err
+	}
+
+	status := c.status | mysql.SERVER_STATUS_CURSOR_EXISTS
+	if eof {
+		golog.Debug("ClientConn", "handleStmtFetch", "cursor closed", c.connectionId,
+			"rows", s.cursor.count, "bytes", s.cursor.size)
+		s.closeCursor()
+		status |= mysql.SERVER_STATUS_LAST_ROW_SEND
+	}
+	_, err = c.writeEOFBatch(total, status, true)
 	return err
 }
 
@@ -452,6 +538,7 @@ func (c *ClientConn) handleStmtReset(data []byte) error {
 	}
 
 	s.ResetParams()
+	s.closeCursor()
 
 	return c.writeOK(nil)
 }
@@ -463,6 +550,9 @@ func (c *ClientConn) handleStmtClose(data []byte) error {
 
 	id := binary.LittleEndian.Uint32(data[0:4])
 
+	if s, ok := c.stmts[id]; ok {
+		s.closeCursor()
+	}
 	delete(c.stmts, id)
 
 	return nil
@@ -15,8 +15,12 @@
 package server
 
 import (
+	"encoding/binary"
+	"fmt"
 	"testing"
 
+	"sqlproxy/mysql"
+
 	"github.com/stretchr/testify/assert"
 )
 
@@ -240,3 +244,94 @@ func TestStmt_Trans(t *testing.T) {
 		}
 	}
 }
+
+func TestStmt_Cursor(t *testing.T) {
+	if _, err := testDB.Exec(`drop table if exists kingshard_test_proxy_cursor`); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := testDB.Exec(`create table kingshard_test_proxy_cursor (id bigint not null, str varchar(32), primary key (id))`); err != nil {
+		t.Fatal(err)
+	}
+	for i := 1; i <= 5; i++ {
+		if _, err := testDB.Exec(fmt.Sprintf(`insert into kingshard_test_proxy_cursor (id, str) values (%d, "s%d")`, i, i)); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	c := newRawConn(t, 0)
+	defer c.Close()
+
+	c.writeCommand(mysql.COM_STMT_PREPARE, []byte(`select id, str from kingshard_test_proxy_cursor where id > ? order by id`))
+	data := c.readPacket()
+	if data[0] != mysql.OK_HEADER {
+		t.Fatal(data)
+	}
+	id := mysql.Uint32ToBytes(binary.LittleEndian.Uint32(data[1:5]))
+	if binary.LittleEndian.Uint16(data[5:7]) > 0 {
+		c.readUntilEOF()
+	}
+	c.readUntilEOF()
+
+	execute := func() []*mysql.Field {
+		// 一个BIGINT参数，值为1
+		args := append(append([]byte{}, id...), mysql.CURSOR_TYPE_READ_ONLY, 1, 0, 0, 0, 0, 1, mysql.MYSQL_TYPE_LONGLONG, 0)
+		args = append(args, mysql.Uint64ToBytes(1)...)
+		c.writeCommand(mysql.COM_STMT_EXECUTE, args)
+		c.readPacket()
+		packets, status := c.readUntilEOF()
+		if status&mysql.SERVER_STATUS_CURSOR_EXISTS == 0 {
+			t.Fatal(status)
+		}
+		fields := make([]*mysql.Field, len(packets))
+		for i, p := range packets {
+			f, err := mysql.FieldData(p).Parse()
+			if err != nil {
+				t.Fatal(err)
+			}
+			fields[i] = f
+		}
+		return fields
+	}
+	fetch := func(fields []*mysql.Field, n uint32) ([]int64, uint16) {
+		c.writeCommand(mysql.COM_STMT_FETCH, append(append([]byte{}, id...), mysql.Uint32ToBytes(n)...))
+		packets, status := c.readUntilEOF()
+		var ids []int64
+		for _, p := range packets {
+			values, err := mysql.RowData(p).ParseBinary(fields)
+			if err != nil {
+				t.Fatal(err)
+			}
+			ids = append(ids, values[0].(int64))
+		}
+		return ids, status
+	}
+
+	fields := execute()
+	if len(fields) != 2 {
+		t.Fatal(len(fields))
+	}
+	ids, status := fetch(fields, 2)
+	if fmt.Sprint(ids) != "[2 3]" || status&mysql.SERVER_STATUS_LAST_ROW_SEND != 0 {
+		t.Fatal(ids, status)
+	}
+	ids, status = fetch(fields, 10)
+	if fmt.Sprint(ids) != "[4 5]" || status&mysql.SERVER_STATUS_LAST_ROW_SEND == 0 {
+		t.Fatal(ids, status)
+	}
+	// 结果读完后游标已关闭
+	c.writeCommand(mysql.COM_STMT_FETCH, append(append([]byte{}, id...), mysql.Uint32ToBytes(1)...))
+	if code := c.readError(); code != mysql.ER_STMT_HAS_NO_OPEN_CURSOR {
+		t.Fatal(code)
+	}
+
+	// COM_STMT_RESET关闭未读完的游标
+	execute()
+	c.writeCommand(mysql.COM_STMT_RESET, id)
+	if data := c.readPacket(); data[0] != mysql.OK_HEADER {
+		t.Fatal(data)
+	}
+	c.writeCommand(mysql.COM_STMT_FETCH, append(append([]byte{}, id...), mysql.Uint32ToBytes(1)...))
+	if code := c.readError(); code != mysql.ER_STMT_HAS_NO_OPEN_CURSOR {
+		t.Fatal(code)
+	}
+}
@@ -15,8 +15,12 @@
 package server
 
 import (
+	"bytes"
+	"fmt"
+	"strings"
 	"testing"
 
+	"sqlproxy/core/golog"
 	. "sqlproxy/mysql"
 )
 
@@ -95,6 +99,34 @@ func TestConn_Select(t *testing.T) {
 	}
 }
 
+func TestConn_ColumnTypes(t *testing.T) {
+	// 文本协议和二进制协议都按后端的类型返回，SQLite的整数按BIGINT返回
+	for _, test := range []struct {
+		args  []interface{}
+		types []string
+	}{
+		{nil, []string{"BIGINT", "TEXT", "DOUBLE", "BIGINT", "BIGINT"}},
+		{[]interface{}{1}, []string{"BIGINT", "TEXT", "DOUBLE", "BIGINT", "BIGINT"}},
+	} {
+		sql := "select id, str, f, u, id + 1 as x from kingshard_test_proxy_conn where id = 1"
+		if test.args != nil {
+			sql = "select id, str, f, u, id + 1 as x from kingshard_test_proxy_conn where id = ?"
+		}
+		rows, err := testDB.QueryRows(sql, test.args...)
+		if err != nil {
+			t.Fatal(err)
+		}
+		types := make([]string, 0, len(test.types))
+		for _, column := range rows.ColumnTypes() {
+			types = append(types, column.DatabaseTypeName())
+		}
+		rows.Close()
+		if strings.Join(types, ",") != strings.Join(test.types, ",") {
+			t.Fatal(types)
+		}
+	}
+}
+
 func TestConn_Update(t *testing.T) {
 	s := `update kingshard_test_proxy_conn set str = "123" where id = 1`
 
@@ -112,6 +144,8 @@ func TestConn_Update(t *testing.T) {
 }
 
 func TestConn_Replace(t *testing.T) {
+	skipSQLite(t, "affected rows of replace do not include the deleted rows")
+
 	s := `replace into kingshard_test_proxy_conn (id, str, f) values(1, 'abc', 3.14159)`
 
 	c := testDB
@@ -172,22 +206,23 @@ func TestConn_Delete(t *testing.T) {
 }
 
 func TestConn_SetAutoCommit(t *testing.T) {
-	c := testDB
+	// 客户端经过database/sql执行时拿不到OK包中的状态位（BackendProxy.Exec返回的Status总是0），
+	// 直接检查服务端连接的状态，写出的OK包不会被testDB的连接读到
+	c := testConn
+	defer c.handleQuery("set autocommit = 1")
 
-	if r, err := c.Exec("set autocommit = 1"); err != nil {
+	if err := c.handleQuery("set autocommit = 1"); err != nil {
 		t.Fatal(err)
-	} else {
-		if !(r.Status&SERVER_STATUS_AUTOCOMMIT > 0) {
-			t.Fatal(r.Status)
-		}
+	}
+	if !(c.status&SERVER_STATUS_AUTOCOMMIT > 0) {
+		t.Fatal(c.status)
 	}
 
-	if r, err := c.Exec("set autocommit = 0"); err != nil {
+	if err := c.handleQuery("set autocommit = 0"); err != nil {
 		t.Fatal(err)
-	} else {
-		if !(r.Status&SERVER_STATUS_AUTOCOMMIT == 0) {
-			t.Fatal(r.Status)
-		}
+	}
+	if !(c.status&SERVER_STATUS_AUTOCOMMIT == 0) {
+		t.Fatal(c.status)
 	}
 }
 
@@ -236,6 +271,8 @@ func TestConn_Trans(t *testing.T) {
 }
 
 func TestConn_LastInsertId(t *testing.T) {
+	skipSQLite(t, "the create table statement can not be parsed for conversion")
+
 	s := `CREATE TABLE IF NOT EXISTS kingshard_test_conn_id (
           id BIGINT(64) UNSIGNED AUTO_INCREMENT NOT NULL,
           str VARCHAR(256),
@@ -283,6 +320,8 @@ func TestConn_LastInsertId(t *testing.T) {
 }
 
 func TestConn_RowCount(t *testing.T) {
+	skipSQLite(t, "row_count() is not supported")
+
 	c := testDB
 
 	r, err := c.Exec(`insert into kingshard_test_proxy_conn (id, str) values (1002, "abc")`)
@@ -316,3 +355,233 @@ func TestConn_SelectVersion(t *testing.T) {
 		t.Fatal(err)
 	}
 }
+
+func TestConn_Blacklist(t *testing.T) {
+	c := testDB
+	black := "select str from kingshard_test_proxy_conn where id = 1"
+	if err := testServer.AddBlackSql(black); err != nil {
+		t.Fatal(err)
+	}
+	defer testServer.DelBlackSql(black)
+
+	// 指纹相同的语句都被拦截，带参数的查询走COM_STMT_PREPARE
+	if _, err := c.Query("select str from kingshard_test_proxy_conn where id = 2"); err == nil || !strings.Contains(err.Error(), "1148") {
+		t.Fatal(err)
+	}
+	if _, err := c.Query("select str from kingshard_test_proxy_conn where id = ?", 3); err == nil || !strings.Contains(err.Error(), "1148") {
+		t.Fatal(err)
+	}
+	if _, err := c.Query("select f from kingshard_test_proxy_conn where id = 1"); err != nil {
+		t.Fatal(err)
+	}
+
+	sqls := testServer.GetAllBlackSqls()
+	if len(sqls) != 1 || sqls[0].Hits != 2 {
+		t.Fatal(sqls)
+	}
+}
+
+func TestConn_Firewall(t *testing.T) {
+	c := testDB
+	if err := testServer.ChangeFirewallMode(FirewallLearning); err != nil {
+		t.Fatal(err)
+	}
+	defer testServer.ChangeFirewallMode(FirewallOff)
+
+	if _, err := c.Query("select str from kingshard_test_proxy_conn where id = 1"); err != nil {
+		t.Fatal(err)
+	}
+	if err := testServer.ChangeFirewallMode(FirewallEnforce); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := c.Query("select str from kingshard_test_proxy_conn where id = 2"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := c.Query("select f from kingshard_test_proxy_conn where id = 1"); err == nil || !strings.Contains(err.Error(), "1227") {
+		t.Fatal(err)
+	}
+	if rules := testServer.GetFirewallRules("testuser", true); len(rules) != 1 {
+		t.Fatal(rules)
+	}
+}
+
+func TestConn_SQLLog(t *testing.T) {
+	var buf bytes.Buffer
+	handler, _ := golog.NewStreamHandler(&buf)
+	sqlLogger := golog.GlobalSqlLogger
+	golog.GlobalSqlLogger = golog.New(handler, golog.Ltime|golog.Llevel)
+
+	c := testDB
+	if _, err := c.Query("select str from kingshard_test_proxy_conn where id = ?", 1); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := c.Exec("update kingshard_test_proxy_conn set f = 3.14 where id = 1"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := c.Exec("update kingshard_test_proxy_conn set nx = 1"); err == nil {
+		t.Fatal("update unknown column")
+	}
+
+	logger := golog.GlobalSqlLogger
+	golog.GlobalSqlLogger = sqlLogger
+	logger.Close()
+
+	log := buf.String()
+	for _, s := range []string{
+		" - OK - ",
+		" - 1 rows - testuser@test - ",
+		"->127.0.0.1:9696:select str from kingshard_test_proxy_conn where id = ?\n",
+		"->127.0.0.1:9696:update kingshard_test_proxy_conn set f = 3.14 where id = 1\n",
+		" - ERROR - ",
+	} {
+		if !strings.Contains(log, s) {
+			t.Fatal(s, log)
+		}
+	}
+}
+
+func TestConn_StreamRows(t *testing.T) {
+	c := testDB
+	if _, err := c.Exec(`CREATE TABLE IF NOT EXISTS kingshard_test_proxy_rows (
+          id BIGINT(64) UNSIGNED NOT NULL,
+          str VARCHAR(256),
+          PRIMARY KEY (id)
+        )`); err != nil {
+		t.Fatal(err)
+	}
+	defer c.Exec(`drop table if exists kingshard_test_proxy_rows`)
+
+	// 超过一次批量写出的大小，需要分多次写给客户端
+	str := strings.Repeat("x", 200)
+	for i := 0; i < 10; i++ {
+		values := make([]string, 0, 100)
+		for j := 0; j < 100; j++ {
+			values = append(values, fmt.Sprintf(`(%d, "%s")`, i*100+j, str))
+		}
+		if _, err := c.Exec("insert into kingshard_test_proxy_rows (id, str) values " + strings.Join(values, ",")); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	r, err := c.Query("select id, str from kingshard_test_proxy_rows order by id")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if r.RowNumber() != 1000 {
+		t.Fatal(r.RowNumber())
+	}
+	if v, _ := r.GetUint(999, 0); v != 999 {
+		t.Fatal(v)
+	}
+	if v, _ := r.GetString(999, 1); v != str {
+		t.Fatal(v)
+	}
+	if r, err = c.Query("select id, str from kingshard_test_proxy_rows where id >= ?", 500); err != nil {
+		t.Fatal(err)
+	}
+	if r.RowNumber() != 500 {
+		t.Fatal(r.RowNumber())
+	}
+
+	testServer.cfg.MaxResultRows = 100
+	_, err = c.Query("select id, str from kingshard_test_proxy_rows")
+	testServer.cfg.MaxResultRows = 0
+	if err == nil || !strings.Contains(err.Error(), "1104") {
+		t.Fatal(err)
+	}
+
+	testServer.cfg.MaxResultSize = 64 * 1024
+	_, err = c.Query("select id, str from kingshard_test_proxy_rows where id >= ?", 0)
+	testServer.cfg.MaxResultSize = 0
+	if err == nil || !strings.Contains(err.Error(), "1104") {
+		t.Fatal(err)
+	}
+
+	// 中止后连接仍然可用
+	if r, err = c.Query("select id from kingshard_test_proxy_rows where id = 1"); err != nil || r.RowNumber() != 1 {
+		t.Fatal(err)
+	}
+}
+
+func TestConn_MultiStatements(t *testing.T) {
+	if _, err := testDB.Exec(`CREATE TABLE IF NOT EXISTS kingshard_test_proxy_multi (
+          id BIGINT(64) NOT NULL,
+          PRIMARY KEY (id)
+        )`); err != nil {
+		t.Fatal(err)
+	}
+	defer testDB.Exec(`drop table if exists kingshard_test_proxy_multi`)
+
+	count := func() string {
+		r, err := testDB.Query(`select id from kingshard_test_proxy_multi order by id`)
+		if err != nil {
+			t.Fatal(err)
+		}
+		ids := make([]string, 0, r.RowNumber())
+		for i := 0; i < r.RowNumber(); i++ {
+			id, _ := r.GetString(i, 0)
+			ids = append(ids, id)
+		}
+		return strings.Join(ids, ",")
+	}
+
+	// 没有开启多语句时按一条语句解析
+	c := newRawConn(t, 0)
+	c.writeCommand(COM_QUERY, []byte(`insert into kingshard_test_proxy_multi (id) values (1); insert into kingshard_test_proxy_multi (id) values (2)`))
+	c.readError()
+	c.Close()
+
+	c = newRawConn(t, CLIENT_MULTI_STATEMENTS|CLIENT_MULTI_RESULTS)
+	defer c.Close()
+
+	c.writeCommand(COM_QUERY, []byte(`insert into kingshard_test_proxy_multi (id) values (1);
+		insert into kingshard_test_proxy_multi (id) values (2);
+		select id from kingshard_test_proxy_multi order by id; /* end */`))
+	for i := 0; i < 2; i++ {
+		if status := c.readOK(); status&SERVER_MORE_RESULTS_EXISTS == 0 {
+			t.Fatal(i, status)
+		}
+	}
+	c.readPacket()
+	if _, status := c.readUntilEOF(); status&SERVER_MORE_RESULTS_EXISTS != 0 {
+		t.Fatal(status)
+	}
+	if rows, status := c.readUntilEOF(); len(rows) != 2 || status&SERVER_MORE_RESULTS_EXISTS != 0 {
+		t.Fatal(len(rows), status)
+	}
+
+	// 遇到错误时不再执行后面的语句
+	c.writeCommand(COM_QUERY, []byte(`insert into kingshard_test_proxy_multi (id) values (3);
+		insert into kingshard_test_proxy_multi (id) values (1);
+		insert into kingshard_test_proxy_multi (id) values (4)`))
+	if status := c.readOK(); status&SERVER_MORE_RESULTS_EXISTS == 0 {
+		t.Fatal(status)
+	}
+	c.readError()
+	if ids := count(); ids != "1,2,3" {
+		t.Fatal(ids)
+	}
+
+	// 在同一个事务中执行
+	c.writeCommand(COM_QUERY, []byte(`begin; delete from kingshard_test_proxy_multi; rollback`))
+	for i := 0; i < 2; i++ {
+		if status := c.readOK(); status&SERVER_MORE_RESULTS_EXISTS == 0 {
+			t.Fatal(i, status)
+		}
+	}
+	if status := c.readOK(); status&SERVER_MORE_RESULTS_EXISTS != 0 {
+		t.Fatal(status)
+	}
+	if ids := count(); ids != "1,2,3" {
+		t.Fatal(ids)
+	}
+
+	// COM_SET_OPTION关闭多语句
+	c.writeCommand(COM_SET_OPTION, Uint16ToBytes(MYSQL_OPTION_MULTI_STATEMENTS_OFF))
+	c.readUntilEOF()
+	c.writeCommand(COM_QUERY, []byte(`delete from kingshard_test_proxy_multi; delete from kingshard_test_proxy_multi`))
+	c.readError()
+	if ids := count(); ids != "1,2,3" {
+		t.Fatal(ids)
+	}
+}
@@ -37,6 +37,7 @@ func (c *ClientConn) handleBegin() error {
 	// 异常处理，前一个事务未释放，又开启一个新事务，需要先把前一个事务提交
 	if c.txConn != nil {
 		golog.Info("ClientConn", "handleBegin", "txConn is not nil, try to commit first.", c.connectionId)
+		c.closeCursors()
 		if err := c.txConn.Commit(); err != nil {
 			golog.Warn("ClientConn", "handleBegin", err.Error(), c.connectionId)
 		}
@@ -78,6 +79,7 @@ func (c *ClientConn) commit() (err error) {
 		golog.Warn("ClientConn", "commit", "txConn is nil", c.connectionId)
 		return
 	}
+	c.closeCursors()
 	if err = c.txConn.Commit(); err != nil {
 		return err
 	}
@@ -92,6 +94,7 @@ func (c *ClientConn) rollback() (err error) {
 		golog.Warn("ClientConn", "rollback", "txConn is nil", c.connectionId)
 		return
 	}
+	c.closeCursors()
 	if err = c.txConn.Rollback(); err != nil {
 		return err
 	}
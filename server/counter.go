@@ -27,6 +27,10 @@ type Counter struct {
 	ClientQPS    int64
 	ErrLogTotal  int64
 	SlowLogTotal int64
+	// 被黑名单拦截的语句数
+	BlacklistTotal int64
+	// 不在防火墙白名单中的语句数
+	FirewallTotal int64
 }
 
 func (counter *Counter) IncrClientConns() {
@@ -49,6 +53,14 @@ func (counter *Counter) IncrSlowLogTotal() {
 	atomic.AddInt64(&counter.SlowLogTotal, 1)
 }
 
+func (counter *Counter) IncrBlacklistTotal() {
+	atomic.AddInt64(&counter.BlacklistTotal, 1)
+}
+
+func (counter *Counter) IncrFirewallTotal() {
+	atomic.AddInt64(&counter.FirewallTotal, 1)
+}
+
 //flush the count per second
 func (counter *Counter) FlushCounter() {
 	atomic.StoreInt64(&counter.OldClientQPS, counter.ClientQPS)
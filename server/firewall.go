@@ -0,0 +1,257 @@
+package server
+
+import (
+	"io/ioutil"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"gopkg.in/yaml.v2"
+
+	"sqlproxy/core/errors"
+	"sqlproxy/core/golog"
+	"sqlproxy/mysql"
+)
+
+// 防火墙的模式
+const (
+	FirewallOff      = "off"
+	FirewallLearning = "learning" // 记录每个用户执行过的指纹，自动加入白名单
+	FirewallDetect   = "detect"   // 白名单以外的指纹只记录日志
+	FirewallEnforce  = "enforce"  // 拒绝白名单以外的指纹
+)
+
+// FirewallMaxPending 待审核指纹的上限，超过后新的指纹不再记录（enforce模式下仍然拒绝），
+// 避免大量只有字面量以外部分不同的语句占满内存和firewall_file
+const FirewallMaxPending = 10000
+
+// FirewallRule 用户执行过的一个SQL指纹，Approved为false时等待审核
+type FirewallRule struct {
+	User        string    `yaml:"user" json:"user"`
+	Fingerprint string    `yaml:"fingerprint" json:"fingerprint"`
+	Md5         string    `yaml:"md5" json:"md5"`
+	SQL         string    `yaml:"sql" json:"sql"` // 第一次出现时的语句
+	Approved    bool      `yaml:"approved" json:"approved"`
+	FirstSeen   time.Time `yaml:"first_seen" json:"first_seen"`
+	Hits        int64     `yaml:"-" json:"hits"` // 启动以来的执行次数
+}
+
+// Firewall 按用户和SQL指纹的白名单，学习到的指纹保存在firewall_file中
+type Firewall struct {
+	mode  atomic.Value // string
+	file  string
+	dirty int32 // 有未保存的变化
+	full  int32 // 待审核的指纹已达到上限，只在第一次达到时记录日志
+
+	mu         sync.RWMutex
+	rules      map[string]*FirewallRule // user/md5 -> rule
+	pending    int                      // 待审核的指纹数
+	maxPending int
+}
+
+func firewallKey(user, md5 string) string {
+	return user + "/" + md5
+}
+
+func checkFirewallMode(mode string) (string, error) {
+	if mode == "" {
+		return FirewallOff, nil
+	}
+	mode = strings.ToLower(mode)
+	switch mode {
+	case FirewallOff, FirewallLearning, FirewallDetect, FirewallEnforce:
+		return mode, nil
+	}
+	return "", errors.ErrFirewallMode
+}
+
+// NewFirewall 从文件加载白名单，文件不存在时从空的白名单开始学习
+func NewFirewall(mode, file string) (*Firewall, error) {
+	mode, err := checkFirewallMode(mode)
+	if err != nil {
+		return nil, err
+	}
+	f := &Firewall{file: file, rules: make(map[string]*FirewallRule), maxPending: FirewallMaxPending}
+	f.mode.Store(mode)
+	if file == "" {
+		return f, nil
+	}
+	data, err := ioutil.ReadFile(file)
+	if os.IsNotExist(err) {
+		return f, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	var rules []*FirewallRule
+	if err := yaml.Unmarshal(data, &rules); err != nil {
+		return nil, err
+	}
+	for _, r := range rules {
+		f.rules[firewallKey(r.User, r.Md5)] = r
+		if !r.Approved {
+			f.pending++
+		}
+	}
+	return f, nil
+}
+
+func (f *Firewall) Mode() string {
+	return f.mode.Load().(string)
+}
+
+func (f *Firewall) SetMode(mode string) error {
+	mode, err := checkFirewallMode(mode)
+	if err != nil {
+		return err
+	}
+	f.mode.Store(mode)
+	return nil
+}
+
+// Check 检查用户的SQL是否在白名单中，learning模式下新的指纹直接加入白名单，
+// detect和enforce模式下新的指纹记录为待审核，待审核的指纹达到上限后不再记录；返回false表示不在白名单中
+func (f *Firewall) Check(user, sql string) (string, bool) {
+	mode := f.Mode()
+	if mode == FirewallOff {
+		return "", true
+	}
+	fingerprint := mysql.GetFingerprint(sql)
+	md5 := mysql.GetMd5(fingerprint)
+	key := firewallKey(user, md5)
+
+	f.mu.RLock()
+	rule, ok := f.rules[key]
+	approved := ok && rule.Approved
+	f.mu.RUnlock()
+	if !ok {
+		f.mu.Lock()
+		if rule, ok = f.rules[key]; !ok {
+			if mode != FirewallLearning && f.pending >= f.maxPending {
+				f.mu.Unlock()
+				if atomic.CompareAndSwapInt32(&f.full, 0, 1) {
+					golog.Warn("Firewall", "Check", "too many pending fingerprints, new fingerprints are not recorded", 0,
+						"max_pending", f.maxPending,
+					)
+				}
+				return md5, false
+			}
+			rule = &FirewallRule{
+				User:        user,
+				Fingerprint: fingerprint,
+				Md5:         md5,
+				SQL:         sql,
+				Approved:    mode == FirewallLearning,
+				FirstSeen:   time.Now(),
+			}
+			f.rules[key] = rule
+			if !rule.Approved {
+				f.pending++
+			}
+			atomic.StoreInt32(&f.dirty, 1)
+		}
+		approved = rule.Approved
+		f.mu.Unlock()
+	}
+	atomic.AddInt64(&rule.Hits, 1)
+	return md5, approved || mode == FirewallLearning
+}
+
+// List 按用户和指纹排序，user为空时返回全部用户的，pending为true时只返回待审核的
+func (f *Firewall) List(user string, pending bool) []*FirewallRule {
+	f.mu.RLock()
+	list := make([]*FirewallRule, 0, len(f.rules))
+	for _, r := range f.rules {
+		if user != "" && r.User != user || pending && r.Approved {
+			continue
+		}
+		list = append(list, &FirewallRule{
+			User:        r.User,
+			Fingerprint: r.Fingerprint,
+			Md5:         r.Md5,
+			SQL:         r.SQL,
+			Approved:    r.Approved,
+			FirstSeen:   r.FirstSeen,
+			Hits:        atomic.LoadInt64(&r.Hits),
+		})
+	}
+	f.mu.RUnlock()
+	sort.Slice(list, func(i, j int) bool {
+		if list[i].User != list[j].User {
+			return list[i].User < list[j].User
+		}
+		return list[i].Fingerprint < list[j].Fingerprint
+	})
+	return list
+}
+
+// Approve 将用户的指纹加入白名单
+func (f *Firewall) Approve(user string, md5s ...string) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	for _, md5 := range md5s {
+		if _, ok := f.rules[firewallKey(user, md5)]; !ok {
+			return errors.ErrFirewallNotExist
+		}
+	}
+	for _, md5 := range md5s {
+		rule := f.rules[firewallKey(user, md5)]
+		if !rule.Approved {
+			rule.Approved = true
+			f.pending--
+		}
+	}
+	f.checkPending()
+	atomic.StoreInt32(&f.dirty, 1)
+	return nil
+}
+
+// Delete 删除用户的指纹，删除后再次执行时按当前模式重新学习或记录为待审核
+func (f *Firewall) Delete(user string, md5s ...string) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	for _, md5 := range md5s {
+		if _, ok := f.rules[firewallKey(user, md5)]; !ok {
+			return errors.ErrFirewallNotExist
+		}
+	}
+	for _, md5 := range md5s {
+		key := firewallKey(user, md5)
+		if rule, ok := f.rules[key]; ok && !rule.Approved {
+			f.pending--
+		}
+		delete(f.rules, key)
+	}
+	f.checkPending()
+	atomic.StoreInt32(&f.dirty, 1)
+	return nil
+}
+
+// checkPending 审核或删除后低于上限时，再次达到上限时重新记录日志，调用时持有f.mu
+func (f *Firewall) checkPending() {
+	if f.pending < f.maxPending {
+		atomic.StoreInt32(&f.full, 0)
+	}
+}
+
+// Save 有变化时保存到firewall_file
+func (f *Firewall) Save() error {
+	if f.file == "" || !atomic.CompareAndSwapInt32(&f.dirty, 1, 0) {
+		return nil
+	}
+	data, err := yaml.Marshal(f.List("", false))
+	if err == nil {
+		err = ioutil.WriteFile(f.file, data, 0644)
+	}
+	if err != nil {
+		atomic.StoreInt32(&f.dirty, 1)
+		golog.Error("Firewall", "Save", "save file error", 0,
+			"err", err.Error(),
+			"firewall_file", f.file,
+		)
+	}
+	return err
+}
@@ -0,0 +1,80 @@
+package server
+
+import (
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"sqlproxy/core/errors"
+)
+
+func TestFirewall(t *testing.T) {
+	file := filepath.Join(t.TempDir(), "firewall.yaml")
+	f, err := NewFirewall("Learning", file)
+	assert.Nil(t, err)
+	assert.Equal(t, FirewallLearning, f.Mode())
+
+	_, ok := f.Check("u1", "select a from t1 where id = 1")
+	assert.True(t, ok)
+	_, ok = f.Check("u1", "select a from t1 where id = 2")
+	assert.True(t, ok)
+	assert.Len(t, f.List("u1", false), 1)
+	assert.Equal(t, int64(2), f.List("u1", false)[0].Hits)
+
+	assert.Nil(t, f.SetMode(FirewallEnforce))
+	_, ok = f.Check("u1", "select a from t1 where id = 3")
+	assert.True(t, ok)
+	// 白名单按用户区分，新的指纹记录为待审核
+	md5, ok := f.Check("u2", "select a from t1 where id = 3")
+	assert.False(t, ok)
+	_, ok = f.Check("u1", "delete from t1")
+	assert.False(t, ok)
+	assert.Len(t, f.List("", true), 2)
+
+	assert.Nil(t, f.Approve("u2", md5))
+	_, ok = f.Check("u2", "select a from t1 where id = 4")
+	assert.True(t, ok)
+	assert.Equal(t, errors.ErrFirewallNotExist, f.Approve("u3", md5))
+
+	assert.Nil(t, f.Save())
+	loaded, err := NewFirewall(FirewallDetect, file)
+	assert.Nil(t, err)
+	assert.Len(t, loaded.List("", false), 3)
+	assert.Len(t, loaded.List("", true), 1)
+
+	assert.Nil(t, loaded.Delete("u2", md5))
+	_, ok = loaded.Check("u2", "select a from t1 where id = 5")
+	assert.False(t, ok)
+
+	_, err = NewFirewall("block", "")
+	assert.Equal(t, errors.ErrFirewallMode, err)
+}
+
+func TestFirewallMaxPending(t *testing.T) {
+	f, err := NewFirewall(FirewallEnforce, "")
+	assert.Nil(t, err)
+	f.maxPending = 2
+
+	md5, ok := f.Check("u1", "select a from t1")
+	assert.False(t, ok)
+	_, ok = f.Check("u1", "select b from t1")
+	assert.False(t, ok)
+	// 达到上限后新的指纹不再记录，仍然拒绝
+	_, ok = f.Check("u2", "select c from t1")
+	assert.False(t, ok)
+	assert.Len(t, f.List("", true), 2)
+
+	// learning模式下加入白名单的指纹不受限制
+	assert.Nil(t, f.SetMode(FirewallLearning))
+	_, ok = f.Check("u2", "select c from t1")
+	assert.True(t, ok)
+	assert.Len(t, f.List("", false), 3)
+
+	// 审核后低于上限，可以继续记录
+	assert.Nil(t, f.SetMode(FirewallDetect))
+	assert.Nil(t, f.Approve("u1", md5))
+	_, ok = f.Check("u2", "select d from t1")
+	assert.False(t, ok)
+	assert.Len(t, f.List("", true), 2)
+}
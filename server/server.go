@@ -21,6 +21,7 @@ import (
 	"net"
 	"os"
 	"runtime"
+	"sort"
 	"strconv"
 	"strings"
 	"sync/atomic"
@@ -39,9 +40,42 @@ import (
 
 type BlacklistSqls struct {
 	sqls    map[string]string
+	hits    map[string]*int64 // 每条被拦截的次数，增删时复制指针，次数保留
 	sqlsLen int
 }
 
+// BlackSql 黑名单中的一条及被拦截的次数
+type BlackSql struct {
+	SQL  string `json:"sql"`
+	Hits int64  `json:"hits"`
+}
+
+func newBlacklistSqls() *BlacklistSqls {
+	return &BlacklistSqls{
+		sqls: make(map[string]string),
+		hits: make(map[string]*int64),
+	}
+}
+
+// clone 增删时修改副本再切换下标，正在检查的连接读到的仍是旧的黑名单
+func (bs *BlacklistSqls) clone() *BlacklistSqls {
+	c := newBlacklistSqls()
+	for md5, sql := range bs.sqls {
+		c.sqls[md5] = sql
+		c.hits[md5] = bs.hits[md5]
+	}
+	c.sqlsLen = bs.sqlsLen
+	return c
+}
+
+func (bs *BlacklistSqls) add(md5, sql string) {
+	bs.sqls[md5] = sql
+	if bs.hits[md5] == nil {
+		bs.hits[md5] = new(int64)
+	}
+	bs.sqlsLen = len(bs.sqls)
+}
+
 const (
 	Offline = iota
 	Online
@@ -67,6 +101,7 @@ type Server struct {
 	blacklistSqls      [2]*BlacklistSqls
 	allowipsIndex      BoolIndex
 	allowips           [2][]IPInfo
+	firewall           *Firewall
 
 	counter *Counter
 	nodes   map[string]*backend.BackendProxy // dbname -> node
@@ -112,8 +147,7 @@ func parseAllowIps(allowIpsStr string) ([]IPInfo, error) {
 
 // parse the blacklist sql file
 func parseBlackListSqls(blackListFilePath string) (*BlacklistSqls, error) {
-	bs := new(BlacklistSqls)
-	bs.sqls = make(map[string]string)
+	bs := newBlacklistSqls()
 	if len(blackListFilePath) != 0 {
 		file, err := os.Open(blackListFilePath)
 		if err != nil {
@@ -129,7 +163,7 @@ func parseBlackListSqls(blackListFilePath string) (*BlacklistSqls, error) {
 				if len(line) != 0 {
 					fingerPrint := mysql.GetFingerprint(line)
 					md5 := mysql.GetMd5(fingerPrint)
-					bs.sqls[md5] = fingerPrint
+					bs.add(md5, fingerPrint)
 				}
 				break
 			}
@@ -140,11 +174,10 @@ func parseBlackListSqls(blackListFilePath string) (*BlacklistSqls, error) {
 			if len(line) != 0 {
 				fingerPrint := mysql.GetFingerprint(line)
 				md5 := mysql.GetMd5(fingerPrint)
-				bs.sqls[md5] = fingerPrint
+				bs.add(md5, fingerPrint)
 			}
 		}
 	}
-	bs.sqlsLen = len(bs.sqls)
 
 	return bs, nil
 }
@@ -168,6 +201,9 @@ func parseNodes(cfgNodes []config.NodeConfig) (map[string]*backend.BackendProxy,
 
 		n, err := parseNode(v)
 		if err != nil {
+			for _, db := range dbs {
+				db.Close()
+			}
 			return nil, err
 		}
 		dbs[v.Name] = n
@@ -234,6 +270,20 @@ func NewServer(cfg *config.Config) (*Server, error) {
 	}
 	atomic.StoreInt32(&s.blacklistSqlsIndex, 0)
 
+	//init sql rewrites
+	if rewrites, err := backend.LoadSQLRewrites(s.cfg.RewriteFile); err != nil {
+		return nil, err
+	} else {
+		backend.Rewrites.Reset(rewrites)
+	}
+
+	//init firewall
+	if firewall, err := NewFirewall(s.cfg.FirewallMode, s.cfg.FirewallFile); err != nil {
+		return nil, err
+	} else {
+		s.firewall = firewall
+	}
+
 	//init allow ip list
 	if allowIps, err := parseAllowIps(s.cfg.AllowIps); err != nil {
 		return nil, err
@@ -285,6 +335,14 @@ func (s *Server) flushCounter() {
 	}
 }
 
+// saveFirewall 定时保存学习到的指纹
+func (s *Server) saveFirewall() {
+	for s.running {
+		time.Sleep(10 * time.Second)
+		s.firewall.Save()
+	}
+}
+
 func (s *Server) newClientConn(co net.Conn) *ClientConn {
 	c := new(ClientConn)
 	tcpConn := co.(*net.TCPConn)
@@ -478,35 +536,46 @@ func (s *Server) DelAllowIP(v string) error {
 	return nil
 }
 
-func (s *Server) GetAllBlackSqls() []string {
-	blackSQLs := make([]string, 0, 10)
-	for _, SQL := range s.blacklistSqls[s.blacklistSqlsIndex].sqls {
-		blackSQLs = append(blackSQLs, SQL)
+// GetAllBlackSqls 黑名单及每条被拦截的次数
+func (s *Server) GetAllBlackSqls() []*BlackSql {
+	bs := s.blacklistSqls[atomic.LoadInt32(&s.blacklistSqlsIndex)]
+	blackSQLs := make([]*BlackSql, 0, len(bs.sqls))
+	for md5, SQL := range bs.sqls {
+		blackSQLs = append(blackSQLs, &BlackSql{SQL: SQL, Hits: atomic.LoadInt64(bs.hits[md5])})
 	}
+	sort.Slice(blackSQLs, func(i, j int) bool {
+		return blackSQLs[i].SQL < blackSQLs[j].SQL
+	})
 	return blackSQLs
 }
 
+// checkBlackSql 按指纹检查SQL是否在黑名单中，命中时增加该条的拦截次数
+func (s *Server) checkBlackSql(sql string) (string, bool) {
+	bs := s.blacklistSqls[atomic.LoadInt32(&s.blacklistSqlsIndex)]
+	if bs.sqlsLen == 0 {
+		return "", false
+	}
+	md5 := mysql.GetMd5(mysql.GetFingerprint(sql))
+	if _, ok := bs.sqls[md5]; !ok {
+		return "", false
+	}
+	atomic.AddInt64(bs.hits[md5], 1)
+	return md5, true
+}
+
 func (s *Server) AddBlackSql(v string) error {
 	v = strings.TrimSpace(v)
 	fingerPrint := mysql.GetFingerprint(v)
 	md5 := mysql.GetMd5(fingerPrint)
-	if s.blacklistSqlsIndex == 0 {
-		if _, ok := s.blacklistSqls[0].sqls[md5]; ok {
-			return errors.ErrBlackSqlExist
-		}
-		s.blacklistSqls[1] = s.blacklistSqls[0]
-		s.blacklistSqls[1].sqls[md5] = v
-		s.blacklistSqls[1].sqlsLen += 1
-		atomic.StoreInt32(&s.blacklistSqlsIndex, 1)
-	} else {
-		if _, ok := s.blacklistSqls[1].sqls[md5]; ok {
-			return errors.ErrBlackSqlExist
-		}
-		s.blacklistSqls[0] = s.blacklistSqls[1]
-		s.blacklistSqls[0].sqls[md5] = v
-		s.blacklistSqls[0].sqlsLen += 1
-		atomic.StoreInt32(&s.blacklistSqlsIndex, 0)
+
+	index := atomic.LoadInt32(&s.blacklistSqlsIndex)
+	if _, ok := s.blacklistSqls[index].sqls[md5]; ok {
+		return errors.ErrBlackSqlExist
 	}
+	bs := s.blacklistSqls[index].clone()
+	bs.add(md5, v)
+	s.blacklistSqls[1-index] = bs
+	atomic.StoreInt32(&s.blacklistSqlsIndex, 1-index)
 
 	return nil
 }
@@ -516,29 +585,75 @@ func (s *Server) DelBlackSql(v string) error {
 	fingerPrint := mysql.GetFingerprint(v)
 	md5 := mysql.GetMd5(fingerPrint)
 
-	if s.blacklistSqlsIndex == 0 {
-		if _, ok := s.blacklistSqls[0].sqls[md5]; !ok {
-			return errors.ErrBlackSqlNotExist
-		}
-		s.blacklistSqls[1] = s.blacklistSqls[0]
-		s.blacklistSqls[1].sqls[md5] = v
-		delete(s.blacklistSqls[1].sqls, md5)
-		s.blacklistSqls[1].sqlsLen -= 1
-		atomic.StoreInt32(&s.blacklistSqlsIndex, 1)
-	} else {
-		if _, ok := s.blacklistSqls[1].sqls[md5]; !ok {
-			return errors.ErrBlackSqlNotExist
-		}
-		s.blacklistSqls[0] = s.blacklistSqls[1]
-		s.blacklistSqls[0].sqls[md5] = v
-		delete(s.blacklistSqls[0].sqls, md5)
-		s.blacklistSqls[0].sqlsLen -= 1
-		atomic.StoreInt32(&s.blacklistSqlsIndex, 0)
+	index := atomic.LoadInt32(&s.blacklistSqlsIndex)
+	if _, ok := s.blacklistSqls[index].sqls[md5]; !ok {
+		return errors.ErrBlackSqlNotExist
 	}
+	bs := s.blacklistSqls[index].clone()
+	delete(bs.sqls, md5)
+	delete(bs.hits, md5)
+	bs.sqlsLen = len(bs.sqls)
+	s.blacklistSqls[1-index] = bs
+	atomic.StoreInt32(&s.blacklistSqlsIndex, 1-index)
+
+	return nil
+}
+
+// GetSQLRewrites 按指纹手工指定的改写
+func (s *Server) GetSQLRewrites() []*backend.SQLRewrite {
+	return backend.Rewrites.List()
+}
+
+func (s *Server) AddSQLRewrite(r *backend.SQLRewrite) error {
+	if r.Node != "" && s.GetNode(r.Node) == nil {
+		return errors.ErrNodeNotExist
+	}
+	return backend.Rewrites.Add(r)
+}
+
+func (s *Server) DelSQLRewrite(node, sql string) error {
+	return backend.Rewrites.Delete(node, sql)
+}
+
+func (s *Server) GetFirewallMode() string {
+	return s.firewall.Mode()
+}
 
+func (s *Server) ChangeFirewallMode(mode string) error {
+	if err := s.firewall.SetMode(mode); err != nil {
+		return err
+	}
+	s.cfg.FirewallMode = s.firewall.Mode()
 	return nil
 }
 
+// GetFirewallRules 防火墙学习到的指纹，pending为true时只返回待审核的
+func (s *Server) GetFirewallRules(user string, pending bool) []*FirewallRule {
+	return s.firewall.List(user, pending)
+}
+
+func (s *Server) ApproveFirewallRules(user string, md5s []string) error {
+	return s.firewall.Approve(user, md5s...)
+}
+
+func (s *Server) DelFirewallRules(user string, md5s []string) error {
+	return s.firewall.Delete(user, md5s...)
+}
+
+func (s *Server) saveSQLRewrites() error {
+	if len(s.cfg.RewriteFile) == 0 {
+		return nil
+	}
+	err := backend.Rewrites.Save(s.cfg.RewriteFile)
+	if err != nil {
+		golog.Error("Server", "saveSQLRewrites", "save file error", 0,
+			"err", err.Error(),
+			"rewrite_sql_file", s.cfg.RewriteFile,
+		)
+	}
+	return err
+}
+
 func (s *Server) saveBlackSql() error {
 	if len(s.cfg.BlsFile) == 0 {
 		return nil
@@ -574,6 +689,16 @@ func (s *Server) SaveProxyConfig() error {
 		return err
 	}
 
+	err = s.saveSQLRewrites()
+	if err != nil {
+		return err
+	}
+
+	err = s.firewall.Save()
+	if err != nil {
+		return err
+	}
+
 	return nil
 }
 
@@ -582,6 +707,7 @@ func (s *Server) Run() error {
 
 	// flush counter
 	go s.flushCounter()
+	go s.saveFirewall()
 
 	for s.running {
 		conn, err := s.listener.Accept()
@@ -601,6 +727,7 @@ func (s *Server) Close() {
 	if s.listener != nil {
 		s.listener.Close()
 	}
+	s.firewall.Save()
 }
 
 func (s *Server) GetNode(name string) *backend.BackendProxy {
@@ -608,6 +735,41 @@ func (s *Server) GetNode(name string) *backend.BackendProxy {
 	return s.nodes[name]
 }
 
+// ReloadNodeMetadata 重新加载node的表结构信息
+func (s *Server) ReloadNodeMetadata(name string) error {
+	node := s.GetNode(name)
+	if node == nil {
+		return errors.ErrNodeNotExist
+	}
+	err := node.ReloadMetadata()
+	if err != nil {
+		golog.Error("Server", "ReloadNodeMetadata", err.Error(), 0, "node", name)
+		return err
+	}
+	golog.Info("Server", "ReloadNodeMetadata", "reload metadata success", 0, "node", name)
+	return nil
+}
+
+// DumpNodeMetadata 导出node的表结构信息，用于离线转换
+func (s *Server) DumpNodeMetadata(name string) (*backend.SchemaDump, error) {
+	node := s.GetNode(name)
+	if node == nil {
+		return nil, errors.ErrNodeNotExist
+	}
+	return node.DumpMetadata()
+}
+
+// GetConvertCacheStats 各node转换缓存的统计信息
+func (s *Server) GetConvertCacheStats() map[string]*backend.ConvertCacheStats {
+	stats := make(map[string]*backend.ConvertCacheStats)
+	for name, node := range s.nodes {
+		if st := node.ConvertCacheStats(); st != nil {
+			stats[name] = st
+		}
+	}
+	return stats
+}
+
 // func (s *Server) GetAllNodes() map[string]*backend.Node {
 // 	return s.nodes
 // }
@@ -649,6 +811,12 @@ func (s *Server) UpdateConfig(newCfg *config.Config) {
 		return
 	}
 
+	newRewrites, err := backend.LoadSQLRewrites(newCfg.RewriteFile)
+	if nil != err {
+		golog.Error("Server", "UpdateConfig", err.Error(), 0)
+		return
+	}
+
 	newAllowIps, err := parseAllowIps(newCfg.AllowIps)
 	if nil != err {
 		golog.Error("Server", "UpdateConfig", err.Error(), 0)
@@ -696,6 +864,12 @@ func (s *Server) UpdateConfig(newCfg *config.Config) {
 		atomic.StoreInt32(&s.blacklistSqlsIndex, 0)
 	}
 
+	backend.Rewrites.Reset(newRewrites)
+
+	if err := s.firewall.SetMode(newCfg.FirewallMode); err != nil {
+		golog.Error("Server", "UpdateConfig", err.Error(), 0)
+	}
+
 	_, another, index := s.allowipsIndex.Get()
 	s.allowips[another] = newAllowIps
 	s.allowipsIndex.Set(!index)
@@ -718,9 +892,9 @@ func (s *Server) UpdateConfig(newCfg *config.Config) {
 	s.ChangeSlowLogTime(fmt.Sprintf("%d", newCfg.SlowLogTime))
 
 	//reset nodes: old nodes offline (stop check thread)
-	// for _, n := range s.nodes {
-	// 	n.Online = false
-	// }
+	for _, n := range s.nodes {
+		n.Close()
+	}
 	s.nodes = nodes
 
 	//reset schema
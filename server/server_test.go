@@ -15,8 +15,14 @@
 package server
 
 import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"net"
 	"os"
+	"path/filepath"
 	"sqlproxy/core/golog"
+	"sqlproxy/mysql"
 	"sync"
 	"testing"
 	"time"
@@ -29,8 +35,12 @@ var testServerOnce sync.Once
 var testServer *Server
 var testDB *backend.BackendProxy
 var testConn *ClientConn
+var testConnHolder *backend.BackendProxy
 
-var testConfigData = []byte(`
+// 默认使用临时目录下的SQLite库，设置环境变量TEST_MYSQL_DATASOURCE时使用MySQL
+var testDriverName, testDatasource = "sqlite", ""
+
+var testConfigData = `
 addr : 127.0.0.1:9696
 user_list :
 - 
@@ -40,25 +50,41 @@ user_list :
 nodes :
 - 
     name : test
-    driver_name: mysql
-    datasource: proxy_dml:quanshitestcmnew@tcp(192.168.39.119:3306)/test?charset=utf8mb4&readTimeout=10s&writeTimeout=10s
+    driver_name: %s
+    datasource: %s
     max_conns_limit: 5
 
 schema_list :
 - 
     user: testuser  
-    node: test
-`)
+    nodes: [test]
+`
 
 type OnConnectListener struct{}
 
 func (this *OnConnectListener) OnConnect(conn *ClientConn) {
+	// testConn固定为第一个连接，测试中直接写入的包不会被testDB的连接读到
+	if testConn != nil {
+		return
+	}
 	testConn = conn
 	golog.Info("OnConnectListener", "OnConnect", "test conn init.", 0)
 }
 
 func TestMain(m *testing.M) {
 	var err error
+	var testDir string
+	if dsn := os.Getenv("TEST_MYSQL_DATASOURCE"); dsn != "" {
+		testDriverName, testDatasource = "mysql", dsn
+	} else {
+		testDir, err = os.MkdirTemp("", "sqlproxy")
+		if err != nil {
+			panic(err)
+		}
+		// WAL模式下读不会被其他连接未提交的事务阻塞
+		testDatasource = "file:" + filepath.Join(testDir, "test.db") + "?_pragma=journal_mode(wal)&_pragma=busy_timeout(5000)"
+	}
+
 	testServer, err = newTestServer()
 	if err != nil {
 		panic(err)
@@ -67,6 +93,7 @@ func TestMain(m *testing.M) {
 	// 如果测试backendProxy从testServer中获取连接实例
 	// 如果要从外面测sqlproxy服务，则使用newFrontConn来获取连接实例
 	// testDB = testServer.GetNode("test")
+	testConnHolder = newFrontConn()
 	testDB = newFrontConn()
 	if testDB == nil {
 		panic("testDB is nil")
@@ -75,12 +102,15 @@ func TestMain(m *testing.M) {
 	exitCode := m.Run()
 
 	testServer.Close()
+	if testDir != "" {
+		os.RemoveAll(testDir)
+	}
 
 	os.Exit(exitCode)
 }
 
 func newTestServer() (*Server, error) {
-	cfg, err := config.ParseConfigData(testConfigData)
+	cfg, err := config.ParseConfigData([]byte(fmt.Sprintf(testConfigData, testDriverName, testDatasource)))
 	if err != nil {
 		return nil, err
 	}
@@ -123,6 +153,109 @@ func newFrontConn() *backend.BackendProxy {
 	return db
 }
 
+// skipSQLite 跳过依赖MySQL行为的测试，SQLite无法模拟
+func skipSQLite(t *testing.T, reason string) {
+	if testDriverName == "sqlite" {
+		t.Skip("sqlite: " + reason)
+	}
+}
+
+// rawConn 直接按协议收发包的客户端，用于测试驱动不支持的命令
+type rawConn struct {
+	t    *testing.T
+	conn net.Conn
+	pkg  *mysql.PacketIO
+}
+
+func newRawConn(t *testing.T, capability uint32) *rawConn {
+	conn, err := net.Dial("tcp", testServer.cfg.Addr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	c := &rawConn{t: t, conn: conn, pkg: mysql.NewPacketIO(conn)}
+
+	// 握手包中的salt分为8字节和12字节两段
+	data := c.readPacket()
+	pos := 1 + bytes.IndexByte(data[1:], 0) + 1 + 4
+	salt := append([]byte{}, data[pos:pos+8]...)
+	pos += 8 + 1 + 2 + 1 + 2 + 2 + 1 + 10
+	salt = append(salt, data[pos:pos+12]...)
+
+	capability |= mysql.CLIENT_PROTOCOL_41 | mysql.CLIENT_SECURE_CONNECTION | mysql.CLIENT_CONNECT_WITH_DB
+	auth := mysql.CalcPassword(salt, []byte("testpwd"))
+	resp := make([]byte, 4, 128)
+	resp = append(resp, mysql.Uint32ToBytes(capability)...)
+	resp = append(resp, 0, 0, 0, 1, 33)
+	resp = append(resp, make([]byte, 23)...)
+	resp = append(resp, "testuser"...)
+	resp = append(resp, 0, byte(len(auth)))
+	resp = append(resp, auth...)
+	resp = append(resp, "test"...)
+	resp = append(resp, 0)
+	if err := c.pkg.WritePacket(resp); err != nil {
+		t.Fatal(err)
+	}
+	if data = c.readPacket(); data[0] != mysql.OK_HEADER {
+		t.Fatal(data)
+	}
+	return c
+}
+
+func (c *rawConn) Close() {
+	c.conn.Close()
+}
+
+func (c *rawConn) writeCommand(cmd byte, args []byte) {
+	c.pkg.Sequence = 0
+	data := append([]byte{0, 0, 0, 0, cmd}, args...)
+	if err := c.pkg.WritePacket(data); err != nil {
+		c.t.Fatal(err)
+	}
+}
+
+func (c *rawConn) readPacket() []byte {
+	data, err := c.pkg.ReadPacket()
+	if err != nil {
+		c.t.Fatal(err)
+	}
+	return data
+}
+
+// readUntilEOF 读取到EOF包为止，返回之前的包和EOF中的状态
+func (c *rawConn) readUntilEOF() ([][]byte, uint16) {
+	var packets [][]byte
+	for {
+		data := c.readPacket()
+		if data[0] == mysql.ERR_HEADER {
+			c.t.Fatal(string(data[9:]))
+		}
+		if data[0] == mysql.EOF_HEADER && len(data) < 9 {
+			return packets, binary.LittleEndian.Uint16(data[3:5])
+		}
+		packets = append(packets, data)
+	}
+}
+
+// readOK 读取OK包，返回其中的状态
+func (c *rawConn) readOK() uint16 {
+	data := c.readPacket()
+	if data[0] != mysql.OK_HEADER {
+		c.t.Fatal(string(data))
+	}
+	_, _, n := mysql.LengthEncodedInt(data[1:])
+	_, _, m := mysql.LengthEncodedInt(data[1+n:])
+	return binary.LittleEndian.Uint16(data[1+n+m:])
+}
+
+// readError 读取错误包，返回错误码
+func (c *rawConn) readError() uint16 {
+	data := c.readPacket()
+	if data[0] != mysql.ERR_HEADER {
+		c.t.Fatal(data)
+	}
+	return binary.LittleEndian.Uint16(data[1:3])
+}
+
 func TestServer(t *testing.T) {
 	newTestServer()
 }
@@ -1,5 +1,7 @@
 package sqlparser
 
+import "fmt"
+
 type Merge struct {
 	Comments  Comments
 	Table     *MergeTableExpr
@@ -11,9 +13,13 @@ func (node *Merge) iStatement() {}
 
 // Format formats the node.
 func (node *Merge) Format(buf *TrackedBuffer) {
-	buf.Myprintf("merge %vinto %v %v %v",
-		node.Comments,
-		node.Table, node.Matched, node.Unmatched)
+	buf.Myprintf("merge %vinto %v", node.Comments, node.Table)
+	if len(node.Matched) > 0 {
+		buf.Myprintf(" %v", node.Matched)
+	}
+	if node.Unmatched != nil {
+		buf.Myprintf(" %v", node.Unmatched)
+	}
 }
 
 func (node *Merge) walkSubtree(visit Visit) error {
@@ -111,7 +117,8 @@ func (node *MergeTableExpr) iTableExpr() {}
 
 // Format formats the node.
 func (node *MergeTableExpr) Format(buf *TrackedBuffer) {
-	buf.Myprintf("%v using %v%v", node.LeftExpr, node.RightExpr, node.Condition)
+	// Oracle中merge的on条件必须用括号括起来
+	buf.Myprintf("%v using %v on (%v)", node.LeftExpr, node.RightExpr, node.Condition.On)
 }
 
 func (node *MergeTableExpr) walkSubtree(visit Visit) error {
@@ -126,18 +133,17 @@ func (node *MergeTableExpr) walkSubtree(visit Visit) error {
 	)
 }
 
-// using语句拼接的虚拟表定义
+// using语句拼接的虚拟表定义，列名通过查询中的列别名声明
 type VirtualTableExpr struct {
-	Rows      SelectValues // 虚拟表数据，来自于Insert.Rows
-	TableName TableIdent   // 表名
-	Columns   Columns      // 虚拟表数据声明的列
+	Rows      SelectStatement // 虚拟表数据，来自于Insert.Rows，多行values转换为union all
+	TableName TableIdent      // 表名
 }
 
 func (node *VirtualTableExpr) iTableExpr() {}
 
 // Format formats the node.
 func (node *VirtualTableExpr) Format(buf *TrackedBuffer) {
-	buf.Myprintf("(%v) %v %v", node.Rows, node.TableName, node.Columns)
+	buf.Myprintf("(%v) %v", node.Rows, node.TableName)
 }
 
 func (node *VirtualTableExpr) walkSubtree(visit Visit) error {
@@ -148,36 +154,184 @@ func (node *VirtualTableExpr) walkSubtree(visit Visit) error {
 		visit,
 		node.Rows,
 		node.TableName,
-		node.Columns,
 	)
 }
 
-type SelectValues []SelectTuple
+// RownumExpr 表示Oracle中的ROWNUM伪列，不能作为普通列名加引号输出
+type RownumExpr struct{}
 
-func (node SelectValues) Format(buf *TrackedBuffer) {
-	prefix := ""
-	for _, n := range node {
-		buf.Myprintf("%s%v", prefix, n)
-		prefix = " union all "
+func (*RownumExpr) iExpr() {}
+
+// Format formats the node.
+func (node *RownumExpr) Format(buf *TrackedBuffer) {
+	buf.WriteString("rownum")
+}
+
+func (node *RownumExpr) walkSubtree(visit Visit) error {
+	return nil
+}
+
+func (node *RownumExpr) replace(from, to Expr) bool {
+	return false
+}
+
+// RownumSelect 基于ROWNUM的分页查询，用于不支持OFFSET ... FETCH语法的老版本Oracle
+// 没有offset时：select * from (...) where rownum <= n
+// 有offset时：select * from (select "t_".*, rownum as "rn_" from (...) "t_" where rownum <= m + n) where "rn_" > m
+type RownumSelect struct {
+	Columns  Columns         // 外层查询输出的列，为空时输出*
+	Select   SelectStatement // 去掉limit后的原始查询
+	Offset   Expr
+	Rowcount Expr
+	Lower    Expr // Offset的副本，用于外层的"rn_"过滤，绑定参数需要单独编号
+}
+
+func (*RownumSelect) iStatement()       {}
+func (*RownumSelect) iSelectStatement() {}
+func (*RownumSelect) iInsertRows()      {}
+
+// AddOrder adds an order by element
+func (node *RownumSelect) AddOrder(order *Order) {
+	panic("unreachable")
+}
+
+// SetLimit sets the limit clause
+func (node *RownumSelect) SetLimit(limit *Limit) {
+	panic("unreachable")
+}
+
+// Format formats the node.
+func (node *RownumSelect) Format(buf *TrackedBuffer) {
+	buf.WriteString("select ")
+	if len(node.Columns) == 0 {
+		buf.WriteString("*")
+	} else {
+		prefix := ""
+		for _, col := range node.Columns {
+			buf.Myprintf("%s%v", prefix, col)
+			prefix = ", "
+		}
+	}
+	if node.Offset == nil {
+		buf.Myprintf(" from (%v) where %v <= %v", node.Select, &RownumExpr{}, node.Rowcount)
+		return
 	}
+	alias, rn := NewTableIdent("t_"), NewColIdent("rn_")
+	buf.Myprintf(" from (select %v.*, %v as %v from (%v) %v where %v <= %v + %v) where %v > %v",
+		alias, &RownumExpr{}, rn, node.Select, alias, &RownumExpr{}, node.Offset, node.Rowcount, rn, node.Lower)
 }
 
-func (node SelectValues) walkSubtree(visit Visit) error {
-	for _, n := range node {
-		if err := Walk(visit, n); err != nil {
-			return err
+func (node *RownumSelect) walkSubtree(visit Visit) error {
+	if node == nil {
+		return nil
+	}
+	return Walk(
+		visit,
+		node.Columns,
+		node.Select,
+		node.Offset,
+		node.Rowcount,
+		node.Lower,
+	)
+}
+
+// DedupSelect 按唯一索引对数据源去重，每组唯一键只保留第一行（Last时保留最后一行），用于merge的数据源：
+// insert ignore中数据源内重复的行只插入第一行，merge中所有行都不匹配目标表，会全部插入而违反唯一约束；
+// on duplicate key update和replace中后面的行覆盖前面的行，merge的数据源有重复行时报ORA-30926
+// select cols from (select "d_".*, row_number() over (partition by k1 order by "rn_") as "rn1_", ...
+// from (select "s_".*, rownum as "rn_" from (...) "s_") "d_") where "rn1_" = 1 and ...
+type DedupSelect struct {
+	Columns Columns         // 外层查询输出的列，即数据源的列别名
+	Select  SelectStatement // 原始的数据源
+	Keys    []Columns       // 唯一索引的列，每个索引单独去重
+	Last    bool            // 保留每组唯一键的最后一行
+}
+
+func (*DedupSelect) iStatement()       {}
+func (*DedupSelect) iSelectStatement() {}
+func (*DedupSelect) iInsertRows()      {}
+
+// AddOrder adds an order by element
+func (node *DedupSelect) AddOrder(order *Order) {
+	panic("unreachable")
+}
+
+// SetLimit sets the limit clause
+func (node *DedupSelect) SetLimit(limit *Limit) {
+	panic("unreachable")
+}
+
+// Format formats the node.
+func (node *DedupSelect) Format(buf *TrackedBuffer) {
+	prefix := "select "
+	for _, col := range node.Columns {
+		buf.Myprintf("%s%v", prefix, col)
+		prefix = ", "
+	}
+	source, dedup, rn := NewTableIdent("s_"), NewTableIdent("d_"), NewColIdent("rn_")
+	buf.Myprintf(" from (select %v.*", dedup)
+	for i, key := range node.Keys {
+		buf.WriteString(", row_number() over (partition by ")
+		prefix = ""
+		for _, col := range key {
+			buf.Myprintf("%s%v", prefix, col)
+			prefix = ", "
+		}
+		buf.Myprintf(" order by %v", rn)
+		if node.Last {
+			buf.WriteString(" desc")
 		}
+		buf.Myprintf(") as %v", NewColIdent(fmt.Sprintf("rn%d_", i+1)))
+	}
+	buf.Myprintf(" from (select %v.*, %v as %v from (%v) %v) %v)", source, &RownumExpr{}, rn, node.Select, source, dedup)
+	prefix = " where "
+	for i := range node.Keys {
+		buf.Myprintf("%s%v = 1", prefix, NewColIdent(fmt.Sprintf("rn%d_", i+1)))
+		prefix = " and "
 	}
-	return nil
 }
 
-type SelectTuple Exprs
+func (node *DedupSelect) walkSubtree(visit Visit) error {
+	if node == nil {
+		return nil
+	}
+	return Walk(
+		visit,
+		node.Columns,
+		node.Select,
+	)
+}
+
+// TemplateExpr 按模板输出的表达式，用于MySQL函数到Oracle语法的转换，
+// 模板中只能使用%v占位，且顺序与Exprs一致，保证Walk的顺序与输出顺序相同
+type TemplateExpr struct {
+	Template string
+	Exprs    Exprs
+}
+
+func (*TemplateExpr) iExpr() {}
 
 // Format formats the node.
-func (node SelectTuple) Format(buf *TrackedBuffer) {
-	buf.Myprintf("select %v", Exprs(node))
+func (node *TemplateExpr) Format(buf *TrackedBuffer) {
+	values := make([]interface{}, 0, len(node.Exprs))
+	for _, expr := range node.Exprs {
+		values = append(values, expr)
+	}
+	buf.Myprintf(node.Template, values...)
+}
+
+func (node *TemplateExpr) walkSubtree(visit Visit) error {
+	if node == nil {
+		return nil
+	}
+	return Walk(visit, node.Exprs)
 }
 
-func (node SelectTuple) walkSubtree(visit Visit) error {
-	return Walk(visit, Exprs(node))
+func (node *TemplateExpr) replace(from, to Expr) bool {
+	for i := range node.Exprs {
+		if replaceExprs(from, to, &node.Exprs[i]) {
+			return true
+		}
+	}
+	return false
 }
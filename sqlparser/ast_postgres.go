@@ -0,0 +1,56 @@
+package sqlparser
+
+// Upsert PostgreSQL的insert ... on conflict，
+// 由MySQL的insert ignore、replace和on duplicate key update转换而来
+type Upsert struct {
+	Insert   *Insert
+	Conflict *OnConflict
+}
+
+func (node *Upsert) iStatement() {}
+
+// Format formats the node.
+func (node *Upsert) Format(buf *TrackedBuffer) {
+	buf.Myprintf("%v %v", node.Insert, node.Conflict)
+}
+
+func (node *Upsert) walkSubtree(visit Visit) error {
+	if node == nil {
+		return nil
+	}
+	return Walk(
+		visit,
+		node.Insert,
+		node.Conflict,
+	)
+}
+
+// OnConflict on conflict (columns) do update set ...，Exprs为空时do nothing
+type OnConflict struct {
+	Columns Columns
+	Exprs   UpdateExprs
+}
+
+// Format formats the node.
+func (node *OnConflict) Format(buf *TrackedBuffer) {
+	buf.Myprintf("on conflict")
+	if len(node.Columns) > 0 {
+		buf.Myprintf(" %v", node.Columns)
+	}
+	if len(node.Exprs) == 0 {
+		buf.Myprintf(" do nothing")
+		return
+	}
+	buf.Myprintf(" do update set %v", node.Exprs)
+}
+
+func (node *OnConflict) walkSubtree(visit Visit) error {
+	if node == nil {
+		return nil
+	}
+	return Walk(
+		visit,
+		node.Columns,
+		node.Exprs,
+	)
+}
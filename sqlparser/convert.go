@@ -1,18 +1,91 @@
 package sqlparser
 
+import (
+	"sort"
+	"strings"
+	"sync"
+)
+
+const (
+	MYSQL_TO_ORACLE   = "mysql-to-oracle"
+	MYSQL_TO_POSTGRES = "mysql-to-postgres"
+	MYSQL_TO_SQLITE   = "mysql-to-sqlite"
+)
+
+// 分页语法的转换方式
 const (
-	MYSQL_TO_ORACLE = "mysql-to-oracle"
+	// OFFSET m ROWS FETCH NEXT n ROWS ONLY, 适用于Oracle 12c+及达梦
+	PAGINATION_OFFSET_FETCH = "offset_fetch"
+	// 基于ROWNUM的子查询包装，适用于Oracle 11g及以下版本
+	PAGINATION_ROWNUM = "rownum"
 )
 
+// SQLConverter 转换SQL，返回的参数只能是原参数的重新排列（可以重复或去掉），
+// 转换结果不能依赖参数的值，backend按SQL缓存转换结果
 type SQLConverter interface {
 	Convert(sql string, args ...interface{}) (string, []interface{}, error)
 }
 
-func GetSQLConverter(name string, tableUniqueIndexs map[string]map[string][]string, tableColumns map[string][]string, incrementColumns map[string]map[string]int) SQLConverter {
-	switch name {
-	case MYSQL_TO_ORACLE:
-		return NewOracleConverter(tableUniqueIndexs, tableColumns, incrementColumns)
-	default:
+// DDLConverter 转换DDL，一条MySQL的DDL可能对应多条目标库的语句，需要按顺序执行
+type DDLConverter interface {
+	ConvertDDL(sql string) ([]string, error)
+}
+
+// ConvertOptions 转换器的可选项，来自node的配置
+type ConvertOptions struct {
+	Pagination string
+	// 目标库的表名、列名为大写时，输出的标识符转为大写
+	UpperCaseIdent bool
+	// 目标库的驱动名，达梦和Oracle语法不同的地方（例如自增列）据此区分
+	DriverName string
+}
+
+// MetadataKey 表结构信息中表名、列名的写法，Oracle数据字典中不加引号的标识符为大写，
+// 驱动为oci8或者配置了大写时统一按大写存储和查找，PostgreSQL默认按小写
+func (opts ConvertOptions) MetadataKey(name string) string {
+	if opts.UpperCaseIdent || opts.DriverName == "oci8" {
+		return strings.ToUpper(name)
+	}
+	if opts.DriverName == "postgres" || opts.DriverName == "pgx" {
+		return strings.ToLower(name)
+	}
+	return name
+}
+
+// ConverterFactory 根据目标库的表结构信息创建转换器
+type ConverterFactory func(tableUniqueIndexs map[string]map[string][]string, tableColumns map[string][]string, incrementColumns map[string]map[string]int, opts ConvertOptions) SQLConverter
+
+var (
+	convertersMu sync.RWMutex
+	converters   = map[string]ConverterFactory{}
+)
+
+// RegisterSQLConverter 按名称注册转换器，内置的转换器在各自的文件中注册，已存在时覆盖
+func RegisterSQLConverter(name string, factory ConverterFactory) {
+	convertersMu.Lock()
+	defer convertersMu.Unlock()
+	converters[name] = factory
+}
+
+// SQLConverterNames 已注册的转换器名称
+func SQLConverterNames() []string {
+	convertersMu.RLock()
+	defer convertersMu.RUnlock()
+	names := make([]string, 0, len(converters))
+	for name := range converters {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// GetSQLConverter 创建指定名称的转换器，未注册时返回nil
+func GetSQLConverter(name string, tableUniqueIndexs map[string]map[string][]string, tableColumns map[string][]string, incrementColumns map[string]map[string]int, opts ConvertOptions) SQLConverter {
+	convertersMu.RLock()
+	factory, ok := converters[name]
+	convertersMu.RUnlock()
+	if !ok {
 		return nil
 	}
+	return factory(tableUniqueIndexs, tableColumns, incrementColumns, opts)
 }
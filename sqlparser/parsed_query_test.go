@@ -185,13 +185,15 @@ func TestGenerateQueryForArgs(t *testing.T) {
 				"PRIMARY": {"cal_id"},
 			},
 		}, nil, nil)
-		convertTree, _ := converter.convertStmt(tree)
+		convertTree, _, _ := converter.convertStmt(tree)
 		if convertTree == nil {
 			t.Errorf("convert failed: %s", tcase.query)
 			continue
 		}
 
-		pq := NewParsedQuery(convertTree)
+		buf := NewTrackedBuffer(converter.formatNode)
+		buf.Myprintf("%v", convertTree)
+		pq := buf.ParsedQuery()
 		bytes, err := pq.GenerateQueryForArgs(tcase.bindVars)
 		var got string
 		if err != nil {
@@ -199,7 +201,6 @@ func TestGenerateQueryForArgs(t *testing.T) {
 		} else {
 			got = string(bytes)
 		}
-		got = converter.replaceCommonIdents(got)
 		if got != tcase.output {
 			t.Errorf("for test case: %s, got: '%s', want '%s'", tcase.desc, got, tcase.output)
 		} else {
@@ -3,25 +3,30 @@ package sqlparser
 import (
 	"fmt"
 	"log"
+	"sort"
+	"sqlproxy/core/errors"
 	"sqlproxy/core/golog"
 	"strconv"
 	"strings"
 )
 
 type OracleConverter struct {
-	replaceChars      map[string]string
 	tableUniqueIndexs map[string]map[string][]string
 	tableColumns      map[string][]string
 	incrementColumns  map[string]map[string]int
+	options           ConvertOptions
+}
+
+func init() {
+	RegisterSQLConverter(MYSQL_TO_ORACLE, func(tableUniqueIndexs map[string]map[string][]string, tableColumns map[string][]string, incrementColumns map[string]map[string]int, opts ConvertOptions) SQLConverter {
+		converter := NewOracleConverter(tableUniqueIndexs, tableColumns, incrementColumns)
+		converter.options = opts
+		return converter
+	})
 }
 
 func NewOracleConverter(tableUniqueIndexs map[string]map[string][]string, tableColumns map[string][]string, incrementColumns map[string]map[string]int) *OracleConverter {
 	return &OracleConverter{
-		replaceChars: map[string]string{
-			"`":                   "\"",
-			"0000-00-00 00:00:00": "0001-01-01 00:00:00",
-			`\'`:                  `''`,
-		},
 		tableUniqueIndexs: tableUniqueIndexs,
 		incrementColumns:  incrementColumns,
 		tableColumns:      tableColumns,
@@ -34,32 +39,48 @@ func NewOracleConverter(tableUniqueIndexs map[string]map[string][]string, tableC
 // 3. convert mysql ast to oracle ast
 // 4. rebuild oracle sql from ast
 func (this *OracleConverter) Convert(sql string, args ...interface{}) (string, []interface{}, error) {
-	if !supportConvert(sql) {
-		return sql, args, nil
-	}
-	stmt, err := Parse(sql)
+	stmt, err := parseConvertStmt(sql)
 	if err != nil {
 		log.Printf("ignoring error parsing sql '%s': %v", sql, err)
 		return "", args, err
 	}
+	if stmt == nil {
+		return sql, args, nil
+	}
 	// 转换statement时可能带来参数数量的变化，例如：replace转merge， insert去掉increment column等，
 	// 因此，参数args也需要作相应的配套处理
-	oracleStmt, args := this.convertStmt(stmt, args...)
+	oracleStmt, args, err := this.convertStmt(stmt, args...)
+	if err != nil {
+		golog.Warn("OracleConverter", "Convert", err.Error(), 0, "sql", sql)
+		return "", args, err
+	}
 
 	if oracleStmt == nil {
-		return this.replaceCommonIdents(sql), args, nil
+		return sql, args, nil
 	}
-	buf := NewTrackedBuffer(nil).WriteNode(oracleStmt)
-	convertSQL := this.replaceCommonIdents(buf.String())
+	// 标识符引号、字符串转义、时间零值等在输出时由formatNode处理
+	convertSQL := NewTrackedBuffer(this.formatNode).WriteNode(oracleStmt).String()
 	golog.Debug("OracleConverter", "Convert", "ConvertSQL", 0, convertSQL)
 	return convertSQL, args, nil
 }
 
-func (this *OracleConverter) convertStmt(stmt Statement, args ...interface{}) (Statement, []interface{}) {
+func (this *OracleConverter) convertStmt(stmt Statement, args ...interface{}) (Statement, []interface{}, error) {
 	var newStmt Statement
+	var err error
+	// 多表update/delete需要复制MySQL的表达式，在转换函数之前处理
+	switch n := stmt.(type) {
+	case *Update:
+		stmt, err = this.convertUpdateJoin(n)
+	case *Delete:
+		stmt, err = this.convertDeleteJoin(n)
+	}
+	if err != nil {
+		return nil, args, err
+	}
+	stmt = this.convertFuncs(stmt)
 	switch stmt.(type) {
 	case *Insert:
-		newStmt = this.convertInsert(stmt.(*Insert))
+		newStmt, err = this.convertInsert(stmt.(*Insert))
 	case *Update:
 		newStmt = this.convertUpdateIncrement(stmt.(*Update))
 	case *Select:
@@ -67,11 +88,18 @@ func (this *OracleConverter) convertStmt(stmt Statement, args ...interface{}) (S
 	default:
 		newStmt = stmt
 	}
+	if err != nil {
+		return nil, args, err
+	}
+	newStmt, err = this.convertLimit(newStmt)
+	if err != nil {
+		return nil, args, err
+	}
 
 	if this.needConvertArgs(newStmt, args...) {
-		return this.convertStmtArgs(newStmt, args...)
+		newStmt, args = this.convertStmtArgs(newStmt, args...)
 	}
-	return newStmt, args
+	return newStmt, args, nil
 }
 
 func (this *OracleConverter) convertSelect(stmt *Select) Statement {
@@ -90,7 +118,7 @@ func (this *OracleConverter) needConvertArgs(stmt Statement, args ...interface{}
 		return false
 	}
 	switch stmt.(type) {
-	case *Merge, *Insert:
+	case *Merge, *Insert, *Select, *Union, *RownumSelect, *Update, *Delete:
 		return true
 	default:
 		return false
@@ -104,8 +132,14 @@ func (this *OracleConverter) convertStmtArgs(stmt Statement, args ...interface{}
 		switch node.(type) {
 		case *SQLVal:
 			n := node.(*SQLVal)
+			if n.Type != ValArg {
+				return true, nil
+			}
 			v := string(n.Val)
 			i, _ := strconv.Atoi(strings.ReplaceAll(v, ":v", ""))
+			if i < 1 || i > len(args) {
+				return true, nil
+			}
 			n.Val = []byte(fmt.Sprintf(":v%d", id))
 			newArgs = append(newArgs, args[i-1])
 			id++
@@ -118,34 +152,56 @@ func (this *OracleConverter) convertStmtArgs(stmt Statement, args ...interface{}
 	return stmt, newArgs
 }
 
-func (this *OracleConverter) convertInsert(stmt *Insert) Statement {
+func (this *OracleConverter) convertInsert(stmt *Insert) (Statement, error) {
+	// 没有指定columns时使用表的全部列，merge的using和insert分支都需要明确的列
+	this.fillInsertColumns(stmt)
 	// try to find auto increment columns and remove them
 	stmt = this.convertInsertIncrement(stmt)
+	// Oracle不支持insert ignore，有唯一索引时转换为只有when not matched分支的merge，
+	// 被忽略的行不会插入，影响行数与MySQL一致
+	ignore := stmt.Ignore != "" && stmt.OnDup == nil
+	stmt.Ignore = ""
 	// write a method to walk ast tree, recognize all kinds of expr, and rebuild oracle ast
-	if stmt.Action == InsertStr && stmt.OnDup == nil {
-		return stmt
+	if stmt.Action == InsertStr && stmt.OnDup == nil && !ignore {
+		return stmt, nil
 	}
 	// The case where columns is empty is not currently supported for conversion.
-	if len(stmt.Columns) == 0 {
-		return stmt
+	if len(stmt.Columns) == 0 || !insertRowsMatchColumns(stmt) {
+		if ignore {
+			return nil, fmt.Errorf("insert ignore into %s: unknown columns", stmt.Table.Name.String())
+		}
+		return stmt, nil
 	}
 
 	// find unique columns for table
 	condcols := this.getUniqueConditionColumns(stmt)
 	if len(condcols) == 0 {
+		// 去掉ignore后重复行会报错而不是被忽略，由ConvertStrict决定是否回退原SQL
+		if ignore {
+			return nil, fmt.Errorf("insert ignore into %s: no unique index", stmt.Table.Name.String())
+		}
 		stmt.OnDup = nil
-		return stmt
+		return stmt, nil
+	}
+	tableExpr, err := this.buildMergeTableExpr(stmt, condcols)
+	if err != nil {
+		return nil, err
+	}
+	var matchedExpr MatchedExpr
+	if !ignore {
+		if matchedExpr, err = buildMatchedExpr(stmt, condcols); err != nil {
+			return nil, err
+		}
 	}
-	tableExpr := this.buildMergeTableExpr(stmt, condcols)
-	matchedExpr := buildMatchedExpr(stmt, condcols)
 
-	if tableExpr == nil || len(matchedExpr) == 0 {
-		return stmt
+	if tableExpr == nil {
+		return stmt, nil
 	}
+	dedupMergeSource(stmt, tableExpr, condcols, !ignore)
 
 	// sets the qualifier for columns
 	// setQualifierForCols(tableExpr)
-	setQualifierForCols(matchedExpr)
+	setQualifierForCols(matchedExpr, stmt.Table)
 	log.Printf("condcols: %v, tableExpr: %v, matchedExpr: %v", condcols, tableExpr, matchedExpr)
 
 	return &Merge{
@@ -157,7 +213,63 @@ func (this *OracleConverter) convertInsert(stmt *Insert) Statement {
 			// Rows:    Rows,
 			Values: buildValuesExpr(stmt),
 		},
+	}, nil
+}
+
+// dedupMergeSource merge的数据源有多行时按唯一索引去重，insert ignore每组唯一键只保留第一行，
+// on duplicate key update和replace保留最后一行（last），最后一行的值覆盖前面的行。
+// 有多个唯一索引时各索引分别去重，与MySQL逐行插入的结果在少数情况下不同：
+// 第二行因索引a重复被忽略后，与它索引b相同的第三行在MySQL中可以插入，去重后也被去掉；
+// on duplicate key update的目标表中没有该行时，MySQL插入第一行再用后面的行更新，merge直接插入最后一行
+func dedupMergeSource(stmt *Insert, tableExpr *MergeTableExpr, condcols [][]string, last bool) {
+	if rows, ok := stmt.Rows.(Values); ok && (len(rows) <= 1 || literalKeysDistinct(stmt.Columns, rows, condcols)) {
+		return
+	}
+	source, ok := tableExpr.RightExpr.(*VirtualTableExpr)
+	if !ok {
+		return
+	}
+	keys := make([]Columns, 0, len(condcols))
+	for _, condcol := range condcols {
+		key := make(Columns, 0, len(condcol))
+		for _, column := range condcol {
+			key = append(key, NewColIdent(column))
+		}
+		keys = append(keys, key)
 	}
+	source.Rows = &DedupSelect{Columns: stmt.Columns, Select: source.Rows, Keys: keys, Last: last}
+}
+
+// literalKeysDistinct 唯一键的值都是常量且各行互不相同时不需要去重，有绑定参数时无法判断
+func literalKeysDistinct(columns Columns, rows Values, condcols [][]string) bool {
+	for _, condcol := range condcols {
+		indexes := make([]int, 0, len(condcol))
+		for _, name := range condcol {
+			for i, column := range columns {
+				if column.EqualString(name) {
+					indexes = append(indexes, i)
+					break
+				}
+			}
+		}
+		seen := make(map[string]struct{}, len(rows))
+		for _, row := range rows {
+			key := make([]string, 0, len(indexes))
+			for _, i := range indexes {
+				val, ok := row[i].(*SQLVal)
+				if !ok || val.Type == ValArg {
+					return false
+				}
+				key = append(key, string(val.Val))
+			}
+			k := strings.Join(key, ",")
+			if _, ok := seen[k]; ok {
+				return false
+			}
+			seen[k] = struct{}{}
+		}
+	}
+	return true
 }
 
 func buildValuesExpr(stmt *Insert) ValuesExpr {
@@ -178,43 +290,35 @@ func (this *OracleConverter) convertInsertIncrement(stmt *Insert) *Insert {
 		return stmt
 	}
 
-	incrementColumns := this.incrementColumns[stmt.Table.Name.String()]
+	incrementColumns := this.incrementColumns[this.options.MetadataKey(stmt.Table.Name.String())]
 	if incrementColumns == nil || len(incrementColumns) == 0 {
 		return stmt
 	}
 
 	// 没有指定columns的补充columns
-	if len(stmt.Columns) == 0 {
-		stmt.Columns = []ColIdent{}
-		for _, column := range this.tableColumns[stmt.Table.Name.String()] {
-			stmt.Columns = append(stmt.Columns, NewColIdent(column))
-		}
-	}
+	this.fillInsertColumns(stmt)
 
 	// remove auto increment columns
-	ns := []int{}
+	ns := map[int]bool{}
 	newColumns := []ColIdent{}
 	for i, column := range stmt.Columns {
-		if _, ok := incrementColumns[column.String()]; ok {
-			ns = append(ns, i)
+		if _, ok := incrementColumns[this.options.MetadataKey(column.String())]; ok {
+			ns[i] = true
 		} else {
 			newColumns = append(newColumns, column)
 		}
-		stmt.Columns = newColumns
 	}
+	if len(ns) == 0 {
+		return stmt
+	}
+	stmt.Columns = newColumns
 
 	var rows Values
 	for _, row := range stmt.Rows.(Values) {
 		newRow := ValTuple{}
 		for i, v := range row {
-			if len(ns) == 0 {
+			if !ns[i] {
 				newRow = append(newRow, v)
-			} else {
-				for n := range ns {
-					if i != n {
-						newRow = append(newRow, v)
-					}
-				}
 			}
 		}
 		rows = append(rows, newRow)
@@ -223,11 +327,34 @@ func (this *OracleConverter) convertInsertIncrement(stmt *Insert) *Insert {
 	return stmt
 }
 
+func (this *OracleConverter) fillInsertColumns(stmt *Insert) {
+	if len(stmt.Columns) > 0 {
+		return
+	}
+	for _, column := range this.tableColumns[this.options.MetadataKey(stmt.Table.Name.String())] {
+		stmt.Columns = append(stmt.Columns, NewColIdent(column))
+	}
+}
+
+// 每行values的数量必须与列数一致，insert ... select的列数在build时检查
+func insertRowsMatchColumns(stmt *Insert) bool {
+	rows, ok := stmt.Rows.(Values)
+	if !ok {
+		return true
+	}
+	for _, row := range rows {
+		if len(row) != len(stmt.Columns) {
+			return false
+		}
+	}
+	return true
+}
+
 func (this *OracleConverter) convertUpdateIncrement(stmt *Update) *Update {
 	if len(stmt.TableExprs) == 0 {
 		return stmt
 	}
-	incrementColumns := this.incrementColumns[getTableName(stmt)]
+	incrementColumns := this.incrementColumns[this.options.MetadataKey(getTableName(stmt))]
 	if incrementColumns == nil || len(incrementColumns) == 0 {
 		return stmt
 	}
@@ -235,7 +362,7 @@ func (this *OracleConverter) convertUpdateIncrement(stmt *Update) *Update {
 	// remove auto increment columns
 	newExprs := make([]*UpdateExpr, 0, len(stmt.Exprs))
 	for _, expr := range stmt.Exprs {
-		if _, ok := incrementColumns[expr.Name.Name.String()]; ok {
+		if _, ok := incrementColumns[this.options.MetadataKey(expr.Name.Name.String())]; ok {
 			continue
 		}
 		newExprs = append(newExprs, expr)
@@ -244,10 +371,14 @@ func (this *OracleConverter) convertUpdateIncrement(stmt *Update) *Update {
 	return stmt
 }
 
-func (this *OracleConverter) buildMergeTableExpr(stmt *Insert, condcols [][]string) *MergeTableExpr {
+func (this *OracleConverter) buildMergeTableExpr(stmt *Insert, condcols [][]string) (*MergeTableExpr, error) {
 	onCondition := buildJoinConditions(stmt, condcols)
-	if onCondition == nil {
-		return nil
+	rightExpr, err := this.buildRightTableExpr(stmt)
+	if err != nil {
+		return nil, err
+	}
+	if onCondition == nil || rightExpr == nil {
+		return nil, nil
 	}
 
 	return &MergeTableExpr{
@@ -255,70 +386,73 @@ func (this *OracleConverter) buildMergeTableExpr(stmt *Insert, condcols [][]stri
 			Expr: stmt.Table,
 			As:   NewTableIdent("t"),
 		},
-		RightExpr: buildRightTableExpr(stmt),
+		RightExpr: rightExpr,
 		Condition: JoinCondition{
 			On: onCondition,
 		},
-	}
+	}, nil
 }
 
 func (this *OracleConverter) getUniqueConditionColumns(stmt *Insert) [][]string {
-	condcols := [][]string{}
 	// Case1: If user has configured unique index condcols for the table, use it as condition condcols
-	tableIndexs := this.tableUniqueIndexs[stmt.Table.Name.String()]
-	for _, iii := range tableIndexs {
-		i := 0
-		for _, column := range stmt.Columns {
-			for _, v := range iii {
-				if v == column.String() {
-					i++
+	return uniqueConditionColumns(this.tableUniqueIndexs[this.options.MetadataKey(stmt.Table.Name.String())], stmt.Columns)
+}
+
+// uniqueConditionColumns 返回列全部出现在insert中的唯一索引，列名不区分大小写，使用insert中的写法
+func uniqueConditionColumns(tableIndexs map[string][]string, columns Columns) [][]string {
+	condcols := [][]string{}
+	// 按索引名排序，保证生成的SQL稳定
+	names := make([]string, 0, len(tableIndexs))
+	for name := range tableIndexs {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		iii := tableIndexs[name]
+		matched := []string{}
+		for _, v := range iii {
+			for _, column := range columns {
+				if column.EqualString(v) {
+					matched = append(matched, column.String())
+					break
 				}
 			}
 		}
-		if i == len(iii) {
-			condcols = append(condcols, iii)
+		if len(matched) == len(iii) {
+			condcols = append(condcols, matched)
 		}
 	}
 
 	return condcols
 }
 
-func buildMatchedExpr(stmt *Insert, condcols [][]string) MatchedExpr {
+func buildMatchedExpr(stmt *Insert, condcols [][]string) (MatchedExpr, error) {
 	if stmt.OnDup != nil {
-		return MatchedExpr(stmt.OnDup)
+		matched := MatchedExpr(stmt.OnDup)
+		if err := defaultValuesFuncs(matched, stmt.Columns); err != nil {
+			return nil, err
+		}
+		return replaceValuesFuncs(matched, NewTableIdent("s")), nil
 	}
-	vals := getInsertValues(stmt)
-	exprs := make([]*UpdateExpr, 0, len(vals))
+	exprs := make([]*UpdateExpr, 0, len(stmt.Columns))
 
 	allCondcol := []string{}
 	for _, condcol := range condcols {
 		allCondcol = append(allCondcol, condcol...)
 	}
-	for i, column := range stmt.Columns {
-
+	// replace使用数据源中的值更新非唯一键的列，多行数据时每行各自匹配
+	for _, column := range stmt.Columns {
 		if !StringIn(column.String(), allCondcol...) {
 			exprs = append(exprs, &UpdateExpr{
 				Name: &ColName{Name: column},
-				Expr: vals[i],
+				Expr: &ColName{
+					Name:      column,
+					Qualifier: TableName{Name: NewTableIdent("s")},
+				},
 			})
 		}
 	}
-	return exprs
-}
-
-func getInsertValues(stmt *Insert) []*SQLVal {
-	vals := make([]*SQLVal, 0, len(stmt.Columns))
-	visit := func(node SQLNode) (kcontinue bool, err error) {
-		switch node.(type) {
-		case *SQLVal:
-			vals = append(vals, node.(*SQLVal))
-			return true, nil
-		default:
-			return true, nil
-		}
-	}
-	Walk(visit, stmt.Rows)
-	return vals
+	return exprs, nil
 }
 
 func getTableName(stmt *Update) string {
@@ -337,14 +471,65 @@ func getTableName(stmt *Update) string {
 	return tableName
 }
 
+// findValuesFuncs 查找on duplicate key update中的values(col)
+func findValuesFuncs(matched MatchedExpr) []*ValuesFuncExpr {
+	funcs := []*ValuesFuncExpr{}
+	visit := func(node SQLNode) (kcontinue bool, err error) {
+		if n, ok := node.(*ValuesFuncExpr); ok {
+			funcs = append(funcs, n)
+			return false, nil
+		}
+		return true, nil
+	}
+	_ = Walk(visit, matched)
+	return funcs
+}
+
+// replaceValuesFuncs 将on duplicate key update中的values(col)替换为数据源中的列，例如merge的s.col、
+// PostgreSQL和SQLite的excluded.col，excluded中insert没有指定的列为默认值，与MySQL一致
+func replaceValuesFuncs(matched MatchedExpr, source TableIdent) MatchedExpr {
+	for _, f := range findValuesFuncs(matched) {
+		replaceExprInStmt(matched, f, &ColName{
+			Name:      f.Name.Name,
+			Qualifier: TableName{Name: source},
+		})
+	}
+	return matched
+}
+
+// defaultValuesFuncs insert中没有的列，values(col)在MySQL中取列的默认值，merge的数据源中没有这些列：
+// 整个赋值为values(col)时转换为default，出现在表达式中时无法转换
+func defaultValuesFuncs(matched MatchedExpr, columns Columns) error {
+	for _, f := range findValuesFuncs(matched) {
+		if columns.FindColumn(f.Name.Name) >= 0 {
+			continue
+		}
+		replaced := false
+		for _, expr := range matched {
+			if expr.Expr == Expr(f) {
+				expr.Expr = &Default{}
+				replaced = true
+			}
+		}
+		if !replaced {
+			return fmt.Errorf("%w: values(%s) in expression and column not in insert", errors.ErrStmtConvert, f.Name.Name.String())
+		}
+	}
+	return nil
+}
+
 // sets the qualifier for columns in the SQLNode.
 //
 // It takes a SQLNode as a parameter and sets the qualifier of any ColName
-// nodes to TableName "t".
-func setQualifierForCols(node SQLNode) SQLNode {
+// nodes without qualifier or qualified by the target table to TableName "t".
+func setQualifierForCols(node SQLNode, table TableName) SQLNode {
 	visit := func(node SQLNode) (kcontinue bool, err error) {
 		switch node.(type) {
 		case *ColName:
+			qualifier := node.(*ColName).Qualifier
+			if !qualifier.IsEmpty() && qualifier.Name.String() != table.Name.String() {
+				return true, nil
+			}
 			node.(*ColName).Qualifier = TableName{
 				Name: NewTableIdent("t"),
 			}
@@ -358,27 +543,119 @@ func setQualifierForCols(node SQLNode) SQLNode {
 	return node
 }
 
-func buildRightTableExpr(stmt *Insert) *VirtualTableExpr {
+// buildRightTableExpr 构建merge的数据源s：
+// 多行values转换为select ... from dual union all select ... from dual，
+// insert ... select直接使用原查询，列名通过第一个select的列别名声明，
+// Oracle和达梦不支持在子查询别名后声明列名，select *按表结构展开后再设置别名
+func (this *OracleConverter) buildRightTableExpr(stmt *Insert) (*VirtualTableExpr, error) {
+	var rows SelectStatement
+	switch insertRows := stmt.Rows.(type) {
+	case Values:
+		rows = buildSelectValues(insertRows)
+	case SelectStatement:
+		rows = insertRows
+		if err := this.expandStarExprs(firstSelect(rows)); err != nil {
+			return nil, err
+		}
+	default:
+		return nil, nil
+	}
+
+	if !setSelectAliases(firstSelect(rows), stmt.Columns) {
+		return nil, fmt.Errorf("%w: select columns do not match insert columns", errors.ErrStmtConvert)
+	}
 	return &VirtualTableExpr{
 		TableName: NewTableIdent("s"),
-		Columns:   stmt.Columns,
-		Rows:      buildSelectValues(stmt),
+		Rows:      rows,
+	}, nil
+}
+
+// expandStarExprs 将查询中的*和t.*按表结构展开为带表名的列，表结构未知时返回错误
+func (this *OracleConverter) expandStarExprs(sel *Select) error {
+	if sel == nil {
+		return nil
+	}
+	exprs := make(SelectExprs, 0, len(sel.SelectExprs))
+	for _, expr := range sel.SelectExprs {
+		star, ok := expr.(*StarExpr)
+		if !ok {
+			exprs = append(exprs, expr)
+			continue
+		}
+		for _, table := range aliasedTables(sel.From) {
+			name, ok := table.Expr.(TableName)
+			if !ok {
+				return fmt.Errorf("%w: can not expand * of %s", errors.ErrStmtConvert, String(table))
+			}
+			qualifier := name
+			if !table.As.IsEmpty() {
+				qualifier = TableName{Name: table.As}
+			}
+			if !star.TableName.IsEmpty() && star.TableName.Name.String() != qualifier.Name.String() {
+				continue
+			}
+			columns := this.tableColumns[this.options.MetadataKey(name.Name.String())]
+			if len(columns) == 0 {
+				return fmt.Errorf("%w: can not expand * without columns of table %s", errors.ErrStmtConvert, name.Name.String())
+			}
+			for _, column := range columns {
+				exprs = append(exprs, &AliasedExpr{Expr: &ColName{Name: NewColIdent(column), Qualifier: qualifier}})
+			}
+		}
 	}
+	sel.SelectExprs = exprs
+	return nil
 }
 
-func buildSelectValues(stmt *Insert) SelectValues {
-	values := make([]SelectTuple, 0, 10)
-	visit := func(node SQLNode) (kcontinue bool, err error) {
-		switch node.(type) {
-		case Exprs:
-			values = append(values, SelectTuple(node.(Exprs)))
-			return true, nil
-		default:
-			return true, nil
+// aliasedTables from子句中按顺序出现的表，包括join两边的表
+func aliasedTables(exprs TableExprs) []*AliasedTableExpr {
+	tables := []*AliasedTableExpr{}
+	for _, expr := range exprs {
+		switch n := expr.(type) {
+		case *AliasedTableExpr:
+			tables = append(tables, n)
+		case *JoinTableExpr:
+			tables = append(tables, aliasedTables(TableExprs{n.LeftExpr, n.RightExpr})...)
+		case *ParenTableExpr:
+			tables = append(tables, aliasedTables(n.Exprs)...)
+		}
+	}
+	return tables
+}
+
+func buildSelectValues(rows Values) SelectStatement {
+	var union SelectStatement
+	for _, row := range rows {
+		exprs := make(SelectExprs, 0, len(row))
+		for _, expr := range row {
+			exprs = append(exprs, &AliasedExpr{Expr: expr})
 		}
+		sel := &Select{
+			SelectExprs: exprs,
+			From:        TableExprs{&AliasedTableExpr{Expr: TableName{Name: NewTableIdent("dual")}}},
+		}
+		if union == nil {
+			union = sel
+		} else {
+			union = &Union{Type: UnionAllStr, Left: union, Right: sel}
+		}
+	}
+	return union
+}
+
+func setSelectAliases(sel *Select, columns Columns) bool {
+	if sel == nil || len(sel.SelectExprs) != len(columns) {
+		return false
+	}
+	for _, expr := range sel.SelectExprs {
+		if _, ok := expr.(*AliasedExpr); !ok {
+			return false
+		}
+	}
+	for i, expr := range sel.SelectExprs {
+		expr.(*AliasedExpr).As = columns[i]
 	}
-	Walk(visit, stmt.Rows)
-	return SelectValues(values)
+	return true
 }
 
 func buildJoinConditions(stmt *Insert, condcols [][]string) Expr {
@@ -430,18 +707,27 @@ func buildJoinConditions(stmt *Insert, condcols [][]string) Expr {
 	return conditions
 }
 
-func (this *OracleConverter) replaceCommonIdents(sql string) string {
-	for old, new := range this.replaceChars {
-		sql = strings.Replace(sql, old, new, -1)
+// parseConvertStmt 解析需要转换的语句，返回nil表示原样执行：事务控制、set、show、use等语句由proxy处理或原样执行，
+// DDL由ConvertDDL转换；其它语句（包括Preview无法识别类型的，例如mysqldump的/*!40001 ...*/ select）解析后按语法树判断，
+// 只转换查询和insert/replace/update/delete，其它语句的语法树不完整，格式化后会丢失内容
+func parseConvertStmt(sql string) (Statement, error) {
+	typ := Preview(sql)
+	switch typ {
+	case StmtBegin, StmtCommit, StmtRollback, StmtSet, StmtShow, StmtUse, StmtDDL, StmtOther:
+		return nil, nil
 	}
-	return sql
-}
-
-func supportConvert(sql string) bool {
-	switch Preview(sql) {
-	case StmtInsert, StmtReplace, StmtDelete, StmtSelect, StmtUpdate:
-		return true
+	stmt, err := Parse(sql)
+	if err != nil {
+		switch typ {
+		case StmtSelect, StmtInsert, StmtReplace, StmtUpdate, StmtDelete:
+			return nil, err
+		}
+		return nil, nil
+	}
+	switch stmt.(type) {
+	case SelectStatement, *Insert, *Update, *Delete:
+		return stmt, nil
 	default:
-		return false
+		return nil, nil
 	}
 }
@@ -0,0 +1,685 @@
+package sqlparser
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+
+	"sqlproxy/core/errors"
+	"sqlproxy/core/golog"
+)
+
+const (
+	// varchar2的最大长度，超过时使用clob
+	oracleMaxVarcharLen = 4000
+	// raw的最大长度，超过时使用blob
+	oracleMaxRawLen = 2000
+)
+
+// 解析器对alter table、create index只保留了表名，这些语句的具体内容用正则拆分后，
+// 列定义、索引定义再借助create table的语法解析
+const (
+	identPattern = "(?:`(?:[^`]|``)+`|[\\w$]+)"
+	tablePattern = identPattern + "(?:\\s*\\.\\s*" + identPattern + ")?"
+)
+
+var (
+	createIndexRegexp = regexp.MustCompile(`(?is)^\s*create\s+(unique\s+|fulltext\s+|spatial\s+)?index\s+(` + identPattern + `)\s+(?:using\s+\w+\s+)?on\s+(` + tablePattern + `)\s*(\(.*\))`)
+	dropIndexRegexp   = regexp.MustCompile(`(?is)^\s*drop\s+index\s+(` + identPattern + `)\s+on\s+(` + tablePattern + `)`)
+	alterTableRegexp  = regexp.MustCompile(`(?is)^\s*alter\s+(?:ignore\s+)?table\s+(` + tablePattern + `)\s+(.*?)[\s;]*$`)
+
+	specAddIndexRegexp     = regexp.MustCompile(`(?is)^add\s+(?:constraint(?:\s+` + identPattern + `)?\s+)?((?:primary|unique|fulltext|spatial|index|key)\b.*)$`)
+	specAddColumnRegexp    = regexp.MustCompile(`(?is)^add\s+(?:column\s+)?(.+)$`)
+	specModifyRegexp       = regexp.MustCompile(`(?is)^modify\s+(?:column\s+)?(.+)$`)
+	specChangeRegexp       = regexp.MustCompile(`(?is)^change\s+(?:column\s+)?(` + identPattern + `)\s+(.+)$`)
+	specDropIndexRegexp    = regexp.MustCompile(`(?is)^drop\s+(?:index|key)\s+(` + identPattern + `)$`)
+	specDropPrimaryRegexp  = regexp.MustCompile(`(?is)^drop\s+primary\s+key$`)
+	specDropColumnRegexp   = regexp.MustCompile(`(?is)^drop\s+(?:column\s+)?(` + identPattern + `)$`)
+	specRenameColumnRegexp = regexp.MustCompile(`(?is)^rename\s+column\s+(` + identPattern + `)\s+to\s+(` + identPattern + `)$`)
+	specRenameIndexRegexp  = regexp.MustCompile(`(?is)^rename\s+(?:index|key)\s+(` + identPattern + `)\s+to\s+(` + identPattern + `)$`)
+	specRenameTableRegexp  = regexp.MustCompile(`(?is)^rename\s+(?:to\s+|as\s+)?(` + tablePattern + `)$`)
+	specTableOptionRegexp  = regexp.MustCompile(`(?is)^(?:engine|(?:default\s+)?(?:charset|character\s+set|collate)|auto_increment|row_format|comment|convert\s+to)\b`)
+
+	// 解析器不支持的写法，解析前在引号外改写：current_timestamp(3)去掉精度，索引列的asc/desc去掉，
+	// 没有名称的索引按MySQL的规则以第一列命名
+	timestampPrecisionRegexp = regexp.MustCompile(`(?i)\b(current_timestamp|localtimestamp|now)\s*\(\s*\d*\s*\)`)
+	indexColumnOrderRegexp   = regexp.MustCompile(`(?i)\s+(?:asc|desc)\s*([,)])`)
+	unnamedIndexRegexp       = regexp.MustCompile(`(?i)\b((?:primary|foreign)\s+)?((?:fulltext\s+|spatial\s+)?(?:unique\s+(?:key|index)|unique|key|index))\s*\(\s*(` + identPattern + `)`)
+
+	columnPositionRegexp     = regexp.MustCompile(`(?is)\s+(?:first|after\s+` + identPattern + `)$`)
+	tableCommentRegexp       = regexp.MustCompile(`(?is)\bcomment\s*=?\s*('(?:[^'\\]|\\.|'')*')`)
+	tableAutoIncrementRegexp = regexp.MustCompile(`(?is)\bauto_increment\s*=?\s*(\d+)`)
+)
+
+// oracleDDL 一条MySQL DDL转换出的语句，索引、注释等在Oracle中需要单独的语句，放在extras中最后执行
+type oracleDDL struct {
+	*OracleConverter
+	table   TableName
+	primary Columns
+	stmts   []string
+	extras  []string
+}
+
+// ConvertDDL 将MySQL的DDL转换为达梦/Oracle的语句，一条DDL可能转换为多条语句，需要按顺序执行：
+// create table中的普通索引、唯一索引转为单独的create index，列和表的注释转为comment on，
+// engine、charset等表选项直接去掉
+func (this *OracleConverter) ConvertDDL(sql string) ([]string, error) {
+	if Preview(sql) != StmtDDL {
+		return nil, fmt.Errorf("%w: not a ddl statement", errors.ErrStmtConvert)
+	}
+	var (
+		stmts []string
+		err   error
+	)
+	if m := createIndexRegexp.FindStringSubmatch(sql); m != nil {
+		stmts, err = this.convertCreateIndex(m[1], m[2], m[3], m[4])
+	} else if m := dropIndexRegexp.FindStringSubmatch(sql); m != nil {
+		stmts, err = this.convertDropIndex(m[1], m[2])
+	} else if m := alterTableRegexp.FindStringSubmatch(sql); m != nil {
+		stmts, err = this.convertAlterTable(m[1], m[2])
+	} else {
+		stmts, err = this.convertDDLStmt(sql)
+	}
+	if err != nil {
+		golog.Warn("OracleConverter", "ConvertDDL", err.Error(), 0, "sql", sql)
+		return nil, err
+	}
+	golog.Debug("OracleConverter", "ConvertDDL", "ConvertSQL", 0, strings.Join(stmts, ";\n"))
+	return stmts, nil
+}
+
+func (this *OracleConverter) convertDDLStmt(sql string) ([]string, error) {
+	stmt, err := Parse(normalizeDDL(sql))
+	if err != nil {
+		return nil, err
+	}
+	ddl, ok := stmt.(*DDL)
+	if !ok {
+		return nil, fmt.Errorf("%w: can not convert ddl: %s", errors.ErrStmtConvert, sql)
+	}
+	d := this.newDDL(ddl.Table)
+	switch ddl.Action {
+	case CreateStr:
+		// create view、create table ... like、create table ... as select等语句没有TableSpec，
+		// MySQL的原始语句在目标库上无法正确执行，返回错误
+		if ddl.TableSpec == nil {
+			return nil, fmt.Errorf("%w: can not convert ddl: %s", errors.ErrStmtConvert, sql)
+		}
+		d.table = ddl.NewName
+		if err := d.createTable(ddl.TableSpec); err != nil {
+			return nil, err
+		}
+	case DropStr:
+		// drop table if exists 达梦支持，Oracle需要23c及以上版本，使用PL/SQL忽略表不存在的错误（ORA-00942）
+		switch {
+		case !ddl.IfExists:
+			d.add("drop table %v", ddl.Table)
+		case this.options.DriverName == "dm":
+			d.add("drop table if exists %v", ddl.Table)
+		default:
+			d.add("begin execute immediate %v; exception when others then if sqlcode != -942 then raise; end if; end;",
+				NewStrVal([]byte(d.sprintf("drop table %v", ddl.Table))))
+		}
+	case TruncateStr:
+		d.add("truncate table %v", ddl.Table)
+	case RenameStr:
+		// Oracle的rename to中新表名不能带schema
+		d.add("alter table %v rename to %v", ddl.Table, ddl.NewName.Name)
+	default:
+		return nil, fmt.Errorf("%w: can not convert ddl: %s", errors.ErrStmtConvert, sql)
+	}
+	return d.result(), nil
+}
+
+func (this *OracleConverter) newDDL(table TableName) *oracleDDL {
+	return &oracleDDL{OracleConverter: this, table: table}
+}
+
+func (this *OracleConverter) convertCreateIndex(kind, name, table, columns string) ([]string, error) {
+	tableName, err := parseTableName(table)
+	if err != nil {
+		return nil, err
+	}
+	indexes, err := parseIndexDefs(fmt.Sprintf("%skey %s %s", kind, name, columns))
+	if err != nil {
+		return nil, err
+	}
+	d := this.newDDL(tableName)
+	for _, idx := range indexes {
+		d.addIndex(idx)
+	}
+	return d.result(), nil
+}
+
+func (this *OracleConverter) convertDropIndex(name, table string) ([]string, error) {
+	tableName, err := parseTableName(table)
+	if err != nil {
+		return nil, err
+	}
+	d := this.newDDL(tableName)
+	d.add("drop index %v", d.indexName(unquoteIdent(name)))
+	return d.result(), nil
+}
+
+func (this *OracleConverter) convertAlterTable(table, specs string) ([]string, error) {
+	tableName, err := parseTableName(table)
+	if err != nil {
+		return nil, err
+	}
+	d := this.newDDL(tableName)
+	// 同一语句中没有名称的索引需要一起命名，避免重名
+	for _, spec := range splitAlterSpecs(normalizeDDL(specs)) {
+		if err := d.alterSpec(spec); err != nil {
+			return nil, err
+		}
+	}
+	return d.result(), nil
+}
+
+func (this *oracleDDL) createTable(spec *TableSpec) error {
+	start := 1
+	if m := tableAutoIncrementRegexp.FindStringSubmatch(spec.Options); m != nil {
+		start, _ = strconv.Atoi(m[1])
+	}
+	defs := []string{}
+	for _, col := range spec.Columns {
+		def, err := this.columnDef(col, start)
+		if err != nil {
+			return err
+		}
+		defs = append(defs, def)
+	}
+	for _, idx := range spec.Indexes {
+		this.addIndex(idx)
+	}
+	if len(this.primary) > 0 {
+		defs = append(defs, this.sprintf("primary key %v", this.primary))
+	}
+	this.add("create table %v (%s)", this.table, strings.Join(defs, ", "))
+	this.addTableOptions(spec.Options)
+	return nil
+}
+
+func (this *oracleDDL) alterSpec(spec string) error {
+	if m := specAddIndexRegexp.FindStringSubmatch(spec); m != nil {
+		indexes, err := parseIndexDefs(m[1])
+		if err != nil {
+			return err
+		}
+		for _, idx := range indexes {
+			this.addIndex(idx)
+		}
+		this.addPrimary()
+		return nil
+	}
+	if m := specAddColumnRegexp.FindStringSubmatch(spec); m != nil {
+		defs := strings.TrimSpace(m[1])
+		if strings.HasPrefix(defs, "(") && strings.HasSuffix(defs, ")") {
+			defs = defs[1 : len(defs)-1]
+		}
+		return this.alterColumns("add", defs)
+	}
+	if m := specModifyRegexp.FindStringSubmatch(spec); m != nil {
+		return this.alterColumns("modify", m[1])
+	}
+	if m := specChangeRegexp.FindStringSubmatch(spec); m != nil {
+		ts, err := parseTableSpec(columnPositionRegexp.ReplaceAllString(m[2], ""))
+		if err != nil {
+			return err
+		}
+		if len(ts.Columns) != 1 {
+			return fmt.Errorf("%w: can not parse column definition: %s", errors.ErrStmtConvert, m[2])
+		}
+		if oldName := unquoteIdent(m[1]); !ts.Columns[0].Name.Equal(oldName) {
+			this.add("alter table %v rename column %v to %v", this.table, oldName, ts.Columns[0].Name)
+		}
+		return this.alterColumns("modify", m[2])
+	}
+	if m := specDropIndexRegexp.FindStringSubmatch(spec); m != nil {
+		this.add("drop index %v", this.indexName(unquoteIdent(m[1])))
+		return nil
+	}
+	if specDropPrimaryRegexp.MatchString(spec) {
+		this.add("alter table %v drop primary key", this.table)
+		return nil
+	}
+	if m := specDropColumnRegexp.FindStringSubmatch(spec); m != nil {
+		this.add("alter table %v drop column %v", this.table, unquoteIdent(m[1]))
+		return nil
+	}
+	if m := specRenameColumnRegexp.FindStringSubmatch(spec); m != nil {
+		this.add("alter table %v rename column %v to %v", this.table, unquoteIdent(m[1]), unquoteIdent(m[2]))
+		return nil
+	}
+	if m := specRenameIndexRegexp.FindStringSubmatch(spec); m != nil {
+		this.add("alter index %v rename to %v", this.indexName(unquoteIdent(m[1])), this.indexName(unquoteIdent(m[2])))
+		return nil
+	}
+	if m := specRenameTableRegexp.FindStringSubmatch(spec); m != nil {
+		newName, err := parseTableName(m[1])
+		if err != nil {
+			return err
+		}
+		this.add("alter table %v rename to %v", this.table, newName.Name)
+		return nil
+	}
+	if specTableOptionRegexp.MatchString(spec) {
+		this.addTableOptions(spec)
+		return nil
+	}
+	return fmt.Errorf("%w: unsupported alter table specification: %s", errors.ErrStmtConvert, spec)
+}
+
+// alterColumns 生成alter table t add/modify (...)，列上的主键、索引和注释转为单独的语句
+func (this *oracleDDL) alterColumns(action, defs string) error {
+	spec, err := parseTableSpec(columnPositionRegexp.ReplaceAllString(strings.TrimSpace(defs), ""))
+	if err != nil {
+		return err
+	}
+	cols := []string{}
+	for _, col := range spec.Columns {
+		def, err := this.columnDef(col, 1)
+		if err != nil {
+			return err
+		}
+		cols = append(cols, def)
+	}
+	this.add("alter table %v %s (%s)", this.table, action, strings.Join(cols, ", "))
+	for _, idx := range spec.Indexes {
+		this.addIndex(idx)
+	}
+	this.addPrimary()
+	return nil
+}
+
+// columnDef 生成列定义："c" type [default x | identity] [not null] [check (...)]
+func (this *oracleDDL) columnDef(col *ColumnDefinition, identityStart int) (string, error) {
+	ct := &col.Type
+	typ, err := oracleColumnType(ct)
+	if err != nil {
+		return "", err
+	}
+	buf := NewTrackedBuffer(this.formatNode)
+	buf.Myprintf("%v %s", col.Name, typ)
+	if ct.Autoincrement {
+		buf.Myprintf(" %s", this.identityClause(identityStart))
+	} else if ct.Default != nil {
+		buf.Myprintf(" default ")
+		this.formatDefault(buf, ct)
+	}
+	if ct.NotNull {
+		buf.Myprintf(" not null")
+	}
+	if strings.ToLower(ct.Type) == "enum" {
+		buf.Myprintf(" check (%v in (", col.Name)
+		for i, v := range ct.EnumValues {
+			if i > 0 {
+				buf.Myprintf(", ")
+			}
+			buf.Myprintf("%v", NewStrVal([]byte(unquoteEnumValue(v))))
+		}
+		buf.Myprintf("))")
+	} else if ct.Unsigned {
+		buf.Myprintf(" check (%v >= 0)", col.Name)
+	}
+	if ct.OnUpdate != nil {
+		// on update current_timestamp使用触发器实现，update语句中没有指定该列时更新为当前时间，
+		// updating中加引号的列名区分大小写
+		this.addExtra("create or replace trigger %v before update on %v for each row begin if not updating(%v) then :new.%v := %s; end if; end;",
+			this.indexName(NewColIdent(col.Name.String()+"_on_update")), this.table, NewStrVal([]byte(this.sprintf("%v", col.Name))), col.Name, currentTimeFunc(ct))
+	}
+	if ct.Comment != nil {
+		this.addExtra("comment on column %v.%v is %v", this.table, col.Name, ct.Comment)
+	}
+	switch ct.KeyOpt {
+	case colKeyPrimary:
+		this.primary = append(this.primary, col.Name)
+	case colKeyUnique, colKeyUniqueKey:
+		this.addExtra("create unique index %v on %v (%v)", this.indexName(col.Name), this.table, col.Name)
+	case colKey:
+		this.addExtra("create index %v on %v (%v)", this.indexName(col.Name), this.table, col.Name)
+	}
+	return buf.String(), nil
+}
+
+// identityClause 自增列：达梦使用identity(start, 1)，Oracle 12c+使用generated by default as identity
+func (this *OracleConverter) identityClause(start int) string {
+	if this.options.DriverName == "dm" {
+		return fmt.Sprintf("identity(%d, 1)", start)
+	}
+	return fmt.Sprintf("generated by default as identity (start with %d)", start)
+}
+
+func (this *oracleDDL) formatDefault(buf *TrackedBuffer, ct *ColumnType) {
+	val := ct.Default
+	typ := strings.ToLower(ct.Type)
+	switch {
+	case val.Type == ValArg:
+		// current_timestamp、null
+		if strings.ToLower(string(val.Val)) == "current_timestamp" {
+			buf.WriteString(currentTimeFunc(ct))
+			return
+		}
+		buf.Write(val.Val)
+	case val.Type == BitVal:
+		n, _ := strconv.ParseUint(string(val.Val), 2, 64)
+		buf.WriteString(strconv.FormatUint(n, 10))
+	case val.Type == StrVal && typ == "date":
+		buf.Myprintf("date %v", val)
+	case val.Type == StrVal && (typ == "datetime" || typ == "timestamp"):
+		buf.Myprintf("timestamp %v", val)
+	default:
+		buf.Myprintf("%v", val)
+	}
+}
+
+// currentTimeFunc 当前时间，date类型使用sysdate，其它使用systimestamp
+func currentTimeFunc(ct *ColumnType) string {
+	if strings.ToLower(ct.Type) == "date" {
+		return "sysdate"
+	}
+	return "systimestamp"
+}
+
+// addIndex 普通索引和唯一索引转为单独的create index语句，主键在建表或改表语句中处理
+func (this *oracleDDL) addIndex(idx *IndexDefinition) {
+	if idx.Info.Primary {
+		for _, col := range idx.Columns {
+			this.primary = append(this.primary, col.Column)
+		}
+		return
+	}
+	if idx.Info.Spatial || strings.Contains(strings.ToLower(idx.Info.Type), "fulltext") {
+		golog.Warn("OracleConverter", "ConvertDDL", "ignore unsupported index", 0, "index", idx.Info.Name.String())
+		return
+	}
+	// 前缀索引的长度去掉
+	cols := Columns{}
+	for _, col := range idx.Columns {
+		cols = append(cols, col.Column)
+	}
+	unique := ""
+	if idx.Info.Unique {
+		unique = "unique "
+	}
+	this.addExtra("create %sindex %v on %v %v", unique, this.indexName(idx.Info.Name), this.table, cols)
+}
+
+// addPrimary alter table中新增的主键
+func (this *oracleDDL) addPrimary() {
+	if len(this.primary) == 0 {
+		return
+	}
+	this.add("alter table %v add primary key %v", this.table, this.primary)
+	this.primary = nil
+}
+
+// indexName Oracle中索引名在schema内唯一，MySQL中只需要在表内唯一，索引名前加上表名避免冲突
+func (this *oracleDDL) indexName(name ColIdent) ColIdent {
+	return NewColIdent(this.table.Name.String() + "_" + name.String())
+}
+
+// addTableOptions 表注释转为comment on table，engine、charset等选项直接去掉
+func (this *oracleDDL) addTableOptions(options string) {
+	if m := tableCommentRegexp.FindStringSubmatch(options); m != nil {
+		this.addExtra("comment on table %v is %v", this.table, NewStrVal([]byte(unquoteString(m[1]))))
+	}
+}
+
+func (this *oracleDDL) sprintf(format string, values ...interface{}) string {
+	buf := NewTrackedBuffer(this.formatNode)
+	buf.Myprintf(format, values...)
+	return buf.String()
+}
+
+func (this *oracleDDL) add(format string, values ...interface{}) {
+	this.stmts = append(this.stmts, this.sprintf(format, values...))
+}
+
+func (this *oracleDDL) addExtra(format string, values ...interface{}) {
+	this.extras = append(this.extras, this.sprintf(format, values...))
+}
+
+func (this *oracleDDL) result() []string {
+	return append(this.stmts, this.extras...)
+}
+
+// oracleColumnType 将MySQL的列类型转换为达梦/Oracle中的类型，整数按MySQL的取值范围转为number(p)，
+// 无符号的bigint超出了number(19)的范围，使用number(20)
+func oracleColumnType(ct *ColumnType) (string, error) {
+	length := sqlValInt(ct.Length, 0)
+	switch typ := strings.ToLower(ct.Type); typ {
+	case "bool", "boolean":
+		return "number(1)", nil
+	case "tinyint":
+		return "number(3)", nil
+	case "smallint":
+		return "number(5)", nil
+	case "mediumint":
+		return "number(7)", nil
+	case "int", "integer":
+		return "number(10)", nil
+	case "bigint":
+		if ct.Unsigned {
+			return "number(20)", nil
+		}
+		return "number(19)", nil
+	case "bit":
+		if length <= 0 {
+			length = 1
+		}
+		if length >= 64 {
+			return "number(20)", nil
+		}
+		return fmt.Sprintf("number(%d)", len(strconv.FormatUint(1<<uint(length)-1, 10))), nil
+	case "decimal", "numeric":
+		if ct.Scale != nil {
+			return fmt.Sprintf("number(%d,%d)", sqlValInt(ct.Length, 10), sqlValInt(ct.Scale, 0)), nil
+		}
+		return fmt.Sprintf("number(%d)", sqlValInt(ct.Length, 10)), nil
+	case "float":
+		return "float", nil
+	case "double", "real":
+		return "double precision", nil
+	case "char":
+		return fmt.Sprintf("char(%d char)", sqlValInt(ct.Length, 1)), nil
+	case "varchar":
+		if length > oracleMaxVarcharLen {
+			return "clob", nil
+		}
+		return fmt.Sprintf("varchar2(%d char)", length), nil
+	case "binary":
+		return fmt.Sprintf("raw(%d)", sqlValInt(ct.Length, 1)), nil
+	case "varbinary":
+		if length > oracleMaxRawLen {
+			return "blob", nil
+		}
+		return fmt.Sprintf("raw(%d)", length), nil
+	case "tinytext":
+		return "varchar2(255 char)", nil
+	case "text", "mediumtext", "longtext", "json":
+		return "clob", nil
+	case "tinyblob", "blob", "mediumblob", "longblob":
+		return "blob", nil
+	case "date":
+		return "date", nil
+	case "datetime", "timestamp":
+		return fmt.Sprintf("timestamp(%d)", length), nil
+	case "time":
+		// Oracle没有time类型，按MySQL的格式（-838:59:59.000000）保存为字符串
+		return "varchar2(20 char)", nil
+	case "year":
+		return "number(4)", nil
+	case "enum", "set":
+		size := 0
+		for _, v := range ct.EnumValues {
+			n := utf8.RuneCountInString(unquoteEnumValue(v))
+			if typ == "set" {
+				size += n + 1
+			} else if n > size {
+				size = n
+			}
+		}
+		if size == 0 {
+			size = 1
+		}
+		return fmt.Sprintf("varchar2(%d char)", size), nil
+	default:
+		return "", fmt.Errorf("%w: unsupported column type %s", errors.ErrStmtConvert, ct.Type)
+	}
+}
+
+func sqlValInt(val *SQLVal, def int) int {
+	if val == nil {
+		return def
+	}
+	n, err := strconv.Atoi(string(val.Val))
+	if err != nil {
+		return def
+	}
+	return n
+}
+
+// parseTableSpec 借助create table的语法解析列定义和索引定义
+func parseTableSpec(defs string) (*TableSpec, error) {
+	stmt, err := Parse(normalizeDDL("create table `t` (" + defs + ")"))
+	if err != nil {
+		return nil, err
+	}
+	// 解析失败时解析器只返回表名
+	if ddl, ok := stmt.(*DDL); ok && ddl.TableSpec != nil {
+		return ddl.TableSpec, nil
+	}
+	return nil, fmt.Errorf("%w: can not parse definition: %s", errors.ErrStmtConvert, defs)
+}
+
+// parseIndexDefs create table中至少需要一列，加一个占位列解析索引定义
+func parseIndexDefs(defs string) ([]*IndexDefinition, error) {
+	spec, err := parseTableSpec("`c` int, " + defs)
+	if err != nil {
+		return nil, err
+	}
+	return spec.Indexes, nil
+}
+
+// normalizeDDL 在引号外改写解析器不支持的写法，见timestampPrecisionRegexp等
+func normalizeDDL(sql string) string {
+	used := map[string]int{}
+	nameIndex := func(m string) string {
+		sub := unnamedIndexRegexp.FindStringSubmatch(m)
+		if sub[1] != "" {
+			return m
+		}
+		// MySQL中同名时依次加上_2、_3
+		name := unquoteIdent(sub[3]).String()
+		used[strings.ToLower(name)]++
+		if n := used[strings.ToLower(name)]; n > 1 {
+			name = fmt.Sprintf("%s_%d", name, n)
+		}
+		return fmt.Sprintf("%s `%s` (%s", sub[2], strings.Replace(name, "`", "``", -1), sub[3])
+	}
+	return replaceUnquoted(sql, func(text string) string {
+		text = timestampPrecisionRegexp.ReplaceAllString(text, "$1")
+		text = indexColumnOrderRegexp.ReplaceAllString(text, "$1")
+		return unnamedIndexRegexp.ReplaceAllStringFunc(text, nameIndex)
+	})
+}
+
+// replaceUnquoted 对引号（包括反引号）之外的文本做替换，字符串常量和标识符保持不变
+func replaceUnquoted(sql string, replace func(string) string) string {
+	var (
+		buf   strings.Builder
+		quote byte
+		start int
+	)
+	for i := 0; i < len(sql); i++ {
+		c := sql[i]
+		switch {
+		case quote != 0:
+			if c == '\\' && quote != '`' {
+				i++
+			} else if c == quote {
+				quote = 0
+				buf.WriteString(sql[start : i+1])
+				start = i + 1
+			}
+		case c == '\'' || c == '"' || c == '`':
+			buf.WriteString(replace(sql[start:i]))
+			quote, start = c, i
+		}
+	}
+	if quote != 0 {
+		buf.WriteString(sql[start:])
+	} else {
+		buf.WriteString(replace(sql[start:]))
+	}
+	return buf.String()
+}
+
+func parseTableName(name string) (TableName, error) {
+	stmt, err := Parse("truncate table " + name)
+	if err != nil {
+		return TableName{}, err
+	}
+	return stmt.(*DDL).Table, nil
+}
+
+// splitAlterSpecs 按括号和引号外的逗号拆分alter table的多个子句
+func splitAlterSpecs(specs string) []string {
+	var (
+		result []string
+		quote  byte
+		depth  int
+		start  int
+	)
+	for i := 0; i < len(specs); i++ {
+		c := specs[i]
+		switch {
+		case quote != 0:
+			if c == '\\' && quote != '`' {
+				i++
+			} else if c == quote {
+				quote = 0
+			}
+		case c == '\'' || c == '"' || c == '`':
+			quote = c
+		case c == '(':
+			depth++
+		case c == ')':
+			depth--
+		case c == ',' && depth == 0:
+			result = append(result, strings.TrimSpace(specs[start:i]))
+			start = i + 1
+		}
+	}
+	if last := strings.TrimSpace(specs[start:]); last != "" {
+		result = append(result, last)
+	}
+	return result
+}
+
+func unquoteIdent(name string) ColIdent {
+	if len(name) >= 2 && name[0] == '`' {
+		name = strings.Replace(name[1:len(name)-1], "``", "`", -1)
+	}
+	return NewColIdent(name)
+}
+
+// unquoteString 使用词法解析去掉MySQL字符串的引号和转义
+func unquoteString(quoted string) string {
+	typ, val := NewStringTokenizer(quoted).Scan()
+	if typ != STRING {
+		return quoted
+	}
+	return string(val)
+}
+
+// 解析器中的enum值是加了单引号的原始值
+func unquoteEnumValue(v string) string {
+	if len(v) >= 2 && v[0] == '\'' && v[len(v)-1] == '\'' {
+		return v[1 : len(v)-1]
+	}
+	return v
+}
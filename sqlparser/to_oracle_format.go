@@ -0,0 +1,107 @@
+package sqlparser
+
+import (
+	"strings"
+)
+
+// MySQL中的时间零值在Oracle中不合法，替换为Oracle支持的最小时间
+var oracleZeroDates = map[string]string{
+	"0000-00-00 00:00:00": "0001-01-01 00:00:00",
+	"0000-00-00":          "0001-01-01",
+}
+
+// formatNode 是Oracle方言的NodeFormatter，只处理语法不同的节点，其它节点保持MySQL的输出
+func (this *OracleConverter) formatNode(buf *TrackedBuffer, node SQLNode) {
+	switch n := node.(type) {
+	case TableIdent:
+		this.formatIdent(buf, n.String())
+	case ColIdent:
+		this.formatIdent(buf, n.String())
+	case *SQLVal:
+		formatOracleVal(buf, n)
+	case *ComparisonExpr:
+		formatLikeEscape(buf, n)
+	case *AliasedTableExpr:
+		formatOracleTableExpr(buf, n)
+	case *Limit:
+		formatOracleLimit(buf, n)
+	default:
+		node.Format(buf)
+	}
+}
+
+// formatIdent 标识符统一使用双引号，配置了大写时转为大写，与Oracle对不加引号标识符的处理一致
+func (this *OracleConverter) formatIdent(buf *TrackedBuffer, name string) {
+	if strings.ToLower(name) == "dual" {
+		buf.WriteString(name)
+		return
+	}
+	if this.options.UpperCaseIdent {
+		name = strings.ToUpper(name)
+	}
+	buf.WriteByte('"')
+	buf.WriteString(strings.Replace(name, `"`, `""`, -1))
+	buf.WriteByte('"')
+}
+
+// formatOracleVal 字符串常量使用Oracle的转义方式：单引号写两次，反斜杠不转义
+func formatOracleVal(buf *TrackedBuffer, node *SQLVal) {
+	if node.Type != StrVal {
+		node.Format(buf)
+		return
+	}
+	val := string(node.Val)
+	if zero, ok := oracleZeroDates[val]; ok {
+		val = zero
+	}
+	buf.WriteByte('\'')
+	buf.WriteString(strings.Replace(val, "'", "''", -1))
+	buf.WriteByte('\'')
+}
+
+// formatLikeEscape MySQL的like默认使用反斜杠转义通配符，Oracle和SQLite没有默认的转义字符，
+// 模式串中有反斜杠时加上escape '\'，并去掉MySQL中对普通字符多余的转义（Oracle要求转义字符后只能是%、_或转义字符本身）
+func formatLikeEscape(buf *TrackedBuffer, node *ComparisonExpr) {
+	pattern, ok := node.Right.(*SQLVal)
+	if (node.Operator != LikeStr && node.Operator != NotLikeStr) || node.Escape != nil ||
+		!ok || pattern.Type != StrVal || !strings.Contains(string(pattern.Val), `\`) {
+		node.Format(buf)
+		return
+	}
+	buf.Myprintf("%v %s %v escape %v", node.Left, node.Operator,
+		NewStrVal(normalizeLikeEscape(pattern.Val)), NewStrVal([]byte(`\`)))
+}
+
+func normalizeLikeEscape(val []byte) []byte {
+	out := make([]byte, 0, len(val)+1)
+	for i := 0; i < len(val); i++ {
+		if val[i] != '\\' {
+			out = append(out, val[i])
+			continue
+		}
+		if i+1 == len(val) {
+			// 末尾的反斜杠在MySQL中匹配反斜杠本身
+			out = append(out, '\\', '\\')
+			break
+		}
+		i++
+		switch val[i] {
+		case '%', '_', '\\':
+			out = append(out, '\\', val[i])
+		default:
+			out = append(out, val[i])
+		}
+	}
+	return out
+}
+
+// Oracle中表的别名前不能有as
+func formatOracleTableExpr(buf *TrackedBuffer, node *AliasedTableExpr) {
+	buf.Myprintf("%v%v", node.Expr, node.Partitions)
+	if !node.As.IsEmpty() {
+		buf.Myprintf(" %v", node.As)
+	}
+	if node.Hints != nil {
+		buf.Myprintf("%v", node.Hints)
+	}
+}
@@ -0,0 +1,516 @@
+package sqlparser
+
+import (
+	"strconv"
+	"strings"
+
+	"sqlproxy/core/golog"
+)
+
+// funcConverter 将MySQL函数的参数转换为等价的Oracle表达式，不支持转换时返回nil
+type funcConverter func(args []Expr) Expr
+
+// MySQL函数到Oracle表达式的映射，key为小写的函数名
+var oracleFuncConverters = map[string]funcConverter{
+	"ifnull":            convertIfnull,
+	"if":                convertIf,
+	"now":               convertSysdate,
+	"sysdate":           convertSysdate,
+	"current_timestamp": convertSysdate,
+	"localtime":         convertSysdate,
+	"localtimestamp":    convertSysdate,
+	"curdate":           convertCurdate,
+	"current_date":      convertCurdate,
+	"unix_timestamp":    convertUnixTimestamp,
+	"from_unixtime":     convertFromUnixtime,
+	"date_format":       convertDateFormat,
+	"date_add":          convertDateAdd,
+	"adddate":           convertDateAdd,
+	"date_sub":          convertDateSub,
+	"subdate":           convertDateSub,
+	"concat":            convertConcat,
+	"substring_index":   convertSubstringIndex,
+}
+
+const (
+	oracleEpoch = "to_date('1970-01-01', 'yyyy-mm-dd')"
+	// 时间戳在MySQL中是UTC秒数，转为会话时区的时间
+	oracleFromUnixtime = "cast(from_tz(cast(" + oracleEpoch + " + numtodsinterval(%v, 'second') as timestamp), 'UTC') at time zone sessiontimezone as date)"
+)
+
+// funcDialect 一种目标库的函数转换规则
+type funcDialect struct {
+	funcs       map[string]funcConverter
+	groupConcat func(node *GroupConcatExpr) Expr
+	interval    func(date Expr, interval *IntervalExpr, sub bool) Expr
+}
+
+var oracleFuncDialect = funcDialect{
+	funcs:       oracleFuncConverters,
+	groupConcat: convertGroupConcat,
+	interval:    convertIntervalArith,
+}
+
+// convertFuncs 将语句中的MySQL函数、日期间隔运算和group_concat转换为Oracle的写法
+func (this *OracleConverter) convertFuncs(stmt Statement) Statement {
+	return convertStmtFuncs(stmt, oracleFuncDialect)
+}
+
+// convertStmtFuncs 按目标库的规则转换语句中的函数，
+// 先转换内层表达式再转换外层，保证嵌套的函数都能被转换
+func convertStmtFuncs(stmt Statement, dialect funcDialect) Statement {
+	targets := []Expr{}
+	visit := func(node SQLNode) (kcontinue bool, err error) {
+		switch n := node.(type) {
+		case *FuncExpr, *GroupConcatExpr:
+			targets = append(targets, n.(Expr))
+		case *BinaryExpr:
+			if dialect.interval != nil && isIntervalArith(n) {
+				targets = append(targets, n)
+			}
+		}
+		return true, nil
+	}
+	_ = Walk(visit, stmt)
+
+	for i := len(targets) - 1; i >= 0; i-- {
+		var to Expr
+		switch n := targets[i].(type) {
+		case *FuncExpr:
+			to = convertFuncExpr(n, dialect.funcs)
+		case *GroupConcatExpr:
+			if dialect.groupConcat != nil {
+				to = dialect.groupConcat(n)
+			}
+		case *BinaryExpr:
+			to = dialect.interval(n.Left, n.Right.(*IntervalExpr), n.Operator == MinusStr)
+		}
+		if to != nil {
+			replaceExprInStmt(stmt, targets[i], to)
+		}
+	}
+	return stmt
+}
+
+func convertFuncExpr(node *FuncExpr, funcs map[string]funcConverter) Expr {
+	if !node.Qualifier.IsEmpty() || node.Distinct {
+		return nil
+	}
+	name := node.Name.Lowered()
+	converter, ok := funcs[name]
+	if !ok {
+		return nil
+	}
+	args := make([]Expr, 0, len(node.Exprs))
+	for _, expr := range node.Exprs {
+		aliased, ok := expr.(*AliasedExpr)
+		if !ok {
+			return nil
+		}
+		args = append(args, aliased.Expr)
+	}
+	to := converter(args)
+	if to == nil {
+		golog.Debug("SQLConverter", "convertFuncExpr", "unsupported function arguments", 0, "func", name)
+	}
+	return to
+}
+
+func newFuncExpr(name string, args ...Expr) *FuncExpr {
+	exprs := make(SelectExprs, 0, len(args))
+	for _, arg := range args {
+		exprs = append(exprs, &AliasedExpr{Expr: arg})
+	}
+	return &FuncExpr{Name: NewColIdent(name), Exprs: exprs}
+}
+
+func newTemplateExpr(template string, exprs ...Expr) *TemplateExpr {
+	return &TemplateExpr{Template: template, Exprs: exprs}
+}
+
+// ifnull(a, b) -> nvl(a, b)
+func convertIfnull(args []Expr) Expr {
+	if len(args) != 2 {
+		return nil
+	}
+	return newFuncExpr("nvl", args...)
+}
+
+// if(cond, a, b) -> case when cond then a else b end
+func convertIf(args []Expr) Expr {
+	if len(args) != 3 {
+		return nil
+	}
+	cond := args[0]
+	if !isBoolExpr(cond) {
+		// MySQL中非0即为真，Oracle的when后面必须是条件表达式
+		cond = &ComparisonExpr{Operator: NotEqualStr, Left: cond, Right: NewIntVal([]byte("0"))}
+	}
+	return &CaseExpr{
+		Whens: []*When{{Cond: cond, Val: args[1]}},
+		Else:  args[2],
+	}
+}
+
+func isBoolExpr(expr Expr) bool {
+	switch n := expr.(type) {
+	case *ComparisonExpr, *AndExpr, *OrExpr, *NotExpr, *IsExpr, *RangeCond, *ExistsExpr:
+		return true
+	case *ParenExpr:
+		return isBoolExpr(n.Expr)
+	default:
+		return false
+	}
+}
+
+// now() -> sysdate
+func convertSysdate(args []Expr) Expr {
+	if len(args) != 0 {
+		return nil
+	}
+	return newTemplateExpr("sysdate")
+}
+
+// curdate() -> trunc(sysdate)
+func convertCurdate(args []Expr) Expr {
+	if len(args) != 0 {
+		return nil
+	}
+	return newTemplateExpr("trunc(sysdate)")
+}
+
+// unix_timestamp([d]) -> 与1970-01-01 UTC相差的秒数
+func convertUnixTimestamp(args []Expr) Expr {
+	switch len(args) {
+	case 0:
+		return newTemplateExpr("round((cast(sys_extract_utc(systimestamp) as date) - " + oracleEpoch + ") * 86400)")
+	case 1:
+		return newTemplateExpr("round((cast(sys_extract_utc(cast(%v as timestamp)) as date) - "+oracleEpoch+") * 86400)", args[0])
+	default:
+		return nil
+	}
+}
+
+// from_unixtime(ts[, format]) -> 1970-01-01 UTC加上ts秒，有format时再to_char
+func convertFromUnixtime(args []Expr) Expr {
+	switch len(args) {
+	case 1:
+		return newTemplateExpr(oracleFromUnixtime, args[0])
+	case 2:
+		format := convertDateFormatArg(args[1], oracleDateFormats)
+		if format == nil {
+			return nil
+		}
+		return newTemplateExpr("to_char("+oracleFromUnixtime+", %v)", args[0], format)
+	default:
+		return nil
+	}
+}
+
+// date_format(d, format) -> to_char(d, oracle_format)
+func convertDateFormat(args []Expr) Expr {
+	if len(args) != 2 {
+		return nil
+	}
+	format := convertDateFormatArg(args[1], oracleDateFormats)
+	if format == nil {
+		return nil
+	}
+	return newFuncExpr("to_char", args[0], format)
+}
+
+// 只能转换字符串常量的格式，绑定参数在转换时拿不到值
+func convertDateFormatArg(expr Expr, formats map[byte]string) Expr {
+	val, ok := expr.(*SQLVal)
+	if !ok || val.Type != StrVal {
+		return nil
+	}
+	return NewStrVal([]byte(convertDateFormatMask(string(val.Val), formats)))
+}
+
+// MySQL date_format格式符到Oracle to_char格式的映射
+var oracleDateFormats = map[byte]string{
+	'Y': "yyyy",
+	'y': "yy",
+	'm': "mm",
+	'c': "fmmmfm",
+	'd': "dd",
+	'e': "fmddfm",
+	'H': "hh24",
+	'k': "fmhh24fm",
+	'h': "hh12",
+	'I': "hh12",
+	'l': "fmhh12fm",
+	'i': "mi",
+	's': "ss",
+	'S': "ss",
+	'f': "ff6",
+	'p': "AM",
+	'M': "fmMonthfm",
+	'b': "Mon",
+	'W': "fmDayfm",
+	'a': "Dy",
+	'j': "ddd",
+	'D': "fmddthfm",
+	'U': "ww",
+	'u': "iw",
+	'T': "hh24:mi:ss",
+	'r': "hh12:mi:ss AM",
+}
+
+// convertDateFormatMask 转换date_format的格式串，
+// 格式符之外的字母等文本在Oracle中需要用双引号括起来，标点和空格保持原样
+func convertDateFormatMask(mask string, formats map[byte]string) string {
+	var buf, text strings.Builder
+	flushText := func() {
+		if text.Len() == 0 {
+			return
+		}
+		buf.WriteString(`"`)
+		buf.WriteString(text.String())
+		buf.WriteString(`"`)
+		text.Reset()
+	}
+	for i := 0; i < len(mask); i++ {
+		c := mask[i]
+		if c == '%' && i+1 < len(mask) {
+			i++
+			if f, ok := formats[mask[i]]; ok {
+				flushText()
+				buf.WriteString(f)
+			} else {
+				// %%及未知的格式符输出字符本身
+				text.WriteByte(mask[i])
+			}
+			continue
+		}
+		if strings.IndexByte(" -/,.;:", c) >= 0 {
+			flushText()
+			buf.WriteByte(c)
+			continue
+		}
+		text.WriteByte(c)
+	}
+	flushText()
+	return buf.String()
+}
+
+// date_add(d, interval n unit) / adddate(d, n)
+func convertDateAdd(args []Expr) Expr {
+	return convertDateArith(args, false)
+}
+
+// date_sub(d, interval n unit) / subdate(d, n)
+func convertDateSub(args []Expr) Expr {
+	return convertDateArith(args, true)
+}
+
+func convertDateArith(args []Expr, sub bool) Expr {
+	if len(args) != 2 {
+		return nil
+	}
+	if interval, ok := args[1].(*IntervalExpr); ok {
+		return convertIntervalArith(args[0], interval, sub)
+	}
+	// adddate(d, n)中的n表示天数，Oracle中日期可以直接加减天数
+	if sub {
+		return &BinaryExpr{Operator: MinusStr, Left: args[0], Right: args[1]}
+	}
+	return &BinaryExpr{Operator: PlusStr, Left: args[0], Right: args[1]}
+}
+
+// d + interval n unit
+func isIntervalArith(node *BinaryExpr) bool {
+	if node.Operator != PlusStr && node.Operator != MinusStr {
+		return false
+	}
+	_, ok := node.Right.(*IntervalExpr)
+	return ok
+}
+
+// convertIntervalArith 日期加减间隔：年月使用add_months避免月末日期报错，其它单位使用numtodsinterval
+func convertIntervalArith(date Expr, interval *IntervalExpr, sub bool) Expr {
+	op := "+"
+	sign := ""
+	if sub {
+		op = "-"
+		sign = "-"
+	}
+	switch strings.ToLower(interval.Unit) {
+	case "second", "minute", "hour", "day":
+		return newTemplateExpr("%v "+op+" numtodsinterval(%v, '"+strings.ToLower(interval.Unit)+"')", date, interval.Expr)
+	case "week":
+		return newTemplateExpr("%v "+op+" numtodsinterval((%v) * 7, 'day')", date, interval.Expr)
+	case "microsecond":
+		return newTemplateExpr("%v "+op+" numtodsinterval((%v) / 1000000, 'second')", date, interval.Expr)
+	case "month":
+		return newTemplateExpr("add_months(%v, "+sign+"(%v))", date, interval.Expr)
+	case "quarter":
+		return newTemplateExpr("add_months(%v, "+sign+"(%v) * 3)", date, interval.Expr)
+	case "year":
+		return newTemplateExpr("add_months(%v, "+sign+"(%v) * 12)", date, interval.Expr)
+	default:
+		return nil
+	}
+}
+
+// concat(a, b, c) -> concat(concat(a, b), c), Oracle的concat只支持两个参数
+func convertConcat(args []Expr) Expr {
+	if len(args) <= 2 {
+		return nil
+	}
+	return concatExprs(args)
+}
+
+func concatExprs(args []Expr) Expr {
+	expr := args[0]
+	for _, arg := range args[1:] {
+		expr = newFuncExpr("concat", expr, arg)
+	}
+	return expr
+}
+
+// substring_index(s, delim, n)，n只支持整数常量：
+// n > 0: case when instr(s, delim, 1, n) = 0 then s else substr(s, 1, instr(s, delim, 1, n) - 1) end
+// n < 0: case when instr(s, delim, -1, -n) = 0 then s else substr(s, instr(s, delim, -1, -n) + length(delim)) end
+// 字符串和分隔符会在结果中出现多次，含有绑定参数时不转换
+func convertSubstringIndex(args []Expr) Expr {
+	if len(args) != 3 || hasValArg(args[0]) || hasValArg(args[1]) {
+		return nil
+	}
+	val, ok := args[2].(*SQLVal)
+	if !ok || val.Type != IntVal {
+		return nil
+	}
+	n, err := strconv.Atoi(string(val.Val))
+	if err != nil {
+		return nil
+	}
+	s, delim := args[0], args[1]
+	switch {
+	case n > 0:
+		count := NewIntVal([]byte(strconv.Itoa(n)))
+		return newTemplateExpr("case when instr(%v, %v, 1, %v) = 0 then %v else substr(%v, 1, instr(%v, %v, 1, %v) - 1) end",
+			s, delim, count, s, s, s, delim, count)
+	case n < 0:
+		count := NewIntVal([]byte(strconv.Itoa(-n)))
+		return newTemplateExpr("case when instr(%v, %v, -1, %v) = 0 then %v else substr(%v, instr(%v, %v, -1, %v) + length(%v)) end",
+			s, delim, count, s, s, s, delim, count, delim)
+	default:
+		return NewStrVal([]byte{})
+	}
+}
+
+// group_concat -> listagg(expr, sep) within group (order by ...)，
+// Oracle的listagg不支持distinct，distinct时使用wm_concat
+func convertGroupConcat(node *GroupConcatExpr) Expr {
+	args := make([]Expr, 0, len(node.Exprs))
+	for _, expr := range node.Exprs {
+		aliased, ok := expr.(*AliasedExpr)
+		if !ok {
+			return nil
+		}
+		args = append(args, aliased.Expr)
+	}
+	if len(args) == 0 {
+		return nil
+	}
+	value := concatExprs(args)
+
+	separator := getGroupConcatSeparator(node.Separator)
+	if node.Distinct != "" {
+		if len(node.OrderBy) > 0 || hasValArg(value) {
+			return nil
+		}
+		if separator == "," {
+			return newTemplateExpr("wm_concat(distinct %v)", value)
+		}
+		return newTemplateExpr("replace(wm_concat(distinct %v), ',', %v)", value, NewStrVal([]byte(separator)))
+	}
+
+	template := "listagg(%v, %v) within group (order by "
+	exprs := []Expr{value, NewStrVal([]byte(separator))}
+	if len(node.OrderBy) == 0 {
+		template += "null"
+	}
+	for i, order := range node.OrderBy {
+		if i > 0 {
+			template += ", "
+		}
+		template += "%v " + order.Direction
+		exprs = append(exprs, order.Expr)
+	}
+	template += ")"
+	return newTemplateExpr(template, exprs...)
+}
+
+// 解析出的separator形如 " separator ';'"，没有指定时MySQL默认使用逗号
+func getGroupConcatSeparator(separator string) string {
+	separator = strings.TrimSpace(separator)
+	if separator == "" {
+		return ","
+	}
+	separator = strings.TrimSpace(strings.TrimPrefix(separator, "separator"))
+	if len(separator) >= 2 && separator[0] == '\'' && separator[len(separator)-1] == '\'' {
+		separator = separator[1 : len(separator)-1]
+	}
+	return separator
+}
+
+func hasValArg(node SQLNode) bool {
+	found := false
+	visit := func(node SQLNode) (kcontinue bool, err error) {
+		if val, ok := node.(*SQLVal); ok && val.Type == ValArg {
+			found = true
+		}
+		return !found, nil
+	}
+	_ = Walk(visit, node)
+	return found
+}
+
+// replaceExprInStmt 在语句中把表达式from替换为to，
+// 从所有直接持有表达式的节点开始查找，Expr节点内部的查找由各自的replace方法完成
+func replaceExprInStmt(stmt SQLNode, from, to Expr) {
+	replaced := false
+	visit := func(node SQLNode) (kcontinue bool, err error) {
+		if replaced {
+			return false, nil
+		}
+		switch n := node.(type) {
+		case *AliasedExpr:
+			replaced = replaceExprs(from, to, &n.Expr)
+		case *Where:
+			if n != nil {
+				replaced = replaceExprs(from, to, &n.Expr)
+			}
+		case *UpdateExpr:
+			replaced = replaceExprs(from, to, &n.Expr)
+		case *Order:
+			replaced = replaceExprs(from, to, &n.Expr)
+		case *Limit:
+			if n != nil {
+				replaced = replaceExprs(from, to, &n.Offset, &n.Rowcount)
+			}
+		case *JoinTableExpr:
+			replaced = replaceExprs(from, to, &n.Condition.On)
+		case GroupBy:
+			replaced = replaceExprList(from, to, n)
+		case Exprs:
+			replaced = replaceExprList(from, to, n)
+		case ValTuple:
+			replaced = replaceExprList(from, to, n)
+		}
+		return !replaced, nil
+	}
+	_ = Walk(visit, stmt)
+}
+
+func replaceExprList(from, to Expr, exprs []Expr) bool {
+	for i := range exprs {
+		if replaceExprs(from, to, &exprs[i]) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,253 @@
+package sqlparser
+
+import (
+	"fmt"
+
+	"sqlproxy/core/errors"
+)
+
+// joinTables 多表update/delete中展开后的表及连接条件
+type joinTables struct {
+	tables []*AliasedTableExpr
+	conds  []Expr
+}
+
+// convertUpdateJoin 将MySQL的多表update转换为Oracle支持的关联子查询：
+// update a join b on a.id = b.aid set a.x = b.y, a.z = 1 where b.k = 2
+// ->
+// update a set a.x = (select b.y from b where a.id = b.aid and b.k = 2), a.z = 1
+// where exists (select 1 from b where a.id = b.aid and b.k = 2)
+func (this *OracleConverter) convertUpdateJoin(stmt *Update) (*Update, error) {
+	if !isMultiTable(stmt.TableExprs) {
+		return stmt, nil
+	}
+	joins, err := flattenJoinTables(stmt.TableExprs)
+	if err != nil {
+		return nil, err
+	}
+
+	var target *AliasedTableExpr
+	for _, expr := range stmt.Exprs {
+		t := this.findTargetTable(joins.tables, expr.Name)
+		if target != nil && t != target {
+			return nil, fmt.Errorf("%w: update more than one table in a statement", errors.ErrStmtConvert)
+		}
+		target = t
+	}
+	if target == nil {
+		return nil, fmt.Errorf("%w: can not find the table to update", errors.ErrStmtConvert)
+	}
+	others := joins.others(target)
+	if stmt.Where != nil {
+		joins.conds = append(joins.conds, stmt.Where.Expr)
+	}
+
+	for _, expr := range stmt.Exprs {
+		if !referenceTables(expr.Expr, others) {
+			continue
+		}
+		sel, err := buildCorrelatedSelect(&AliasedExpr{Expr: expr.Expr}, others, joins.conds)
+		if err != nil {
+			return nil, err
+		}
+		expr.Expr = &Subquery{Select: sel}
+	}
+	exists, err := buildCorrelatedSelect(&AliasedExpr{Expr: NewIntVal([]byte("1"))}, others, joins.conds)
+	if err != nil {
+		return nil, err
+	}
+
+	stmt.TableExprs = TableExprs{target}
+	stmt.Where = NewWhere(WhereStr, &ExistsExpr{Subquery: &Subquery{Select: exists}})
+	return stmt, nil
+}
+
+// convertDeleteJoin 将MySQL的多表delete转换为exists子查询：
+// delete a from a join b on a.id = b.aid where b.k = 2
+// ->
+// delete from a where exists (select 1 from b where a.id = b.aid and b.k = 2)
+func (this *OracleConverter) convertDeleteJoin(stmt *Delete) (*Delete, error) {
+	if !isMultiTable(stmt.TableExprs) {
+		// Oracle不支持delete t from t的写法
+		stmt.Targets = nil
+		return stmt, nil
+	}
+	if len(stmt.Targets) != 1 {
+		return nil, fmt.Errorf("%w: delete from more than one table in a statement", errors.ErrStmtConvert)
+	}
+	joins, err := flattenJoinTables(stmt.TableExprs)
+	if err != nil {
+		return nil, err
+	}
+	target := joins.find(stmt.Targets[0].Name)
+	if target == nil {
+		return nil, fmt.Errorf("%w: can not find the table to delete", errors.ErrStmtConvert)
+	}
+	others := joins.others(target)
+	if stmt.Where != nil {
+		joins.conds = append(joins.conds, stmt.Where.Expr)
+	}
+	exists, err := buildCorrelatedSelect(&AliasedExpr{Expr: NewIntVal([]byte("1"))}, others, joins.conds)
+	if err != nil {
+		return nil, err
+	}
+
+	stmt.Targets = nil
+	stmt.TableExprs = TableExprs{target}
+	stmt.Where = NewWhere(WhereStr, &ExistsExpr{Subquery: &Subquery{Select: exists}})
+	return stmt, nil
+}
+
+func isMultiTable(exprs TableExprs) bool {
+	if len(exprs) != 1 {
+		return len(exprs) > 1
+	}
+	_, ok := exprs[0].(*AliasedTableExpr)
+	return !ok
+}
+
+// flattenJoinTables 展开逗号和inner join连接的表，外连接等无法改写为关联子查询的形式返回错误
+func flattenJoinTables(exprs TableExprs) (*joinTables, error) {
+	joins := &joinTables{}
+	var flatten func(expr TableExpr) error
+	flatten = func(expr TableExpr) error {
+		switch n := expr.(type) {
+		case *AliasedTableExpr:
+			joins.tables = append(joins.tables, n)
+		case *ParenTableExpr:
+			for _, e := range n.Exprs {
+				if err := flatten(e); err != nil {
+					return err
+				}
+			}
+		case *JoinTableExpr:
+			if n.Join != JoinStr && n.Join != StraightJoinStr {
+				return fmt.Errorf("%w: %s in multi-table update or delete", errors.ErrStmtConvert, n.Join)
+			}
+			if len(n.Condition.Using) > 0 {
+				return fmt.Errorf("%w: join using in multi-table update or delete", errors.ErrStmtConvert)
+			}
+			if err := flatten(n.LeftExpr); err != nil {
+				return err
+			}
+			if err := flatten(n.RightExpr); err != nil {
+				return err
+			}
+			if n.Condition.On != nil {
+				joins.conds = append(joins.conds, n.Condition.On)
+			}
+		default:
+			return fmt.Errorf("%w: unsupported table expression %s", errors.ErrStmtConvert, String(expr))
+		}
+		return nil
+	}
+	for _, expr := range exprs {
+		if err := flatten(expr); err != nil {
+			return nil, err
+		}
+	}
+	return joins, nil
+}
+
+// find 按别名或表名查找表
+func (this *joinTables) find(name TableIdent) *AliasedTableExpr {
+	for _, t := range this.tables {
+		if tableIdentEqual(t, name) {
+			return t
+		}
+	}
+	return nil
+}
+
+func (this *joinTables) others(target *AliasedTableExpr) TableExprs {
+	others := TableExprs{}
+	for _, t := range this.tables {
+		if t != target {
+			others = append(others, t)
+		}
+	}
+	return others
+}
+
+func tableIdentEqual(t *AliasedTableExpr, name TableIdent) bool {
+	if !t.As.IsEmpty() {
+		return t.As.String() == name.String()
+	}
+	if tableName, ok := t.Expr.(TableName); ok {
+		return tableName.Name.String() == name.String()
+	}
+	return false
+}
+
+// findTargetTable 查找被更新列所属的表，没有限定表名时根据表的列信息查找，找不到时与MySQL习惯一致使用第一个表
+func (this *OracleConverter) findTargetTable(tables []*AliasedTableExpr, col *ColName) *AliasedTableExpr {
+	if !col.Qualifier.IsEmpty() {
+		for _, t := range tables {
+			if tableIdentEqual(t, col.Qualifier.Name) {
+				return t
+			}
+		}
+		return nil
+	}
+	for _, t := range tables {
+		if tableName, ok := t.Expr.(TableName); ok {
+			for _, column := range this.tableColumns[this.options.MetadataKey(tableName.Name.String())] {
+				if col.Name.EqualString(column) {
+					return t
+				}
+			}
+		}
+	}
+	return tables[0]
+}
+
+// referenceTables 表达式中是否引用了tables中的列
+func referenceTables(expr Expr, tables TableExprs) bool {
+	found := false
+	visit := func(node SQLNode) (kcontinue bool, err error) {
+		if col, ok := node.(*ColName); ok && !col.Qualifier.IsEmpty() {
+			for _, t := range tables {
+				if tableIdentEqual(t.(*AliasedTableExpr), col.Qualifier.Name) {
+					found = true
+				}
+			}
+		}
+		return !found, nil
+	}
+	_ = Walk(visit, expr)
+	return found
+}
+
+// buildCorrelatedSelect 构建select expr from tables where conds的关联子查询，
+// 条件在多个子查询中重复出现，需要复制一份使其中的绑定参数能分别编号
+func buildCorrelatedSelect(expr SelectExpr, tables TableExprs, conds []Expr) (*Select, error) {
+	var where Expr
+	for _, cond := range conds {
+		c, err := cloneExpr(cond)
+		if err != nil {
+			return nil, err
+		}
+		if where == nil {
+			where = c
+		} else {
+			where = &AndExpr{Left: where, Right: c}
+		}
+	}
+	sel := &Select{
+		SelectExprs: SelectExprs{expr},
+		From:        tables,
+	}
+	if where != nil {
+		sel.Where = NewWhere(WhereStr, where)
+	}
+	return sel, nil
+}
+
+// cloneExpr 通过重新解析复制表达式，用于在转换函数等Oracle语法之前复制MySQL的表达式
+func cloneExpr(expr Expr) (Expr, error) {
+	stmt, err := Parse("select 1 from dual where " + String(&ParenExpr{Expr: expr}))
+	if err != nil {
+		return nil, err
+	}
+	return stmt.(*Select).Where.Expr, nil
+}
@@ -0,0 +1,160 @@
+package sqlparser
+
+import (
+	"fmt"
+
+	"sqlproxy/core/errors"
+)
+
+// limit m, n  ->  offset m rows fetch next n rows only
+func formatOracleLimit(buf *TrackedBuffer, node *Limit) {
+	if node == nil {
+		return
+	}
+	if node.Offset == nil {
+		buf.Myprintf(" fetch first %v rows only", node.Rowcount)
+		return
+	}
+	buf.Myprintf(" offset %v rows fetch next %v rows only", node.Offset, node.Rowcount)
+}
+
+// convertLimit 转换语句中的limit子句:
+// 1. update/delete中的limit n转换为where条件rownum <= n，rownum在排序之前生成，
+// 同时有order by时无法保证更新/删除的是排序后的前n行，不支持转换；
+// 2. select ... for update中Oracle不允许使用fetch，没有order by和offset时转换为rownum条件，否则不支持转换；
+// 3. 查询语句默认在输出时转换为offset ... fetch语法（见formatNode），
+// 配置了rownum分页时，带limit的查询（包括子查询和union）被包装为基于rownum的子查询。
+func (this *OracleConverter) convertLimit(stmt Statement) (Statement, error) {
+	switch s := stmt.(type) {
+	case *Update:
+		if s.Limit != nil && len(s.OrderBy) > 0 {
+			return nil, fmt.Errorf("%w: update with order by and limit", errors.ErrStmtConvert)
+		}
+		s.Where = addRownumCondition(s.Where, s.Limit)
+		s.Limit, s.OrderBy = nil, nil
+		return s, nil
+	case *Delete:
+		if s.Limit != nil && len(s.OrderBy) > 0 {
+			return nil, fmt.Errorf("%w: delete with order by and limit", errors.ErrStmtConvert)
+		}
+		s.Where = addRownumCondition(s.Where, s.Limit)
+		s.Limit, s.OrderBy = nil, nil
+		return s, nil
+	case *Select:
+		if s.Lock != "" && s.Limit != nil {
+			if s.Limit.Offset != nil || len(s.OrderBy) > 0 {
+				return nil, fmt.Errorf("%w: select%s with order by or offset in limit", errors.ErrStmtConvert, s.Lock)
+			}
+			s.Where = addRownumCondition(s.Where, s.Limit)
+			s.Limit = nil
+		}
+	}
+	if this.options.Pagination != PAGINATION_ROWNUM {
+		return stmt, nil
+	}
+
+	visit := func(node SQLNode) (kcontinue bool, err error) {
+		switch n := node.(type) {
+		case *Subquery:
+			n.Select = wrapRownumSelect(n.Select)
+		case *ParenSelect:
+			n.Select = wrapRownumSelect(n.Select)
+		case *Insert:
+			if sel, ok := n.Rows.(SelectStatement); ok {
+				n.Rows = wrapRownumSelect(sel)
+			}
+		}
+		return true, nil
+	}
+	_ = Walk(visit, stmt)
+
+	if sel, ok := stmt.(SelectStatement); ok {
+		return wrapRownumSelect(sel), nil
+	}
+	return stmt, nil
+}
+
+func addRownumCondition(where *Where, limit *Limit) *Where {
+	if limit == nil {
+		return where
+	}
+	cond := &ComparisonExpr{
+		Operator: LessEqualStr,
+		Left:     &RownumExpr{},
+		Right:    limit.Rowcount,
+	}
+	if where == nil || where.Expr == nil {
+		return NewWhere(WhereStr, cond)
+	}
+	where.Expr = &AndExpr{Left: &ParenExpr{Expr: where.Expr}, Right: cond}
+	return where
+}
+
+func wrapRownumSelect(sel SelectStatement) SelectStatement {
+	var limit *Limit
+	var columns Columns
+	switch s := sel.(type) {
+	case *Select:
+		limit, s.Limit = s.Limit, nil
+		columns = getSelectColumns(s)
+	case *Union:
+		limit, s.Limit = s.Limit, nil
+		columns = getSelectColumns(firstSelect(s))
+	}
+	if limit == nil {
+		return sel
+	}
+	return &RownumSelect{
+		Columns:  columns,
+		Select:   sel,
+		Offset:   limit.Offset,
+		Rowcount: limit.Rowcount,
+		Lower:    copyValArg(limit.Offset),
+	}
+}
+
+// getSelectColumns 获取查询输出的列名，用于rownum分页时去掉外层多出的"rn_"列，
+// 有*或者无法确定列名的表达式时返回nil，外层使用*输出
+func getSelectColumns(sel *Select) Columns {
+	if sel == nil {
+		return nil
+	}
+	columns := make(Columns, 0, len(sel.SelectExprs))
+	for _, expr := range sel.SelectExprs {
+		aliased, ok := expr.(*AliasedExpr)
+		if !ok {
+			return nil
+		}
+		if !aliased.As.IsEmpty() {
+			columns = append(columns, aliased.As)
+			continue
+		}
+		col, ok := aliased.Expr.(*ColName)
+		if !ok {
+			return nil
+		}
+		columns = append(columns, col.Name)
+	}
+	return columns
+}
+
+func firstSelect(sel SelectStatement) *Select {
+	switch s := sel.(type) {
+	case *Select:
+		return s
+	case *Union:
+		return firstSelect(s.Left)
+	case *ParenSelect:
+		return firstSelect(s.Select)
+	default:
+		return nil
+	}
+}
+
+// copyValArg 复制绑定参数节点，使同一个参数在语句中出现两次时可以分别编号
+func copyValArg(expr Expr) Expr {
+	if val, ok := expr.(*SQLVal); ok {
+		return &SQLVal{Type: val.Type, Val: val.Val}
+	}
+	return expr
+}
@@ -1,8 +1,10 @@
 package sqlparser
 
 import (
+	"errors"
 	"fmt"
 	"log"
+	coreerrors "sqlproxy/core/errors"
 	"testing"
 
 	"github.com/stretchr/testify/assert"
@@ -132,3 +134,585 @@ func TestConvertArgs(t *testing.T) {
 	t.Logf("formatSQL: %s", formatSQL)
 
 }
+
+func TestConvertLimit(t *testing.T) {
+	testCases := []struct {
+		pagination string
+		in, out    string
+		err        bool
+		args       []interface{}
+		outArgs    []interface{}
+	}{
+		{
+			in:  "select a, b from t1 where c = 1 order by a limit 10",
+			out: `select "a", "b" from "t1" where "c" = 1 order by "a" asc fetch first 10 rows only`,
+		},
+		{
+			in:  "select * from t1 limit 5, 10",
+			out: `select * from "t1" offset 5 rows fetch next 10 rows only`,
+		},
+		{
+			in:      "select * from t1 where c = ? limit ? offset ?",
+			out:     `select * from "t1" where "c" = :v1 offset :v2 rows fetch next :v3 rows only`,
+			args:    []interface{}{1, 10, 20},
+			outArgs: []interface{}{1, 20, 10},
+		},
+		{
+			in:  "select a from t1 where b in (select b from t2 limit 3) union select a from t3 limit 1",
+			out: `select "a" from "t1" where "b" in (select "b" from "t2" fetch first 3 rows only) union select "a" from "t3" fetch first 1 rows only`,
+		},
+		{
+			pagination: PAGINATION_ROWNUM,
+			in:         "select a, b as c from t1 order by a limit 10",
+			out:        `select "a", "c" from (select "a", "b" as "c" from "t1" order by "a" asc) where rownum <= 10`,
+		},
+		{
+			pagination: PAGINATION_ROWNUM,
+			in:         "select * from t1 where c = ? limit ?, ?",
+			out:        `select * from (select "t_".*, rownum as "rn_" from (select * from "t1" where "c" = :v1) "t_" where rownum <= :v2 + :v3) where "rn_" > :v4`,
+			args:       []interface{}{1, 20, 10},
+			outArgs:    []interface{}{1, 20, 10, 20},
+		},
+		{
+			pagination: PAGINATION_ROWNUM,
+			in:         "select a from t1 where b in (select b from t2 limit 3)",
+			out:        `select "a" from "t1" where "b" in (select "b" from (select "b" from "t2") where rownum <= 3)`,
+		},
+		{
+			pagination: PAGINATION_ROWNUM,
+			in:         "select a from t1 union all select a from t2 limit 5",
+			out:        `select "a" from (select "a" from "t1" union all select "a" from "t2") where rownum <= 5`,
+		},
+		{
+			in:      "update t1 set a = ? where b = ? limit ?",
+			out:     `update "t1" set "a" = :v1 where ("b" = :v2) and rownum <= :v3`,
+			args:    []interface{}{1, 2, 3},
+			outArgs: []interface{}{1, 2, 3},
+		},
+		{
+			in:  "delete from t1 limit 100",
+			out: `delete from "t1" where rownum <= 100`,
+		},
+		{
+			in:      "select a from t1 where b = ? limit ? for update",
+			out:     `select "a" from "t1" where ("b" = :v1) and rownum <= :v2 for update`,
+			args:    []interface{}{1, 10},
+			outArgs: []interface{}{1, 10},
+		},
+		{
+			in:  "select a from t1 order by a limit 10 for update",
+			err: true,
+		},
+		{
+			in:  "delete from t1 where a > 1 order by id limit 10",
+			err: true,
+		},
+		{
+			in:  "update t1 set a = 1 order by id limit 10",
+			err: true,
+		},
+	}
+
+	for i, tcase := range testCases {
+		t.Run(fmt.Sprintf("testcase-%d", i+1), func(t *testing.T) {
+			converter := GetSQLConverter(MYSQL_TO_ORACLE, nil, nil, nil, ConvertOptions{Pagination: tcase.pagination})
+			oSql, newArgs, err := converter.Convert(tcase.in, tcase.args...)
+			if tcase.err {
+				assert.True(t, errors.Is(err, coreerrors.ErrStmtConvert))
+				return
+			}
+			assert.Nil(t, err)
+			assert.Equal(t, tcase.out, oSql)
+			assert.Equal(t, tcase.outArgs, newArgs)
+		})
+	}
+}
+
+func TestConvertFuncs(t *testing.T) {
+	testCases := []struct {
+		in, out string
+		args    []interface{}
+		outArgs []interface{}
+	}{
+		{
+			in:  "select ifnull(a, 0), if(a > 1, 'x', 'y'), if(b, 1, 2), now(), curdate() from t1",
+			out: `select nvl("a", 0), case when "a" > 1 then 'x' else 'y' end, case when "b" != 0 then 1 else 2 end, sysdate, trunc(sysdate) from "t1"`,
+		},
+		{
+			in:  "select unix_timestamp(), from_unixtime(ts) from t1",
+			out: `select round((cast(sys_extract_utc(systimestamp) as date) - to_date('1970-01-01', 'yyyy-mm-dd')) * 86400), cast(from_tz(cast(to_date('1970-01-01', 'yyyy-mm-dd') + numtodsinterval("ts", 'second') as timestamp), 'UTC') at time zone sessiontimezone as date) from "t1"`,
+		},
+		{
+			in:  "select date_format(d, '%Y-%m-%d %H:%i:%s'), date_format(d, '%Y年%c月') from t1",
+			out: `select to_char("d", 'yyyy-mm-dd hh24:mi:ss'), to_char("d", 'yyyy"年"fmmmfm"月"') from "t1"`,
+		},
+		{
+			in:  "select date_format(d, '%W %M %b %a %p'), date_format(d, '%r') from t1",
+			out: `select to_char("d", 'fmDayfm fmMonthfm Mon Dy AM'), to_char("d", 'hh12:mi:ss AM') from "t1"`,
+		},
+		{
+			in:      "select * from t1 where d > date_sub(now(), interval ? day) and e < date_add(d, interval 2 month) and f > d - interval 1 hour and a = ?",
+			out:     `select * from "t1" where "d" > sysdate - numtodsinterval(:v1, 'day') and "e" < add_months("d", (2)) and "f" > "d" - numtodsinterval(1, 'hour') and "a" = :v2`,
+			args:    []interface{}{7, 1},
+			outArgs: []interface{}{7, 1},
+		},
+		{
+			in:  "select concat(a, '-', b), concat(a, b), substring_index(a, ',', 2), substring_index(a, ',', -1) from t1",
+			out: `select concat(concat("a", '-'), "b"), concat("a", "b"), case when instr("a", ',', 1, 2) = 0 then "a" else substr("a", 1, instr("a", ',', 1, 2) - 1) end, case when instr("a", ',', -1, 1) = 0 then "a" else substr("a", instr("a", ',', -1, 1) + length(',')) end from "t1"`,
+		},
+		{
+			in:  "select b, group_concat(a order by c desc separator ';'), group_concat(a), group_concat(distinct a) from t1 group by b",
+			out: `select "b", listagg("a", ';') within group (order by "c" desc), listagg("a", ',') within group (order by null), wm_concat(distinct "a") from "t1" group by "b"`,
+		},
+		{
+			in:      "update t1 set a = ifnull(?, 0), d = now() where id = ?",
+			out:     `update "t1" set "a" = nvl(:v1, 0), "d" = sysdate where "id" = :v2`,
+			args:    []interface{}{1, 2},
+			outArgs: []interface{}{1, 2},
+		},
+	}
+
+	converter := NewOracleConverter(nil, nil, nil)
+	for i, tcase := range testCases {
+		t.Run(fmt.Sprintf("testcase-%d", i+1), func(t *testing.T) {
+			oSql, newArgs, err := converter.Convert(tcase.in, tcase.args...)
+			assert.Nil(t, err)
+			assert.Equal(t, tcase.out, oSql)
+			assert.Equal(t, tcase.outArgs, newArgs)
+		})
+	}
+}
+
+func TestConvertFormat(t *testing.T) {
+	testCases := []struct {
+		upper   bool
+		in, out string
+	}{
+		{
+			in:  "select `a`, b from t1 where c = 'x`y' and d = 'it\\'s' and e = '0000-00-00 00:00:00' and f like '%0000-00-00 00:00:00%'",
+			out: `select "a", "b" from "t1" where "c" = 'x` + "`" + `y' and "d" = 'it''s' and "e" = '0001-01-01 00:00:00' and "f" like '%0000-00-00 00:00:00%'`,
+		},
+		{
+			in:  "select t.a from t1 as t join t2 u on t.id = u.id where t.b = 'c:\\\\temp\\n'",
+			out: "select \"t\".\"a\" from \"t1\" \"t\" join \"t2\" \"u\" on \"t\".\"id\" = \"u\".\"id\" where \"t\".\"b\" = 'c:\\temp\n'",
+		},
+		{
+			upper: true,
+			in:    "update t1 set nameId = 1, `lastName` = 'abc' where id = 2",
+			out:   `update "T1" set "NAMEID" = 1, "LASTNAME" = 'abc' where "ID" = 2`,
+		},
+		{
+			upper: true,
+			in:    "select 1 from dual",
+			out:   `select 1 from dual`,
+		},
+		{
+			in:  `select a from t1 where b like 'x\_y%' and c not like 'a\\q\\\\c\\' and d like 'e_f' and e = 'x\_y'`,
+			out: `select "a" from "t1" where "b" like 'x\_y%' escape '\' and "c" not like 'aq\\c\\' escape '\' and "d" like 'e_f' and "e" = 'x\_y'`,
+		},
+		{
+			in:  "set names utf8mb4",
+			out: "set names utf8mb4",
+		},
+	}
+
+	for i, tcase := range testCases {
+		t.Run(fmt.Sprintf("testcase-%d", i+1), func(t *testing.T) {
+			converter := GetSQLConverter(MYSQL_TO_ORACLE, nil, nil, nil, ConvertOptions{UpperCaseIdent: tcase.upper})
+			oSql, _, err := converter.Convert(tcase.in)
+			assert.Nil(t, err)
+			assert.Equal(t, tcase.out, oSql)
+		})
+	}
+}
+
+func TestConvertInsertIgnore(t *testing.T) {
+	testCases := []struct {
+		in, out string
+		args    []interface{}
+		err     bool
+	}{
+		{
+			in:   "insert ignore into t1(id, a, b, c) values (?, ?, ?, ?)",
+			out:  `merge into "t1" "t" using (select :v1 as "id", :v2 as "a", :v3 as "b", :v4 as "c" from dual) "s" on ("t"."id" = "s"."id" or "t"."a" = "s"."a" and "t"."b" = "s"."b") when not matched then insert ("id", "a", "b", "c") values ("s"."id", "s"."a", "s"."b", "s"."c")`,
+			args: []interface{}{1, "x", "y", "z"},
+		},
+		{
+			in:  "insert ignore into t1(id, c) values (1, 2) on duplicate key update c = 3",
+			out: `merge into "t1" "t" using (select 1 as "id", 2 as "c" from dual) "s" on ("t"."id" = "s"."id") when matched then update set "t"."c" = 3 when not matched then insert ("id", "c") values ("s"."id", "s"."c")`,
+		},
+		{
+			in:  "insert ignore into t2(id) values (1)",
+			err: true,
+		},
+		{
+			in:   "insert ignore into t1(id, a, b) values (?, ?, ?), (?, ?, ?)",
+			out:  `merge into "t1" "t" using (select "id", "a", "b" from (select "d_".*, row_number() over (partition by "id" order by "rn_") as "rn1_", row_number() over (partition by "a", "b" order by "rn_") as "rn2_" from (select "s_".*, rownum as "rn_" from (select :v1 as "id", :v2 as "a", :v3 as "b" from dual union all select :v4, :v5, :v6 from dual) "s_") "d_") where "rn1_" = 1 and "rn2_" = 1) "s" on ("t"."id" = "s"."id" or "t"."a" = "s"."a" and "t"."b" = "s"."b") when not matched then insert ("id", "a", "b") values ("s"."id", "s"."a", "s"."b")`,
+			args: []interface{}{1, "x", "y", 1, "x", "z"},
+		},
+	}
+
+	converter := NewOracleConverter(map[string]map[string][]string{
+		"t1": {
+			"PRIMARY": {"id"},
+			"uk_a_b":  {"a", "b"},
+		},
+	}, nil, nil)
+	for i, tcase := range testCases {
+		t.Run(fmt.Sprintf("testcase-%d", i+1), func(t *testing.T) {
+			oSql, newArgs, err := converter.Convert(tcase.in, tcase.args...)
+			if tcase.err {
+				assert.NotNil(t, err)
+				return
+			}
+			assert.Nil(t, err)
+			assert.Equal(t, tcase.out, oSql)
+			assert.Equal(t, tcase.args, newArgs)
+		})
+	}
+}
+
+func TestConvertMergeSource(t *testing.T) {
+	testCases := []struct {
+		in, out string
+		err     bool
+		args    []interface{}
+		outArgs []interface{}
+	}{
+		{
+			in:  "insert into t1(id, a, c) values (1, 'x', 2), (2, 'y', 3) on duplicate key update c = 4",
+			out: `merge into "t1" "t" using (select 1 as "id", 'x' as "a", 2 as "c" from dual union all select 2, 'y', 3 from dual) "s" on ("t"."id" = "s"."id") when matched then update set "t"."c" = 4 when not matched then insert ("id", "a", "c") values ("s"."id", "s"."a", "s"."c")`,
+		},
+		{
+			in:      "replace into t1(id, a) values (?, ?), (?, ?)",
+			out:     `merge into "t1" "t" using (select "id", "a" from (select "d_".*, row_number() over (partition by "id" order by "rn_" desc) as "rn1_" from (select "s_".*, rownum as "rn_" from (select :v1 as "id", :v2 as "a" from dual union all select :v3, :v4 from dual) "s_") "d_") where "rn1_" = 1) "s" on ("t"."id" = "s"."id") when matched then update set "t"."a" = "s"."a" when not matched then insert ("id", "a") values ("s"."id", "s"."a")`,
+			args:    []interface{}{1, "x", 2, "y"},
+			outArgs: []interface{}{1, "x", 2, "y"},
+		},
+		{
+			in:  "insert into t1(id, a, c) values (1, 'x', 2), (1, 'y', 3) on duplicate key update c = values(c)",
+			out: `merge into "t1" "t" using (select "id", "a", "c" from (select "d_".*, row_number() over (partition by "id" order by "rn_" desc) as "rn1_" from (select "s_".*, rownum as "rn_" from (select 1 as "id", 'x' as "a", 2 as "c" from dual union all select 1, 'y', 3 from dual) "s_") "d_") where "rn1_" = 1) "s" on ("t"."id" = "s"."id") when matched then update set "t"."c" = "s"."c" when not matched then insert ("id", "a", "c") values ("s"."id", "s"."a", "s"."c")`,
+		},
+		{
+			in:  "replace into t1(id, a) values (1, 'x'), (1, 'y')",
+			out: `merge into "t1" "t" using (select "id", "a" from (select "d_".*, row_number() over (partition by "id" order by "rn_" desc) as "rn1_" from (select "s_".*, rownum as "rn_" from (select 1 as "id", 'x' as "a" from dual union all select 1, 'y' from dual) "s_") "d_") where "rn1_" = 1) "s" on ("t"."id" = "s"."id") when matched then update set "t"."a" = "s"."a" when not matched then insert ("id", "a") values ("s"."id", "s"."a")`,
+		},
+		{
+			in:  "insert into t1 values (1, 'x', 2) on duplicate key update c = 4",
+			out: `merge into "t1" "t" using (select 1 as "id", 'x' as "a", 2 as "c" from dual) "s" on ("t"."id" = "s"."id") when matched then update set "t"."c" = 4 when not matched then insert ("id", "a", "c") values ("s"."id", "s"."a", "s"."c")`,
+		},
+		{
+			in:  "insert into t1(id, a, c) select id, name, cnt from t2 where cnt > 0 on duplicate key update c = 1",
+			out: `merge into "t1" "t" using (select "id", "a", "c" from (select "d_".*, row_number() over (partition by "id" order by "rn_" desc) as "rn1_" from (select "s_".*, rownum as "rn_" from (select "id" as "id", "name" as "a", "cnt" as "c" from "t2" where "cnt" > 0) "s_") "d_") where "rn1_" = 1) "s" on ("t"."id" = "s"."id") when matched then update set "t"."c" = 1 when not matched then insert ("id", "a", "c") values ("s"."id", "s"."a", "s"."c")`,
+		},
+		{
+			in:  "insert into t1(id, a, c) select * from t2 on duplicate key update c = 1",
+			out: `merge into "t1" "t" using (select "id", "a", "c" from (select "d_".*, row_number() over (partition by "id" order by "rn_" desc) as "rn1_" from (select "s_".*, rownum as "rn_" from (select "t2"."id" as "id", "t2"."name" as "a", "t2"."cnt" as "c" from "t2") "s_") "d_") where "rn1_" = 1) "s" on ("t"."id" = "s"."id") when matched then update set "t"."c" = 1 when not matched then insert ("id", "a", "c") values ("s"."id", "s"."a", "s"."c")`,
+		},
+		{
+			in:  "insert into t1(id, a, c) select x.id, y.* from t2 x join t3 y on x.id = y.id on duplicate key update c = 1",
+			out: `merge into "t1" "t" using (select "id", "a", "c" from (select "d_".*, row_number() over (partition by "id" order by "rn_" desc) as "rn1_" from (select "s_".*, rownum as "rn_" from (select "x"."id" as "id", "y"."name" as "a", "y"."cnt" as "c" from "t2" "x" join "t3" "y" on "x"."id" = "y"."id") "s_") "d_") where "rn1_" = 1) "s" on ("t"."id" = "s"."id") when matched then update set "t"."c" = 1 when not matched then insert ("id", "a", "c") values ("s"."id", "s"."a", "s"."c")`,
+		},
+		{
+			in:  "insert into t1(id, a, c) select * from t4 on duplicate key update c = 1",
+			err: true,
+		},
+		{
+			in:  "insert into t1(id, a, c) select id, name from t2 on duplicate key update c = 1",
+			err: true,
+		},
+	}
+
+	converter := NewOracleConverter(
+		map[string]map[string][]string{
+			"t1": {"PRIMARY": {"id"}},
+		},
+		map[string][]string{
+			"t1": {"id", "a", "c"},
+			"t2": {"id", "name", "cnt"},
+			"t3": {"name", "cnt"},
+		},
+		nil,
+	)
+	for i, tcase := range testCases {
+		t.Run(fmt.Sprintf("testcase-%d", i+1), func(t *testing.T) {
+			oSql, newArgs, err := converter.Convert(tcase.in, tcase.args...)
+			if tcase.err {
+				assert.True(t, errors.Is(err, coreerrors.ErrStmtConvert))
+				return
+			}
+			assert.Nil(t, err)
+			assert.Equal(t, tcase.out, oSql)
+			assert.Equal(t, tcase.outArgs, newArgs)
+		})
+	}
+}
+
+func TestConvertOnDupValues(t *testing.T) {
+	testCases := []struct {
+		in, out string
+		err     bool
+		args    []interface{}
+		outArgs []interface{}
+	}{
+		{
+			in:  "insert into counter(id, cnt) values (1, 1) on duplicate key update cnt = cnt + values(cnt), updated_at = now()",
+			out: `merge into "counter" "t" using (select 1 as "id", 1 as "cnt" from dual) "s" on ("t"."id" = "s"."id") when matched then update set "t"."cnt" = "t"."cnt" + "s"."cnt", "t"."updated_at" = sysdate when not matched then insert ("id", "cnt") values ("s"."id", "s"."cnt")`,
+		},
+		{
+			in:      "insert into counter(id, cnt) values (?, ?), (?, ?) on duplicate key update counter.cnt = ifnull(counter.cnt, 0) + values(cnt) * ?, updated_at = values(updated_at)",
+			out:     `merge into "counter" "t" using (select "id", "cnt" from (select "d_".*, row_number() over (partition by "id" order by "rn_" desc) as "rn1_" from (select "s_".*, rownum as "rn_" from (select :v1 as "id", :v2 as "cnt" from dual union all select :v3, :v4 from dual) "s_") "d_") where "rn1_" = 1) "s" on ("t"."id" = "s"."id") when matched then update set "t"."cnt" = nvl("t"."cnt", 0) + "s"."cnt" * :v5, "t"."updated_at" = default when not matched then insert ("id", "cnt") values ("s"."id", "s"."cnt")`,
+			args:    []interface{}{1, 2, 3, 4, 5},
+			outArgs: []interface{}{1, 2, 3, 4, 5},
+		},
+		{
+			in:  "insert into counter(id, cnt) values (1, 1) on duplicate key update cnt = cnt + values(total)",
+			err: true,
+		},
+	}
+
+	converter := NewOracleConverter(map[string]map[string][]string{
+		"counter": {"PRIMARY": {"id"}},
+	}, nil, nil)
+	for i, tcase := range testCases {
+		t.Run(fmt.Sprintf("testcase-%d", i+1), func(t *testing.T) {
+			oSql, newArgs, err := converter.Convert(tcase.in, tcase.args...)
+			if tcase.err {
+				assert.True(t, errors.Is(err, coreerrors.ErrStmtConvert))
+				return
+			}
+			assert.Nil(t, err)
+			assert.Equal(t, tcase.out, oSql)
+			assert.Equal(t, tcase.outArgs, newArgs)
+		})
+	}
+}
+
+func TestConvertUpperCaseMetadata(t *testing.T) {
+	testCases := []struct {
+		in, out string
+		opts    ConvertOptions
+	}{
+		{
+			in:   "insert into t1(id, a) values (1, 'x') on duplicate key update a = 'y'",
+			opts: ConvertOptions{DriverName: "oci8", UpperCaseIdent: true},
+			out:  `merge into "T1" "T" using (select 1 as "ID", 'x' as "A" from dual) "S" on ("T"."ID" = "S"."ID") when matched then update set "T"."A" = 'y' when not matched then insert ("ID", "A") values ("S"."ID", "S"."A")`,
+		},
+		{
+			in:   "replace into t1(id, a) values (1, 'x')",
+			opts: ConvertOptions{DriverName: "oci8", UpperCaseIdent: true},
+			out:  `merge into "T1" "T" using (select 1 as "ID", 'x' as "A" from dual) "S" on ("T"."ID" = "S"."ID") when matched then update set "T"."A" = "S"."A" when not matched then insert ("ID", "A") values ("S"."ID", "S"."A")`,
+		},
+		{
+			in:   "insert into t2 values (1, 'x')",
+			opts: ConvertOptions{DriverName: "oci8", UpperCaseIdent: true},
+			out:  `insert into "T2"("NAME") values ('x')`,
+		},
+		{
+			in:   "update t2 set id = 2, name = 'y' where name = 'x'",
+			opts: ConvertOptions{DriverName: "oci8", UpperCaseIdent: true},
+			out:  `update "T2" set "NAME" = 'y' where "NAME" = 'x'`,
+		},
+		{
+			in:   "replace into t1(id, a) values (1, 'x')",
+			opts: ConvertOptions{DriverName: "oci8"},
+			out:  `merge into "t1" "t" using (select 1 as "id", 'x' as "a" from dual) "s" on ("t"."id" = "s"."id") when matched then update set "t"."a" = "s"."a" when not matched then insert ("id", "a") values ("s"."id", "s"."a")`,
+		},
+	}
+
+	for i, tcase := range testCases {
+		t.Run(fmt.Sprintf("testcase-%d", i+1), func(t *testing.T) {
+			converter := GetSQLConverter(MYSQL_TO_ORACLE,
+				map[string]map[string][]string{
+					"T1": {"SYS_C0011": {"ID"}},
+				},
+				map[string][]string{
+					"T1": {"ID", "A"},
+					"T2": {"ID", "NAME"},
+				},
+				map[string]map[string]int{
+					"T2": {"ID": 1},
+				},
+				tcase.opts,
+			)
+			oSql, _, err := converter.Convert(tcase.in)
+			assert.Nil(t, err)
+			assert.Equal(t, tcase.out, oSql)
+		})
+	}
+}
+
+func TestConvertMultiTable(t *testing.T) {
+	testCases := []struct {
+		in, out string
+		err     bool
+		args    []interface{}
+		outArgs []interface{}
+	}{
+		{
+			in:      "update a join b on a.id = b.aid set a.x = b.y, a.z = ? where b.k = ?",
+			out:     `update "a" set "a"."x" = (select "b"."y" from "b" where ("a"."id" = "b"."aid") and ("b"."k" = :v1)), "a"."z" = :v2 where exists (select 1 from "b" where ("a"."id" = "b"."aid") and ("b"."k" = :v3))`,
+			args:    []interface{}{1, 2},
+			outArgs: []interface{}{2, 1, 2},
+		},
+		{
+			in:  "update a t, b set t.x = ifnull(b.y, 0) where t.id = b.aid",
+			out: `update "a" "t" set "t"."x" = (select nvl("b"."y", 0) from "b" where ("t"."id" = "b"."aid")) where exists (select 1 from "b" where ("t"."id" = "b"."aid"))`,
+		},
+		{
+			in:  "delete a from a join b on a.id = b.aid where b.k = 2",
+			out: `delete from "a" where exists (select 1 from "b" where ("a"."id" = "b"."aid") and ("b"."k" = 2))`,
+		},
+		{
+			in:  "delete t from a t where t.id = 1",
+			out: `delete from "a" "t" where "t"."id" = 1`,
+		},
+		{
+			in:  "update a left join b on a.id = b.aid set a.x = 1 where b.aid is null",
+			err: true,
+		},
+		{
+			in:  "update a join b on a.id = b.aid set a.x = 1, b.y = 2",
+			err: true,
+		},
+		{
+			in:  "delete a, b from a join b on a.id = b.aid",
+			err: true,
+		},
+	}
+
+	converter := NewOracleConverter(nil, nil, nil)
+	for i, tcase := range testCases {
+		t.Run(fmt.Sprintf("testcase-%d", i+1), func(t *testing.T) {
+			oSql, newArgs, err := converter.Convert(tcase.in, tcase.args...)
+			if tcase.err {
+				assert.True(t, errors.Is(err, coreerrors.ErrStmtConvert))
+				return
+			}
+			assert.Nil(t, err)
+			assert.Equal(t, tcase.out, oSql)
+			assert.Equal(t, tcase.outArgs, newArgs)
+		})
+	}
+}
+
+func TestConvertDDL(t *testing.T) {
+	testCases := []struct {
+		in   string
+		out  []string
+		err  bool
+		opts ConvertOptions
+	}{
+		{
+			in: "CREATE TABLE `user` (`id` bigint(20) unsigned NOT NULL AUTO_INCREMENT, `name` varchar(64) NOT NULL DEFAULT '' COMMENT '名称', `status` tinyint(4) NOT NULL DEFAULT '0', `kind` enum('a','b''c') DEFAULT 'a', `info` json, `body` longtext, `created` datetime NOT NULL DEFAULT CURRENT_TIMESTAMP, PRIMARY KEY (`id`), UNIQUE KEY `uk_name` (`name`(10)), KEY `idx_status` (`status`, `created`)) ENGINE=InnoDB AUTO_INCREMENT=100 DEFAULT CHARSET=utf8mb4 COMMENT='用户表'",
+			out: []string{
+				`create table "user" ("id" number(20) generated by default as identity (start with 100) not null check ("id" >= 0), "name" varchar2(64 char) default '' not null, "status" number(3) default '0' not null, "kind" varchar2(3 char) default 'a' check ("kind" in ('a', 'b''c')), "info" clob, "body" clob, "created" timestamp(0) default systimestamp not null, primary key ("id"))`,
+				`comment on column "user"."name" is '名称'`,
+				`create unique index "user_uk_name" on "user" ("name")`,
+				`create index "user_idx_status" on "user" ("status", "created")`,
+				`comment on table "user" is '用户表'`,
+			},
+		},
+		{
+			in:   "create table t (id int auto_increment primary key, code varchar(10) unique key, d date default '0000-00-00', f bit(8) default b'101')",
+			opts: ConvertOptions{DriverName: "dm"},
+			out: []string{
+				`create table "t" ("id" number(10) identity(1, 1), "code" varchar2(10 char), "d" date default date '0001-01-01', "f" number(3) default 5, primary key ("id"))`,
+				`create unique index "t_code" on "t" ("code")`,
+			},
+		},
+		{
+			in: "alter table `user` add column `age` int unsigned not null default 0 comment '年龄' after `name`, add unique index uk_age (age), drop index idx_status, modify `name` varchar(128) not null, change `body` `content` text, engine=innodb",
+			out: []string{
+				`alter table "user" add ("age" number(10) default 0 not null check ("age" >= 0))`,
+				`drop index "user_idx_status"`,
+				`alter table "user" modify ("name" varchar2(128 char) not null)`,
+				`alter table "user" rename column "body" to "content"`,
+				`alter table "user" modify ("content" clob)`,
+				`comment on column "user"."age" is '年龄'`,
+				`create unique index "user_uk_age" on "user" ("age")`,
+			},
+		},
+		{
+			in: "alter table user add primary key (id), drop column age, rename key k1 to k2, rename to user2",
+			out: []string{
+				`alter table "user" add primary key ("id")`,
+				`alter table "user" drop column "age"`,
+				`alter index "user_k1" rename to "user_k2"`,
+				`alter table "user" rename to "user2"`,
+			},
+		},
+		{
+			in:  "create unique index uk_a on user (a, b(3))",
+			out: []string{`create unique index "user_uk_a" on "user" ("a", "b")`},
+		},
+		{
+			in:  "drop index uk_a on user",
+			out: []string{`drop index "user_uk_a"`},
+		},
+		{
+			in:  "rename table a to b",
+			out: []string{`alter table "a" rename to "b"`},
+		},
+		{
+			in: "create table t (id int, d datetime(3) not null default current_timestamp(3) on update current_timestamp(3) comment 'key (d)', key (d), key idx_id (id desc, d))",
+			out: []string{
+				`create table "t" ("id" number(10), "d" timestamp(3) default systimestamp not null)`,
+				`create or replace trigger "t_d_on_update" before update on "t" for each row begin if not updating('"d"') then :new."d" := systimestamp; end if; end;`,
+				`comment on column "t"."d" is 'key (d)'`,
+				`create index "t_d" on "t" ("d")`,
+				`create index "t_idx_id" on "t" ("id", "d")`,
+			},
+		},
+		{
+			in: "alter table t add index (a), add unique (a, b)",
+			out: []string{
+				`create index "t_a" on "t" ("a")`,
+				`create unique index "t_a_2" on "t" ("a", "b")`,
+			},
+		},
+		{
+			in:  "create index idx_a on t (a desc)",
+			out: []string{`create index "t_idx_a" on "t" ("a")`},
+		},
+		{
+			in:  "drop table if exists t",
+			out: []string{`begin execute immediate 'drop table "t"'; exception when others then if sqlcode != -942 then raise; end if; end;`},
+		},
+		{
+			in:   "drop table if exists t",
+			opts: ConvertOptions{DriverName: "dm"},
+			out:  []string{`drop table if exists "t"`},
+		},
+		{
+			in:  "create table t2 like t1",
+			err: true,
+		},
+		{
+			in:  "create table t2 as select * from t1",
+			err: true,
+		},
+		{
+			in:  "alter table user partition by hash(id)",
+			err: true,
+		},
+		{
+			in:  "create table t (g geometry)",
+			err: true,
+		},
+	}
+
+	for i, tcase := range testCases {
+		t.Run(fmt.Sprintf("testcase-%d", i+1), func(t *testing.T) {
+			converter := NewOracleConverter(nil, nil, nil)
+			converter.options = tcase.opts
+			stmts, err := converter.ConvertDDL(tcase.in)
+			if tcase.err {
+				assert.True(t, errors.Is(err, coreerrors.ErrStmtConvert))
+				return
+			}
+			assert.Nil(t, err)
+			assert.Equal(t, tcase.out, stmts)
+		})
+	}
+}
@@ -0,0 +1,242 @@
+package sqlparser
+
+import (
+	"fmt"
+
+	"sqlproxy/core/errors"
+	"sqlproxy/core/golog"
+)
+
+// PostgresConverter 将MySQL的SQL转换为PostgreSQL的写法，
+// 绑定参数的数量和顺序保持不变，?在输出时转换为$n
+type PostgresConverter struct {
+	tableUniqueIndexs map[string]map[string][]string
+	tableColumns      map[string][]string
+	incrementColumns  map[string]map[string]int
+	options           ConvertOptions
+}
+
+func init() {
+	RegisterSQLConverter(MYSQL_TO_POSTGRES, func(tableUniqueIndexs map[string]map[string][]string, tableColumns map[string][]string, incrementColumns map[string]map[string]int, opts ConvertOptions) SQLConverter {
+		converter := NewPostgresConverter(tableUniqueIndexs, tableColumns, incrementColumns)
+		converter.options = opts
+		return converter
+	})
+}
+
+func NewPostgresConverter(tableUniqueIndexs map[string]map[string][]string, tableColumns map[string][]string, incrementColumns map[string]map[string]int) *PostgresConverter {
+	return &PostgresConverter{
+		tableUniqueIndexs: tableUniqueIndexs,
+		incrementColumns:  incrementColumns,
+		tableColumns:      tableColumns,
+	}
+}
+
+func (this *PostgresConverter) Convert(sql string, args ...interface{}) (string, []interface{}, error) {
+	stmt, err := parseConvertStmt(sql)
+	if err != nil {
+		golog.Warn("PostgresConverter", "Convert", err.Error(), 0, "sql", sql)
+		return "", args, err
+	}
+	if stmt == nil {
+		return sql, args, nil
+	}
+	stmt = convertStmtFuncs(stmt, postgresFuncDialect)
+	switch n := stmt.(type) {
+	case *Insert:
+		stmt = this.convertInsert(n)
+	case *Select:
+		stmt = this.convertSelect(n)
+	case *Update:
+		n.Where, err = limitByCtid(n.TableExprs, n.Where, n.OrderBy, n.Limit)
+		n.OrderBy, n.Limit = nil, nil
+	case *Delete:
+		if len(n.Targets) > 0 && n.Limit != nil {
+			err = fmt.Errorf("%w: multi-table delete with limit", errors.ErrStmtConvert)
+			break
+		}
+		n.Where, err = limitByCtid(n.TableExprs, n.Where, n.OrderBy, n.Limit)
+		n.OrderBy, n.Limit = nil, nil
+	}
+	if err != nil {
+		golog.Warn("PostgresConverter", "Convert", err.Error(), 0, "sql", sql)
+		return "", args, err
+	}
+
+	convertSQL := NewTrackedBuffer(this.formatNode).WriteNode(stmt).String()
+	golog.Debug("PostgresConverter", "Convert", "ConvertSQL", 0, convertSQL)
+	return convertSQL, args, nil
+}
+
+// convertInsert insert ignore、replace和on duplicate key update转换为insert ... on conflict：
+// insert ignore -> on conflict do nothing
+// replace -> on conflict (unique columns) do update set col = excluded.col
+// on duplicate key update -> on conflict (unique columns) do update set ...，values(col)替换为excluded.col
+func (this *PostgresConverter) convertInsert(stmt *Insert) Statement {
+	ignore := stmt.Ignore != "" && stmt.OnDup == nil
+	this.convertInsertIncrement(stmt)
+	replace := stmt.Action == ReplaceStr
+	onDup := stmt.OnDup
+	stmt.Ignore = ""
+	stmt.Action = InsertStr
+	stmt.OnDup = nil
+	if ignore {
+		return &Upsert{Insert: stmt, Conflict: &OnConflict{}}
+	}
+	if !replace && onDup == nil {
+		return stmt
+	}
+
+	this.fillInsertColumns(stmt)
+	if len(stmt.Columns) == 0 || !insertRowsMatchColumns(stmt) {
+		return stmt
+	}
+	// PostgreSQL的do update必须指定一个唯一索引，有多个时使用第一个
+	condcols := uniqueConditionColumns(this.tableUniqueIndexs[this.options.MetadataKey(stmt.Table.Name.String())], stmt.Columns)
+	if len(condcols) == 0 {
+		golog.Warn("PostgresConverter", "convertInsert", "no unique index for upsert", 0, "table", stmt.Table.Name.String())
+		return stmt
+	}
+	conflict := &OnConflict{}
+	for _, col := range condcols[0] {
+		conflict.Columns = append(conflict.Columns, NewColIdent(col))
+	}
+
+	excluded := NewTableIdent("excluded")
+	if onDup != nil {
+		conflict.Exprs = UpdateExprs(replaceValuesFuncs(MatchedExpr(onDup), excluded))
+		// set左边的列不能带表名，右边没有表名的列指向表中原有的行
+		for _, expr := range conflict.Exprs {
+			expr.Name.Qualifier = TableName{}
+		}
+		setQualifierForTable(conflict.Exprs, stmt.Table)
+	} else {
+		for _, column := range stmt.Columns {
+			if !StringIn(column.String(), condcols[0]...) {
+				conflict.Exprs = append(conflict.Exprs, &UpdateExpr{
+					Name: &ColName{Name: column},
+					Expr: &ColName{Name: column, Qualifier: TableName{Name: excluded}},
+				})
+			}
+		}
+	}
+	return &Upsert{Insert: stmt, Conflict: conflict}
+}
+
+func (this *PostgresConverter) fillInsertColumns(stmt *Insert) {
+	if len(stmt.Columns) > 0 {
+		return
+	}
+	for _, column := range this.tableColumns[this.options.MetadataKey(stmt.Table.Name.String())] {
+		stmt.Columns = append(stmt.Columns, NewColIdent(column))
+	}
+}
+
+// convertInsertIncrement MySQL中自增列插入0和null时都会生成新值，PostgreSQL中插入0会保存0、插入null会报错，
+// 自增列的0和null替换为default，绑定参数替换为coalesce(nullif(?, 0), nextval(序列))，使转换结果与参数的值无关
+func (this *PostgresConverter) convertInsertIncrement(stmt *Insert) {
+	rows, ok := stmt.Rows.(Values)
+	incrementColumns := this.incrementColumns[this.options.MetadataKey(stmt.Table.Name.String())]
+	if !ok || len(incrementColumns) == 0 {
+		return
+	}
+	this.fillInsertColumns(stmt)
+
+	table := NewTrackedBuffer(this.formatNode).WriteNode(stmt.Table).String()
+	for i, column := range stmt.Columns {
+		if _, ok := incrementColumns[this.options.MetadataKey(column.String())]; !ok {
+			continue
+		}
+		// pg_get_serial_sequence的列名参数区分大小写，与输出的列名写法一致
+		sequence := newFuncExpr("pg_get_serial_sequence", NewStrVal([]byte(table)), NewStrVal([]byte(this.identName(column.String()))))
+		for _, row := range rows {
+			if i >= len(row) {
+				continue
+			}
+			switch val := row[i].(type) {
+			case *NullVal:
+				row[i] = &Default{}
+			case *SQLVal:
+				switch val.Type {
+				case IntVal:
+					if string(val.Val) == "0" {
+						row[i] = &Default{}
+					}
+				case ValArg:
+					row[i] = newFuncExpr("coalesce", newFuncExpr("nullif", val, NewIntVal([]byte("0"))), newFuncExpr("nextval", sequence))
+				}
+			}
+		}
+	}
+}
+
+// limitByCtid update/delete的limit转换为按ctid匹配的子查询，order by在子查询中生效，没有limit时order by不影响结果直接去掉：
+// update t set ... where ... order by ... limit n -> update t set ... where ctid in (select ctid from t where ... order by ... limit n)
+func limitByCtid(tableExprs TableExprs, where *Where, orderBy OrderBy, limit *Limit) (*Where, error) {
+	if limit == nil {
+		return where, nil
+	}
+	if len(tableExprs) != 1 {
+		return nil, fmt.Errorf("%w: multi-table update/delete with limit", errors.ErrStmtConvert)
+	}
+	table, ok := tableExprs[0].(*AliasedTableExpr)
+	if !ok {
+		return nil, fmt.Errorf("%w: update/delete with limit on %s", errors.ErrStmtConvert, String(tableExprs[0]))
+	}
+	if _, ok := table.Expr.(TableName); !ok {
+		return nil, fmt.Errorf("%w: update/delete with limit on %s", errors.ErrStmtConvert, String(table))
+	}
+	sub := &Select{
+		SelectExprs: SelectExprs{&AliasedExpr{Expr: newTemplateExpr("ctid")}},
+		From:        TableExprs{&AliasedTableExpr{Expr: table.Expr, As: table.As}},
+		Where:       where,
+		OrderBy:     orderBy,
+		Limit:       limit,
+	}
+	return NewWhere(WhereStr, &ComparisonExpr{
+		Left:     newTemplateExpr("ctid"),
+		Operator: InStr,
+		Right:    &Subquery{Select: sub},
+	}), nil
+}
+
+// setQualifierForTable 为update表达式右边没有表名的列加上目标表名，
+// 否则在on conflict中与excluded的同名列冲突
+func setQualifierForTable(exprs UpdateExprs, table TableName) {
+	visit := func(node SQLNode) (kcontinue bool, err error) {
+		if col, ok := node.(*ColName); ok && col.Qualifier.IsEmpty() {
+			col.Qualifier = TableName{Name: table.Name}
+		}
+		return true, nil
+	}
+	for _, expr := range exprs {
+		_ = Walk(visit, expr.Expr)
+	}
+}
+
+// convertSelect 去掉force index，PostgreSQL没有dual表，select ... from dual去掉from
+func (this *PostgresConverter) convertSelect(stmt *Select) Statement {
+	visit := func(node SQLNode) (kcontinue bool, err error) {
+		if t, ok := node.(*AliasedTableExpr); ok {
+			t.Hints = nil
+		}
+		return true, nil
+	}
+	_ = Walk(visit, stmt.From)
+	if isDualTable(stmt.From) {
+		stmt.From = nil
+	}
+	return stmt
+}
+
+func isDualTable(from TableExprs) bool {
+	if len(from) != 1 {
+		return false
+	}
+	t, ok := from[0].(*AliasedTableExpr)
+	if !ok {
+		return false
+	}
+	name, ok := t.Expr.(TableName)
+	return ok && name.Qualifier.IsEmpty() && name.Name.String() == "dual"
+}
@@ -0,0 +1,108 @@
+package sqlparser
+
+import (
+	"strings"
+)
+
+// formatNode 是PostgreSQL方言的NodeFormatter，只处理语法不同的节点，其它节点保持MySQL的输出
+func (this *PostgresConverter) formatNode(buf *TrackedBuffer, node SQLNode) {
+	switch n := node.(type) {
+	case TableIdent:
+		this.formatIdent(buf, n.String())
+	case ColIdent:
+		this.formatIdent(buf, n.String())
+	case *SQLVal:
+		formatPostgresVal(buf, n)
+	case *AliasedTableExpr:
+		buf.Myprintf("%v", n.Expr)
+		if !n.As.IsEmpty() {
+			buf.Myprintf(" as %v", n.As)
+		}
+	case *Select:
+		formatPostgresSelect(buf, n)
+	case *Limit:
+		formatPostgresLimit(buf, n)
+	case *ComparisonExpr:
+		formatPostgresComparison(buf, n)
+	case *BinaryExpr:
+		formatPostgresBinary(buf, n)
+	default:
+		node.Format(buf)
+	}
+}
+
+// formatIdent 标识符使用双引号，on conflict中的excluded是关键字不加引号
+func (this *PostgresConverter) formatIdent(buf *TrackedBuffer, name string) {
+	if name == "excluded" {
+		buf.WriteString(name)
+		return
+	}
+	name = this.identName(name)
+	buf.WriteByte('"')
+	buf.WriteString(strings.Replace(name, `"`, `""`, -1))
+	buf.WriteByte('"')
+}
+
+// identName 加引号的标识符区分大小写，默认与PostgreSQL对不加引号标识符的处理一致转为小写，
+// 配置了upper_case_ident时转为大写
+func (this *PostgresConverter) identName(name string) string {
+	if this.options.UpperCaseIdent {
+		return strings.ToUpper(name)
+	}
+	return strings.ToLower(name)
+}
+
+// formatPostgresVal 绑定参数:vN输出为$N，字符串的转义和时间零值与Oracle一致
+func formatPostgresVal(buf *TrackedBuffer, node *SQLVal) {
+	if node.Type == ValArg && strings.HasPrefix(string(node.Val), ":v") {
+		buf.WriteByte('$')
+		buf.WriteString(string(node.Val[2:]))
+		return
+	}
+	formatOracleVal(buf, node)
+}
+
+// 去掉PostgreSQL不支持的sql_cache、straight_join等，没有from时不输出from
+func formatPostgresSelect(buf *TrackedBuffer, node *Select) {
+	buf.Myprintf("select %v%s%v", node.Comments, node.Distinct, node.SelectExprs)
+	if len(node.From) > 0 {
+		buf.Myprintf(" from %v", node.From)
+	}
+	lock := node.Lock
+	if lock == ShareModeStr {
+		lock = " for share"
+	}
+	buf.Myprintf("%v%v%v%v%v%s", node.Where, node.GroupBy, node.Having, node.OrderBy, node.Limit, lock)
+}
+
+// limit m, n  ->  limit n offset m
+func formatPostgresLimit(buf *TrackedBuffer, node *Limit) {
+	if node == nil {
+		return
+	}
+	buf.Myprintf(" limit %v", node.Rowcount)
+	if node.Offset != nil {
+		buf.Myprintf(" offset %v", node.Offset)
+	}
+}
+
+// regexp -> ~*，MySQL默认的排序规则下regexp不区分大小写；like的反斜杠转义与Oracle一样显式指定escape
+func formatPostgresComparison(buf *TrackedBuffer, node *ComparisonExpr) {
+	switch node.Operator {
+	case RegexpStr:
+		buf.Myprintf("%v ~* %v", node.Left, node.Right)
+	case NotRegexpStr:
+		buf.Myprintf("%v !~* %v", node.Left, node.Right)
+	default:
+		formatLikeEscape(buf, node)
+	}
+}
+
+// a div b -> div(a, b)，与MySQL一样向零取整
+func formatPostgresBinary(buf *TrackedBuffer, node *BinaryExpr) {
+	if node.Operator == IntDivStr {
+		buf.Myprintf("div(%v, %v)", node.Left, node.Right)
+		return
+	}
+	node.Format(buf)
+}
@@ -0,0 +1,219 @@
+package sqlparser
+
+import (
+	"strings"
+)
+
+// MySQL函数到PostgreSQL表达式的映射，key为小写的函数名，
+// now()、concat()等两边一致的函数不需要转换
+var postgresFuncConverters = map[string]funcConverter{
+	"ifnull":         convertPostgresIfnull,
+	"if":             convertIf,
+	"sysdate":        convertPostgresNow,
+	"localtime":      convertPostgresNow,
+	"localtimestamp": convertPostgresNow,
+	"curdate":        convertPostgresCurdate,
+	"unix_timestamp": convertPostgresUnixTimestamp,
+	"from_unixtime":  convertPostgresFromUnixtime,
+	"date_format":    convertPostgresDateFormat,
+	"date_add":       convertPostgresDateAdd,
+	"adddate":        convertPostgresDateAdd,
+	"date_sub":       convertPostgresDateSub,
+	"subdate":        convertPostgresDateSub,
+	"rand":           convertPostgresRand,
+}
+
+var postgresFuncDialect = funcDialect{
+	funcs:       postgresFuncConverters,
+	groupConcat: convertPostgresGroupConcat,
+	interval:    convertPostgresIntervalArith,
+}
+
+// ifnull(a, b) -> coalesce(a, b)
+func convertPostgresIfnull(args []Expr) Expr {
+	if len(args) != 2 {
+		return nil
+	}
+	return newFuncExpr("coalesce", args...)
+}
+
+// sysdate() -> now()
+func convertPostgresNow(args []Expr) Expr {
+	if len(args) != 0 {
+		return nil
+	}
+	return newTemplateExpr("now()")
+}
+
+// curdate() -> current_date
+func convertPostgresCurdate(args []Expr) Expr {
+	if len(args) != 0 {
+		return nil
+	}
+	return newTemplateExpr("current_date")
+}
+
+// rand() -> random()
+func convertPostgresRand(args []Expr) Expr {
+	if len(args) != 0 {
+		return nil
+	}
+	return newFuncExpr("random")
+}
+
+// unix_timestamp([d]) -> cast(extract(epoch from d) as bigint)
+func convertPostgresUnixTimestamp(args []Expr) Expr {
+	switch len(args) {
+	case 0:
+		return newTemplateExpr("cast(extract(epoch from now()) as bigint)")
+	case 1:
+		return newTemplateExpr("cast(extract(epoch from cast(%v as timestamptz)) as bigint)", args[0])
+	default:
+		return nil
+	}
+}
+
+// from_unixtime(ts[, format]) -> to_timestamp(ts)，有format时再to_char
+func convertPostgresFromUnixtime(args []Expr) Expr {
+	switch len(args) {
+	case 1:
+		return newFuncExpr("to_timestamp", args[0])
+	case 2:
+		format := convertDateFormatArg(args[1], postgresDateFormats)
+		if format == nil {
+			return nil
+		}
+		return newFuncExpr("to_char", newFuncExpr("to_timestamp", args[0]), format)
+	default:
+		return nil
+	}
+}
+
+// date_format(d, format) -> to_char(d, postgres_format)
+func convertPostgresDateFormat(args []Expr) Expr {
+	if len(args) != 2 {
+		return nil
+	}
+	format := convertDateFormatArg(args[1], postgresDateFormats)
+	if format == nil {
+		return nil
+	}
+	return newFuncExpr("to_char", args[0], format)
+}
+
+// MySQL date_format格式符到PostgreSQL to_char格式的映射，
+// PostgreSQL的FM只作用于紧跟的一个格式
+var postgresDateFormats = map[byte]string{
+	'Y': "YYYY",
+	'y': "YY",
+	'm': "MM",
+	'c': "FMMM",
+	'd': "DD",
+	'e': "FMDD",
+	'H': "HH24",
+	'k': "FMHH24",
+	'h': "HH12",
+	'I': "HH12",
+	'l': "FMHH12",
+	'i': "MI",
+	's': "SS",
+	'S': "SS",
+	'f': "US",
+	'p': "AM",
+	'M': "FMMonth",
+	'b': "Mon",
+	'W': "FMDay",
+	'a': "Dy",
+	'j': "DDD",
+	'D': "FMDDth",
+	'U': "WW",
+	'u': "IW",
+	'T': "HH24:MI:SS",
+	'r': "HH12:MI:SS AM",
+}
+
+// date_add(d, interval n unit) / adddate(d, n)
+func convertPostgresDateAdd(args []Expr) Expr {
+	return convertPostgresDateArith(args, false)
+}
+
+// date_sub(d, interval n unit) / subdate(d, n)
+func convertPostgresDateSub(args []Expr) Expr {
+	return convertPostgresDateArith(args, true)
+}
+
+func convertPostgresDateArith(args []Expr, sub bool) Expr {
+	if len(args) != 2 {
+		return nil
+	}
+	interval, ok := args[1].(*IntervalExpr)
+	if !ok {
+		// adddate(d, n)中的n表示天数
+		interval = &IntervalExpr{Expr: args[1], Unit: "day"}
+	}
+	return convertPostgresIntervalArith(args[0], interval, sub)
+}
+
+// MySQL的间隔单位对应的PostgreSQL interval
+var postgresIntervalUnits = map[string]string{
+	"microsecond": "1 microsecond",
+	"second":      "1 second",
+	"minute":      "1 minute",
+	"hour":        "1 hour",
+	"day":         "1 day",
+	"week":        "1 week",
+	"month":       "1 month",
+	"quarter":     "3 month",
+	"year":        "1 year",
+}
+
+// convertPostgresIntervalArith d + interval n unit -> d + (n) * interval '1 unit'，
+// n可能是绑定参数，不能直接拼到interval的字符串里
+func convertPostgresIntervalArith(date Expr, interval *IntervalExpr, sub bool) Expr {
+	unit, ok := postgresIntervalUnits[strings.ToLower(interval.Unit)]
+	if !ok {
+		return nil
+	}
+	op := "+"
+	if sub {
+		op = "-"
+	}
+	return newTemplateExpr("%v "+op+" (%v) * interval '"+unit+"'", date, interval.Expr)
+}
+
+// group_concat -> string_agg(cast(expr as text), sep [order by ...])
+func convertPostgresGroupConcat(node *GroupConcatExpr) Expr {
+	args := make([]Expr, 0, len(node.Exprs))
+	for _, expr := range node.Exprs {
+		aliased, ok := expr.(*AliasedExpr)
+		if !ok {
+			return nil
+		}
+		args = append(args, aliased.Expr)
+	}
+	if len(args) == 0 {
+		return nil
+	}
+	value := args[0]
+	if len(args) > 1 {
+		value = newFuncExpr("concat", args...)
+	}
+
+	template := "string_agg("
+	if node.Distinct != "" {
+		template += "distinct "
+	}
+	template += "cast(%v as text), %v"
+	exprs := []Expr{value, NewStrVal([]byte(getGroupConcatSeparator(node.Separator)))}
+	for i, order := range node.OrderBy {
+		if i == 0 {
+			template += " order by "
+		} else {
+			template += ", "
+		}
+		template += "%v " + order.Direction
+		exprs = append(exprs, order.Expr)
+	}
+	template += ")"
+	return newTemplateExpr(template, exprs...)
+}
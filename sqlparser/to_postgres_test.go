@@ -0,0 +1,259 @@
+package sqlparser
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	coreerrors "sqlproxy/core/errors"
+)
+
+func TestConvertToPostgres(t *testing.T) {
+	testCases := []struct {
+		in, out string
+		args    []interface{}
+	}{
+		{
+			in:   "select `a`, b from t1 where c = ? and d = 'it\\'s' and e = '0000-00-00 00:00:00' limit 10",
+			out:  `select "a", "b" from "t1" where "c" = $1 and "d" = 'it''s' and "e" = '0001-01-01 00:00:00' limit 10`,
+			args: []interface{}{1},
+		},
+		{
+			in:   "select a from t1 force index (idx_a) where b in (?, ?) order by a desc limit ?, ?",
+			out:  `select "a" from "t1" where "b" in ($1, $2) order by "a" desc limit $4 offset $3`,
+			args: []interface{}{1, 2, 3, 4},
+		},
+		{
+			in:  "select sql_no_cache 1 from dual",
+			out: `select 1`,
+		},
+		{
+			in:  "select t.a from t1 as t join t2 u on t.id = u.id lock in share mode",
+			out: `select "t"."a" from "t1" as "t" join "t2" as "u" on "t"."id" = "u"."id" for share`,
+		},
+		{
+			in:  "update t1 set `name` = 'x' where id = 1",
+			out: `update "t1" set "name" = 'x' where "id" = 1`,
+		},
+		{
+			in:  "delete from t1 where id = 1",
+			out: `delete from "t1" where "id" = 1`,
+		},
+		{
+			in:  `select a from t1 where b like 'x\_y' and c not like 'a\%' and d regexp '^a' and e not regexp 'b' and f div 2 > 1`,
+			out: `select "a" from "t1" where "b" like 'x\_y' escape '\' and "c" not like 'a\%' escape '\' and "d" ~* '^a' and "e" !~* 'b' and div("f", 2) > 1`,
+		},
+		{
+			in:  "select UserId, `Name` as UserName from T_User where Status = 1",
+			out: `select "userid", "name" as "username" from "t_user" where "status" = 1`,
+		},
+	}
+
+	converter := NewPostgresConverter(nil, nil, nil)
+	for i, tcase := range testCases {
+		t.Run(fmt.Sprintf("testcase-%d", i+1), func(t *testing.T) {
+			pSql, newArgs, err := converter.Convert(tcase.in, tcase.args...)
+			assert.Nil(t, err)
+			assert.Equal(t, tcase.out, pSql)
+			assert.Equal(t, tcase.args, newArgs)
+		})
+	}
+}
+
+func TestConvertPostgresUpsert(t *testing.T) {
+	testCases := []struct {
+		in, out string
+		args    []interface{}
+	}{
+		{
+			in:  "insert into counter(id, cnt) values (1, 1) on duplicate key update cnt = cnt + values(cnt), updated_at = now()",
+			out: `insert into "counter"("id", "cnt") values (1, 1) on conflict ("id") do update set "cnt" = "counter"."cnt" + excluded."cnt", "updated_at" = now()`,
+		},
+		{
+			in:   "insert into counter(id, cnt) values (?, ?), (?, ?) on duplicate key update counter.cnt = ifnull(counter.cnt, 0) + values(cnt) * ?, updated_at = values(updated_at)",
+			out:  `insert into "counter"("id", "cnt") values ($1, $2), ($3, $4) on conflict ("id") do update set "cnt" = coalesce("counter"."cnt", 0) + excluded."cnt" * $5, "updated_at" = excluded."updated_at"`,
+			args: []interface{}{1, 2, 3, 4, 5},
+		},
+		{
+			in:  "replace into t1 (id, a, b, c) values (1, 'x', 'y', 'z')",
+			out: `insert into "t1"("id", "a", "b", "c") values (1, 'x', 'y', 'z') on conflict ("id") do update set "a" = excluded."a", "b" = excluded."b", "c" = excluded."c"`,
+		},
+		{
+			in:  "replace into t1 (a, b, c) values ('x', 'y', 'z')",
+			out: `insert into "t1"("a", "b", "c") values ('x', 'y', 'z') on conflict ("a", "b") do update set "c" = excluded."c"`,
+		},
+		{
+			in:  "replace into t1 values (1, 'x', 'y', 'z')",
+			out: `insert into "t1"("id", "a", "b", "c") values (1, 'x', 'y', 'z') on conflict ("id") do update set "a" = excluded."a", "b" = excluded."b", "c" = excluded."c"`,
+		},
+		{
+			in:  "insert ignore into t1(id, a) values (1, 'x')",
+			out: `insert into "t1"("id", "a") values (1, 'x') on conflict do nothing`,
+		},
+		{
+			in:  "insert into t2(id) values (1) on duplicate key update id = id",
+			out: `insert into "t2"("id") values (1)`,
+		},
+	}
+
+	converter := NewPostgresConverter(map[string]map[string][]string{
+		"counter": {"counter_pkey": {"id"}},
+		"t1": {
+			"t1_pkey": {"id"},
+			"uk_a_b":  {"a", "b"},
+		},
+	}, map[string][]string{
+		"t1": {"id", "a", "b", "c"},
+	}, nil)
+	for i, tcase := range testCases {
+		t.Run(fmt.Sprintf("testcase-%d", i+1), func(t *testing.T) {
+			pSql, newArgs, err := converter.Convert(tcase.in, tcase.args...)
+			assert.Nil(t, err)
+			assert.Equal(t, tcase.out, pSql)
+			assert.Equal(t, tcase.args, newArgs)
+		})
+	}
+}
+
+func TestConvertPostgresIdentCase(t *testing.T) {
+	testCases := []struct {
+		opts    ConvertOptions
+		in, out string
+	}{
+		{
+			opts: ConvertOptions{DriverName: "postgres"},
+			in:   "replace into T_User(Id, UserName) values (1, 'x')",
+			out:  `insert into "t_user"("id", "username") values (1, 'x') on conflict ("id") do update set "username" = excluded."username"`,
+		},
+		{
+			opts: ConvertOptions{DriverName: "postgres", UpperCaseIdent: true},
+			in:   "select UserName from t_user where Id = 1",
+			out:  `select "USERNAME" from "T_USER" where "ID" = 1`,
+		},
+	}
+
+	for i, tcase := range testCases {
+		t.Run(fmt.Sprintf("testcase-%d", i+1), func(t *testing.T) {
+			converter := GetSQLConverter(MYSQL_TO_POSTGRES, map[string]map[string][]string{
+				"t_user": {"t_user_pkey": {"id"}},
+			}, nil, nil, tcase.opts)
+			pSql, _, err := converter.Convert(tcase.in)
+			assert.Nil(t, err)
+			assert.Equal(t, tcase.out, pSql)
+		})
+	}
+}
+
+func TestConvertPostgresFuncs(t *testing.T) {
+	testCases := []struct {
+		in, out string
+		args    []interface{}
+	}{
+		{
+			in:  "select ifnull(a, 0), if(a > 1, 'x', 'y'), now(), sysdate(), curdate(), rand() from t1",
+			out: `select coalesce("a", 0), case when "a" > 1 then 'x' else 'y' end, now(), now(), current_date, random() from "t1"`,
+		},
+		{
+			in:  "select unix_timestamp(), unix_timestamp(d), from_unixtime(ts), from_unixtime(ts, '%Y-%m-%d') from t1",
+			out: `select cast(extract(epoch from now()) as bigint), cast(extract(epoch from cast("d" as timestamptz)) as bigint), to_timestamp("ts"), to_char(to_timestamp("ts"), 'YYYY-MM-DD') from "t1"`,
+		},
+		{
+			in:  "select date_format(d, '%Y-%m-%d %H:%i:%s'), date_format(d, '%Y年%c月%e日') from t1",
+			out: `select to_char("d", 'YYYY-MM-DD HH24:MI:SS'), to_char("d", 'YYYY"年"FMMM"月"FMDD"日"') from "t1"`,
+		},
+		{
+			in:   "select * from t1 where d > date_sub(now(), interval ? day) and e < adddate(d, 2) and f > d - interval 1 quarter and a = ?",
+			out:  `select * from "t1" where "d" > now() - ($1) * interval '1 day' and "e" < "d" + (2) * interval '1 day' and "f" > "d" - (1) * interval '3 month' and "a" = $2`,
+			args: []interface{}{7, 1},
+		},
+		{
+			in:  "select b, group_concat(a order by c desc separator ';'), group_concat(a, c), group_concat(distinct a) from t1 group by b",
+			out: `select "b", string_agg(cast("a" as text), ';' order by "c" desc), string_agg(cast(concat("a", "c") as text), ','), string_agg(distinct cast("a" as text), ',') from "t1" group by "b"`,
+		},
+	}
+
+	converter := NewPostgresConverter(nil, nil, nil)
+	for i, tcase := range testCases {
+		t.Run(fmt.Sprintf("testcase-%d", i+1), func(t *testing.T) {
+			pSql, newArgs, err := converter.Convert(tcase.in, tcase.args...)
+			assert.Nil(t, err)
+			assert.Equal(t, tcase.out, pSql)
+			assert.Equal(t, tcase.args, newArgs)
+		})
+	}
+}
+
+func TestConvertPostgresLimit(t *testing.T) {
+	testCases := []struct {
+		in, out string
+		err     bool
+		args    []interface{}
+	}{
+		{
+			in:   "update t1 set a = ? where b = ? order by c desc limit ?",
+			out:  `update "t1" set "a" = $1 where ctid in (select ctid from "t1" where "b" = $2 order by "c" desc limit $3)`,
+			args: []interface{}{1, 2, 3},
+		},
+		{
+			in:  "update t1 set a = 1 order by c",
+			out: `update "t1" set "a" = 1`,
+		},
+		{
+			in:  "delete from t1 where b = 1 limit 10",
+			out: `delete from "t1" where ctid in (select ctid from "t1" where "b" = 1 limit 10)`,
+		},
+		{
+			in:  "update t1 join t2 on t1.id = t2.id set t1.a = 1 limit 1",
+			err: true,
+		},
+	}
+
+	converter := NewPostgresConverter(nil, nil, nil)
+	for i, tcase := range testCases {
+		t.Run(fmt.Sprintf("testcase-%d", i+1), func(t *testing.T) {
+			pSql, newArgs, err := converter.Convert(tcase.in, tcase.args...)
+			if tcase.err {
+				assert.True(t, errors.Is(err, coreerrors.ErrStmtConvert))
+				return
+			}
+			assert.Nil(t, err)
+			assert.Equal(t, tcase.out, pSql)
+			assert.Equal(t, tcase.args, newArgs)
+		})
+	}
+}
+
+func TestConvertPostgresIncrement(t *testing.T) {
+	testCases := []struct {
+		in, out string
+		args    []interface{}
+	}{
+		{
+			in:  "insert into t1 values (0, 'x'), (null, 'y'), (5, 'z')",
+			out: `insert into "t1"("id", "a") values (default, 'x'), (default, 'y'), (5, 'z')`,
+		},
+		{
+			in:   "insert into t1(a, id) values (?, ?) on duplicate key update a = values(a)",
+			out:  `insert into "t1"("a", "id") values ($1, coalesce(nullif($2, 0), nextval(pg_get_serial_sequence('"t1"', 'id')))) on conflict ("id") do update set "a" = excluded."a"`,
+			args: []interface{}{"x", 0},
+		},
+	}
+
+	converter := NewPostgresConverter(map[string]map[string][]string{
+		"t1": {"t1_pkey": {"id"}},
+	}, map[string][]string{
+		"t1": {"id", "a"},
+	}, map[string]map[string]int{
+		"t1": {"id": 1},
+	})
+	for i, tcase := range testCases {
+		t.Run(fmt.Sprintf("testcase-%d", i+1), func(t *testing.T) {
+			pSql, newArgs, err := converter.Convert(tcase.in, tcase.args...)
+			assert.Nil(t, err)
+			assert.Equal(t, tcase.out, pSql)
+			assert.Equal(t, tcase.args, newArgs)
+		})
+	}
+}
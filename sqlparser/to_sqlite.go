@@ -0,0 +1,133 @@
+package sqlparser
+
+import (
+	"sqlproxy/core/golog"
+)
+
+// SQLiteConverter 将MySQL的SQL转换为SQLite的写法，用于本地开发和测试。
+// SQLite本身支持反引号、limit m, n、replace into等MySQL的写法，只转换不兼容的部分
+type SQLiteConverter struct {
+	tableUniqueIndexs map[string]map[string][]string
+	tableColumns      map[string][]string
+	incrementColumns  map[string]map[string]int
+	options           ConvertOptions
+}
+
+func init() {
+	RegisterSQLConverter(MYSQL_TO_SQLITE, func(tableUniqueIndexs map[string]map[string][]string, tableColumns map[string][]string, incrementColumns map[string]map[string]int, opts ConvertOptions) SQLConverter {
+		converter := NewSQLiteConverter(tableUniqueIndexs, tableColumns, incrementColumns)
+		converter.options = opts
+		return converter
+	})
+}
+
+func NewSQLiteConverter(tableUniqueIndexs map[string]map[string][]string, tableColumns map[string][]string, incrementColumns map[string]map[string]int) *SQLiteConverter {
+	return &SQLiteConverter{
+		tableUniqueIndexs: tableUniqueIndexs,
+		incrementColumns:  incrementColumns,
+		tableColumns:      tableColumns,
+	}
+}
+
+func (this *SQLiteConverter) Convert(sql string, args ...interface{}) (string, []interface{}, error) {
+	stmt, err := parseConvertStmt(sql)
+	if err != nil {
+		golog.Warn("SQLiteConverter", "Convert", err.Error(), 0, "sql", sql)
+		return "", args, err
+	}
+	if stmt == nil {
+		return sql, args, nil
+	}
+	stmt = convertStmtFuncs(stmt, sqliteFuncDialect)
+	switch n := stmt.(type) {
+	case *Insert:
+		stmt = this.convertInsert(n)
+	case *Select:
+		stmt = this.convertSelect(n)
+	}
+
+	convertSQL := NewTrackedBuffer(this.formatNode).WriteNode(stmt).String()
+	golog.Debug("SQLiteConverter", "Convert", "ConvertSQL", 0, convertSQL)
+	return convertSQL, args, nil
+}
+
+// convertInsert insert ignore转换为insert or ignore，on duplicate key update转换为on conflict do update，
+// 不指定冲突的列时SQLite与MySQL一样匹配任意一个唯一索引
+func (this *SQLiteConverter) convertInsert(stmt *Insert) Statement {
+	this.convertInsertIncrement(stmt)
+	if stmt.OnDup == nil {
+		if stmt.Ignore != "" {
+			stmt.Action = InsertStr + " or ignore"
+			stmt.Ignore = ""
+		}
+		return stmt
+	}
+
+	conflict := &OnConflict{
+		Exprs: UpdateExprs(replaceValuesFuncs(MatchedExpr(stmt.OnDup), NewTableIdent("excluded"))),
+	}
+	// set左边的列不能带表名
+	for _, expr := range conflict.Exprs {
+		expr.Name.Qualifier = TableName{}
+	}
+	stmt.Ignore = ""
+	stmt.OnDup = nil
+	// insert ... select后面直接跟on conflict时有语法歧义，select需要带上where
+	if sel, ok := stmt.Rows.(*Select); ok && sel.Where == nil {
+		sel.Where = NewWhere(WhereStr, NewIntVal([]byte("1")))
+	}
+	return &Upsert{Insert: stmt, Conflict: conflict}
+}
+
+// convertInsertIncrement MySQL中自增列插入0和null时都会生成新值，SQLite中插入0会保存0，
+// 将自增列的0替换为null，绑定参数替换为nullif(?, 0)，使转换结果与参数的值无关
+func (this *SQLiteConverter) convertInsertIncrement(stmt *Insert) {
+	rows, ok := stmt.Rows.(Values)
+	incrementColumns := this.incrementColumns[this.options.MetadataKey(stmt.Table.Name.String())]
+	if !ok || len(incrementColumns) == 0 {
+		return
+	}
+	if len(stmt.Columns) == 0 {
+		for _, column := range this.tableColumns[this.options.MetadataKey(stmt.Table.Name.String())] {
+			stmt.Columns = append(stmt.Columns, NewColIdent(column))
+		}
+	}
+
+	for i, column := range stmt.Columns {
+		if _, ok := incrementColumns[this.options.MetadataKey(column.String())]; !ok {
+			continue
+		}
+		for _, row := range rows {
+			if i >= len(row) {
+				continue
+			}
+			val, ok := row[i].(*SQLVal)
+			if !ok {
+				continue
+			}
+			switch val.Type {
+			case IntVal:
+				if string(val.Val) == "0" {
+					row[i] = &NullVal{}
+				}
+			case ValArg:
+				row[i] = newFuncExpr("nullif", val, NewIntVal([]byte("0")))
+			}
+		}
+	}
+}
+
+// convertSelect 去掉SQLite不支持的索引提示，SQLite没有dual表，select ... from dual去掉from
+func (this *SQLiteConverter) convertSelect(stmt *Select) Statement {
+	visit := func(node SQLNode) (kcontinue bool, err error) {
+		if t, ok := node.(*AliasedTableExpr); ok {
+			t.Hints = nil
+		}
+		return true, nil
+	}
+	_ = Walk(visit, stmt.From)
+	if isDualTable(stmt.From) {
+		stmt.From = nil
+	}
+	return stmt
+}
@@ -0,0 +1,347 @@
+package sqlparser
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"sqlproxy/core/errors"
+	"sqlproxy/core/golog"
+)
+
+// 解析器不保留create table的if not exists
+var createTableIfNotExistsRegexp = regexp.MustCompile(`(?is)^\s*create\s+(?:temporary\s+)?table\s+if\s+not\s+exists\b`)
+
+// sqliteDDL 一条MySQL DDL转换出的语句，索引在SQLite中需要单独的语句，放在extras中最后执行
+type sqliteDDL struct {
+	*SQLiteConverter
+	table       TableName
+	ifNotExists string
+	stmts       []string
+	extras      []string
+}
+
+// ConvertDDL 将MySQL的DDL转换为SQLite的语句：列类型转为SQLite的类型亲和性，
+// auto_increment主键转为integer primary key autoincrement，索引转为单独的create index，
+// engine、charset、注释等去掉
+func (this *SQLiteConverter) ConvertDDL(sql string) ([]string, error) {
+	if Preview(sql) != StmtDDL {
+		return nil, fmt.Errorf("%w: not a ddl statement", errors.ErrStmtConvert)
+	}
+	var (
+		stmts []string
+		err   error
+	)
+	if m := createIndexRegexp.FindStringSubmatch(sql); m != nil {
+		stmts, err = this.convertCreateIndex(m[1], m[2], m[3], m[4])
+	} else if m := dropIndexRegexp.FindStringSubmatch(sql); m != nil {
+		stmts, err = this.convertDropIndex(m[1], m[2])
+	} else if m := alterTableRegexp.FindStringSubmatch(sql); m != nil {
+		stmts, err = this.convertAlterTable(m[1], m[2])
+	} else {
+		stmts, err = this.convertDDLStmt(sql)
+	}
+	if err != nil {
+		golog.Warn("SQLiteConverter", "ConvertDDL", err.Error(), 0, "sql", sql)
+		return nil, err
+	}
+	golog.Debug("SQLiteConverter", "ConvertDDL", "ConvertSQL", 0, strings.Join(stmts, ";\n"))
+	return stmts, nil
+}
+
+func (this *SQLiteConverter) convertDDLStmt(sql string) ([]string, error) {
+	stmt, err := Parse(sql)
+	if err != nil {
+		return nil, err
+	}
+	ddl, ok := stmt.(*DDL)
+	if !ok {
+		return nil, fmt.Errorf("can not convert ddl: %s", sql)
+	}
+	d := this.newDDL(ddl.Table)
+	switch ddl.Action {
+	case CreateStr:
+		if ddl.TableSpec == nil {
+			return nil, fmt.Errorf("can not convert ddl: %s", sql)
+		}
+		d.table = ddl.NewName
+		if createTableIfNotExistsRegexp.MatchString(sql) {
+			d.ifNotExists = "if not exists "
+		}
+		if err := d.createTable(ddl.TableSpec); err != nil {
+			return nil, err
+		}
+	case DropStr:
+		exists := ""
+		if ddl.IfExists {
+			exists = " if exists"
+		}
+		d.add("drop table%s %v", exists, ddl.Table)
+	case TruncateStr:
+		// SQLite没有truncate
+		d.add("delete from %v", ddl.Table)
+	case RenameStr:
+		d.add("alter table %v rename to %v", ddl.Table, ddl.NewName.Name)
+	default:
+		return nil, fmt.Errorf("can not convert ddl: %s", sql)
+	}
+	return d.result(), nil
+}
+
+func (this *SQLiteConverter) newDDL(table TableName) *sqliteDDL {
+	return &sqliteDDL{SQLiteConverter: this, table: table}
+}
+
+func (this *SQLiteConverter) convertCreateIndex(kind, name, table, columns string) ([]string, error) {
+	tableName, err := parseTableName(table)
+	if err != nil {
+		return nil, err
+	}
+	indexes, err := parseIndexDefs(fmt.Sprintf("%skey %s %s", kind, name, columns))
+	if err != nil {
+		return nil, err
+	}
+	d := this.newDDL(tableName)
+	for _, idx := range indexes {
+		d.addIndex(idx)
+	}
+	return d.result(), nil
+}
+
+func (this *SQLiteConverter) convertDropIndex(name, table string) ([]string, error) {
+	tableName, err := parseTableName(table)
+	if err != nil {
+		return nil, err
+	}
+	d := this.newDDL(tableName)
+	d.add("drop index %v", d.indexName(unquoteIdent(name)))
+	return d.result(), nil
+}
+
+// convertAlterTable SQLite的alter table只支持新增、删除、重命名列和重命名表
+func (this *SQLiteConverter) convertAlterTable(table, specs string) ([]string, error) {
+	tableName, err := parseTableName(table)
+	if err != nil {
+		return nil, err
+	}
+	d := this.newDDL(tableName)
+	for _, spec := range splitAlterSpecs(specs) {
+		if err := d.alterSpec(spec); err != nil {
+			return nil, err
+		}
+	}
+	return d.result(), nil
+}
+
+func (this *sqliteDDL) createTable(spec *TableSpec) error {
+	primary := Columns{}
+	for _, idx := range spec.Indexes {
+		if idx.Info.Primary {
+			for _, col := range idx.Columns {
+				primary = append(primary, col.Column)
+			}
+		}
+	}
+	for _, col := range spec.Columns {
+		if col.Type.KeyOpt == colKeyPrimary {
+			primary = append(primary, col.Name)
+		}
+	}
+
+	defs := []string{}
+	for _, col := range spec.Columns {
+		// 自增列只能是integer primary key，主键在列定义中声明
+		rowid := bool(col.Type.Autoincrement) && (len(primary) == 0 || len(primary) == 1 && primary[0].Equal(col.Name))
+		if rowid {
+			primary = nil
+		}
+		def, err := this.columnDef(col, rowid)
+		if err != nil {
+			return err
+		}
+		defs = append(defs, def)
+	}
+	if len(primary) > 0 {
+		defs = append(defs, this.sprintf("primary key %v", primary))
+	}
+	for _, idx := range spec.Indexes {
+		this.addIndex(idx)
+	}
+	this.add("create table %s%v (%s)", this.ifNotExists, this.table, strings.Join(defs, ", "))
+	return nil
+}
+
+func (this *sqliteDDL) alterSpec(spec string) error {
+	if m := specAddIndexRegexp.FindStringSubmatch(spec); m != nil {
+		indexes, err := parseIndexDefs(m[1])
+		if err != nil {
+			return err
+		}
+		for _, idx := range indexes {
+			if idx.Info.Primary {
+				return fmt.Errorf("%w: add primary key in sqlite", errors.ErrStmtConvert)
+			}
+			this.addIndex(idx)
+		}
+		return nil
+	}
+	if m := specAddColumnRegexp.FindStringSubmatch(spec); m != nil {
+		defs := strings.TrimSpace(m[1])
+		if strings.HasPrefix(defs, "(") && strings.HasSuffix(defs, ")") {
+			defs = defs[1 : len(defs)-1]
+		}
+		ts, err := parseTableSpec(columnPositionRegexp.ReplaceAllString(defs, ""))
+		if err != nil {
+			return err
+		}
+		// SQLite一次只能新增一列
+		for _, col := range ts.Columns {
+			def, err := this.columnDef(col, false)
+			if err != nil {
+				return err
+			}
+			this.add("alter table %v add column %s", this.table, def)
+		}
+		for _, idx := range ts.Indexes {
+			this.addIndex(idx)
+		}
+		return nil
+	}
+	if m := specDropIndexRegexp.FindStringSubmatch(spec); m != nil {
+		this.add("drop index %v", this.indexName(unquoteIdent(m[1])))
+		return nil
+	}
+	if m := specDropColumnRegexp.FindStringSubmatch(spec); m != nil {
+		this.add("alter table %v drop column %v", this.table, unquoteIdent(m[1]))
+		return nil
+	}
+	if m := specRenameColumnRegexp.FindStringSubmatch(spec); m != nil {
+		this.add("alter table %v rename column %v to %v", this.table, unquoteIdent(m[1]), unquoteIdent(m[2]))
+		return nil
+	}
+	if m := specRenameTableRegexp.FindStringSubmatch(spec); m != nil {
+		newName, err := parseTableName(m[1])
+		if err != nil {
+			return err
+		}
+		this.add("alter table %v rename to %v", this.table, newName.Name)
+		return nil
+	}
+	if specTableOptionRegexp.MatchString(spec) {
+		return nil
+	}
+	return fmt.Errorf("%w: unsupported alter table specification in sqlite: %s", errors.ErrStmtConvert, spec)
+}
+
+// columnDef 生成列定义：name type [primary key autoincrement] [not null] [default x] [unique]
+func (this *sqliteDDL) columnDef(col *ColumnDefinition, rowid bool) (string, error) {
+	ct := &col.Type
+	typ, err := sqliteColumnType(ct)
+	if err != nil {
+		return "", err
+	}
+	buf := NewTrackedBuffer(this.formatNode)
+	if rowid {
+		buf.Myprintf("%v integer primary key autoincrement", col.Name)
+	} else {
+		buf.Myprintf("%v %s", col.Name, typ)
+	}
+	if ct.NotNull {
+		buf.Myprintf(" not null")
+	}
+	if ct.Default != nil && !ct.Autoincrement {
+		buf.Myprintf(" default ")
+		this.formatDefault(buf, ct.Default)
+	}
+	switch ct.KeyOpt {
+	case colKeyUnique, colKeyUniqueKey:
+		buf.Myprintf(" unique")
+	case colKey:
+		this.addExtra("create index %s%v on %v (%v)", this.ifNotExists, this.indexName(col.Name), this.table, col.Name)
+	}
+	return buf.String(), nil
+}
+
+func (this *sqliteDDL) formatDefault(buf *TrackedBuffer, val *SQLVal) {
+	switch val.Type {
+	case ValArg:
+		// current_timestamp、null，SQLite的current_timestamp是UTC时间
+		if strings.ToLower(string(val.Val)) == "current_timestamp" {
+			buf.WriteString("(datetime('now', 'localtime'))")
+			return
+		}
+		buf.Write(val.Val)
+	case BitVal:
+		n, _ := strconv.ParseUint(string(val.Val), 2, 64)
+		buf.WriteString(strconv.FormatUint(n, 10))
+	default:
+		buf.Myprintf("%v", val)
+	}
+}
+
+// addIndex 索引转为单独的create index语句，主键在建表语句中处理
+func (this *sqliteDDL) addIndex(idx *IndexDefinition) {
+	if idx.Info.Primary {
+		return
+	}
+	if idx.Info.Spatial || strings.Contains(strings.ToLower(idx.Info.Type), "fulltext") {
+		golog.Warn("SQLiteConverter", "ConvertDDL", "ignore unsupported index", 0, "index", idx.Info.Name.String())
+		return
+	}
+	cols := Columns{}
+	for _, col := range idx.Columns {
+		cols = append(cols, col.Column)
+	}
+	unique := ""
+	if idx.Info.Unique {
+		unique = "unique "
+	}
+	this.addExtra("create %sindex %s%v on %v %v", unique, this.ifNotExists, this.indexName(idx.Info.Name), this.table, cols)
+}
+
+// indexName SQLite中索引名在库内唯一，索引名前加上表名避免冲突
+func (this *sqliteDDL) indexName(name ColIdent) ColIdent {
+	return NewColIdent(this.table.Name.String() + "_" + name.String())
+}
+
+func (this *sqliteDDL) sprintf(format string, values ...interface{}) string {
+	buf := NewTrackedBuffer(this.formatNode)
+	buf.Myprintf(format, values...)
+	return buf.String()
+}
+
+func (this *sqliteDDL) add(format string, values ...interface{}) {
+	this.stmts = append(this.stmts, this.sprintf(format, values...))
+}
+
+func (this *sqliteDDL) addExtra(format string, values ...interface{}) {
+	this.extras = append(this.extras, this.sprintf(format, values...))
+}
+
+func (this *sqliteDDL) result() []string {
+	return append(this.stmts, this.extras...)
+}
+
+// sqliteColumnType 将MySQL的列类型转换为SQLite的类型亲和性，
+// 时间类型使用text保存，读出的格式与MySQL一致。
+// 整数类型保留原类型名：只有声明为integer的单列主键才是rowid的别名，不能保存超出范围的值
+func sqliteColumnType(ct *ColumnType) (string, error) {
+	switch typ := strings.ToLower(ct.Type); typ {
+	case "tinyint", "smallint", "mediumint", "int", "bigint":
+		return typ, nil
+	case "bit", "bool", "boolean", "integer", "year":
+		return "integer", nil
+	case "float", "double", "real":
+		return "real", nil
+	case "decimal", "numeric":
+		return "numeric", nil
+	case "char", "varchar", "tinytext", "text", "mediumtext", "longtext", "json", "enum", "set",
+		"date", "time", "datetime", "timestamp":
+		return "text", nil
+	case "binary", "varbinary", "tinyblob", "blob", "mediumblob", "longblob":
+		return "blob", nil
+	default:
+		return "", fmt.Errorf("%w: unsupported column type %s", errors.ErrStmtConvert, typ)
+	}
+}
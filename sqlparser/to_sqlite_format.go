@@ -0,0 +1,48 @@
+package sqlparser
+
+import (
+	"strings"
+)
+
+// formatNode 是SQLite方言的NodeFormatter，标识符的反引号SQLite可以识别，保持MySQL的输出
+func (this *SQLiteConverter) formatNode(buf *TrackedBuffer, node SQLNode) {
+	switch n := node.(type) {
+	case *SQLVal:
+		formatSQLiteVal(buf, n)
+	case *ComparisonExpr:
+		formatLikeEscape(buf, n)
+	case *AliasedTableExpr:
+		buf.Myprintf("%v", n.Expr)
+		if !n.As.IsEmpty() {
+			buf.Myprintf(" as %v", n.As)
+		}
+	case *Select:
+		formatSQLiteSelect(buf, n)
+	default:
+		node.Format(buf)
+	}
+}
+
+// formatSQLiteVal 绑定参数:vN输出为?N，字符串常量中的单引号写两次，反斜杠不转义
+func formatSQLiteVal(buf *TrackedBuffer, node *SQLVal) {
+	switch {
+	case node.Type == ValArg && strings.HasPrefix(string(node.Val), ":v"):
+		buf.WriteByte('?')
+		buf.WriteString(string(node.Val[2:]))
+	case node.Type == StrVal:
+		buf.WriteByte('\'')
+		buf.WriteString(strings.Replace(string(node.Val), "'", "''", -1))
+		buf.WriteByte('\'')
+	default:
+		node.Format(buf)
+	}
+}
+
+// 去掉SQLite不支持的sql_cache、straight_join及for update等锁，没有from时不输出from
+func formatSQLiteSelect(buf *TrackedBuffer, node *Select) {
+	buf.Myprintf("select %v%s%v", node.Comments, node.Distinct, node.SelectExprs)
+	if len(node.From) > 0 {
+		buf.Myprintf(" from %v", node.From)
+	}
+	buf.Myprintf("%v%v%v%v%v", node.Where, node.GroupBy, node.Having, node.OrderBy, node.Limit)
+}
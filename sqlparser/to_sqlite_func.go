@@ -0,0 +1,239 @@
+package sqlparser
+
+import (
+	"strings"
+)
+
+// MySQL函数到SQLite表达式的映射，key为小写的函数名，ifnull等两边一致的函数不需要转换。
+// SQLite中的时间是'YYYY-MM-DD HH:MM:SS'格式的字符串，与MySQL的输出一致
+var sqliteFuncConverters = map[string]funcConverter{
+	"if":                convertIf,
+	"now":               convertSQLiteNow,
+	"sysdate":           convertSQLiteNow,
+	"current_timestamp": convertSQLiteNow,
+	"localtime":         convertSQLiteNow,
+	"localtimestamp":    convertSQLiteNow,
+	"curdate":           convertSQLiteCurdate,
+	"current_date":      convertSQLiteCurdate,
+	"curtime":           convertSQLiteCurtime,
+	"current_time":      convertSQLiteCurtime,
+	"unix_timestamp":    convertSQLiteUnixTimestamp,
+	"from_unixtime":     convertSQLiteFromUnixtime,
+	"date_format":       convertSQLiteDateFormat,
+	"date_add":          convertSQLiteDateAdd,
+	"adddate":           convertSQLiteDateAdd,
+	"date_sub":          convertSQLiteDateSub,
+	"subdate":           convertSQLiteDateSub,
+	"concat":            convertSQLiteConcat,
+	"rand":              convertSQLiteRand,
+	"last_insert_id":    convertSQLiteLastInsertId,
+	"version":           convertSQLiteVersion,
+}
+
+var sqliteFuncDialect = funcDialect{
+	funcs:       sqliteFuncConverters,
+	groupConcat: convertSQLiteGroupConcat,
+	interval:    convertSQLiteIntervalArith,
+}
+
+// now() -> datetime('now', 'localtime')
+func convertSQLiteNow(args []Expr) Expr {
+	if len(args) != 0 {
+		return nil
+	}
+	return newTemplateExpr("datetime('now', 'localtime')")
+}
+
+// curdate() -> date('now', 'localtime')
+func convertSQLiteCurdate(args []Expr) Expr {
+	if len(args) != 0 {
+		return nil
+	}
+	return newTemplateExpr("date('now', 'localtime')")
+}
+
+// curtime() -> time('now', 'localtime')
+func convertSQLiteCurtime(args []Expr) Expr {
+	if len(args) != 0 {
+		return nil
+	}
+	return newTemplateExpr("time('now', 'localtime')")
+}
+
+// unix_timestamp([d]) -> cast(strftime('%s', d) as integer)，d是本地时间，
+// 模板中不能直接写%s，格式串作为参数传入
+func convertSQLiteUnixTimestamp(args []Expr) Expr {
+	seconds := NewStrVal([]byte("%s"))
+	switch len(args) {
+	case 0:
+		return newTemplateExpr("cast(strftime(%v, 'now') as integer)", seconds)
+	case 1:
+		return newTemplateExpr("cast(strftime(%v, %v, 'utc') as integer)", seconds, args[0])
+	default:
+		return nil
+	}
+}
+
+// from_unixtime(ts[, format]) -> datetime(ts, 'unixepoch', 'localtime')，有format时使用strftime
+func convertSQLiteFromUnixtime(args []Expr) Expr {
+	switch len(args) {
+	case 1:
+		return newTemplateExpr("datetime(%v, 'unixepoch', 'localtime')", args[0])
+	case 2:
+		format := convertSQLiteDateFormatArg(args[1])
+		if format == nil {
+			return nil
+		}
+		return newTemplateExpr("strftime(%v, %v, 'unixepoch', 'localtime')", format, args[0])
+	default:
+		return nil
+	}
+}
+
+// date_format(d, format) -> strftime(sqlite_format, d)
+func convertSQLiteDateFormat(args []Expr) Expr {
+	if len(args) != 2 {
+		return nil
+	}
+	format := convertSQLiteDateFormatArg(args[1])
+	if format == nil {
+		return nil
+	}
+	return newFuncExpr("strftime", format, args[0])
+}
+
+// MySQL date_format格式符到SQLite strftime格式的映射，SQLite不支持的格式符不转换
+var sqliteDateFormats = map[byte]string{
+	'Y': "%Y",
+	'm': "%m",
+	'd': "%d",
+	'H': "%H",
+	'i': "%M",
+	's': "%S",
+	'S': "%S",
+	'j': "%j",
+	'w': "%w",
+	'u': "%W",
+	'T': "%H:%M:%S",
+	'%': "%%",
+}
+
+func convertSQLiteDateFormatArg(expr Expr) Expr {
+	val, ok := expr.(*SQLVal)
+	if !ok || val.Type != StrVal {
+		return nil
+	}
+	var buf strings.Builder
+	mask := string(val.Val)
+	for i := 0; i < len(mask); i++ {
+		if mask[i] != '%' || i+1 == len(mask) {
+			buf.WriteByte(mask[i])
+			continue
+		}
+		i++
+		f, ok := sqliteDateFormats[mask[i]]
+		if !ok {
+			return nil
+		}
+		buf.WriteString(f)
+	}
+	return NewStrVal([]byte(buf.String()))
+}
+
+// date_add(d, interval n unit) / adddate(d, n)
+func convertSQLiteDateAdd(args []Expr) Expr {
+	return convertSQLiteDateArith(args, false)
+}
+
+// date_sub(d, interval n unit) / subdate(d, n)
+func convertSQLiteDateSub(args []Expr) Expr {
+	return convertSQLiteDateArith(args, true)
+}
+
+func convertSQLiteDateArith(args []Expr, sub bool) Expr {
+	if len(args) != 2 {
+		return nil
+	}
+	interval, ok := args[1].(*IntervalExpr)
+	if !ok {
+		// adddate(d, n)中的n表示天数
+		interval = &IntervalExpr{Expr: args[1], Unit: "day"}
+	}
+	return convertSQLiteIntervalArith(args[0], interval, sub)
+}
+
+// convertSQLiteIntervalArith d + interval n unit -> datetime(d, (n) || ' unit')，
+// 周和季度换算为天和月，结果统一为'YYYY-MM-DD HH:MM:SS'格式
+func convertSQLiteIntervalArith(date Expr, interval *IntervalExpr, sub bool) Expr {
+	n := "(%v)"
+	if sub {
+		n = "-(%v)"
+	}
+	var modifier string
+	switch unit := strings.ToLower(interval.Unit); unit {
+	case "second", "minute", "hour", "day", "month", "year":
+		modifier = n + " || ' " + unit + "'"
+	case "week":
+		modifier = "(" + n + " * 7) || ' day'"
+	case "quarter":
+		modifier = "(" + n + " * 3) || ' month'"
+	case "microsecond":
+		modifier = "(" + n + " / 1000000.0) || ' second'"
+	default:
+		return nil
+	}
+	return newTemplateExpr("datetime(%v, "+modifier+")", date, interval.Expr)
+}
+
+// concat(a, b, c) -> (a || b || c)，与MySQL一样有一个参数为null时结果为null
+func convertSQLiteConcat(args []Expr) Expr {
+	if len(args) == 0 {
+		return nil
+	}
+	template := strings.TrimSuffix(strings.Repeat("%v || ", len(args)), " || ")
+	return newTemplateExpr("("+template+")", args...)
+}
+
+// rand() -> random()，只用于order by rand()的随机排序
+func convertSQLiteRand(args []Expr) Expr {
+	if len(args) != 0 {
+		return nil
+	}
+	return newFuncExpr("random")
+}
+
+// last_insert_id() -> last_insert_rowid()
+func convertSQLiteLastInsertId(args []Expr) Expr {
+	if len(args) != 0 {
+		return nil
+	}
+	return newFuncExpr("last_insert_rowid")
+}
+
+// version() -> sqlite_version()
+func convertSQLiteVersion(args []Expr) Expr {
+	if len(args) != 0 {
+		return nil
+	}
+	return newFuncExpr("sqlite_version")
+}
+
+// group_concat(expr separator sep) -> group_concat(expr, sep)，
+// SQLite的group_concat不支持order by，distinct时不能指定分隔符
+func convertSQLiteGroupConcat(node *GroupConcatExpr) Expr {
+	if len(node.Exprs) != 1 || len(node.OrderBy) > 0 {
+		return nil
+	}
+	aliased, ok := node.Exprs[0].(*AliasedExpr)
+	if !ok {
+		return nil
+	}
+	separator := getGroupConcatSeparator(node.Separator)
+	if node.Distinct != "" {
+		if separator != "," {
+			return nil
+		}
+		return newTemplateExpr("group_concat(distinct %v)", aliased.Expr)
+	}
+	return newTemplateExpr("group_concat(%v, %v)", aliased.Expr, NewStrVal([]byte(separator)))
+}
@@ -0,0 +1,145 @@
+package sqlparser
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestConvertToSQLite(t *testing.T) {
+	testCases := []struct {
+		in, out       string
+		args, outArgs []interface{}
+	}{
+		{
+			in:      "select `a`, b from t1 force index (idx_a) where c = ? and d = 'it\\'s' limit ?, ?",
+			out:     "select `a`, `b` from `t1` where `c` = ?1 and `d` = 'it''s' limit ?2, ?3",
+			args:    []interface{}{1, 2, 3},
+			outArgs: []interface{}{1, 2, 3},
+		},
+		{
+			in:  "select sql_no_cache 1 from dual",
+			out: "select 1",
+		},
+		{
+			in:  "select a from t1 where id = 1 for update",
+			out: "select `a` from `t1` where `id` = 1",
+		},
+		{
+			in:  "replace into t1 (id, a) values (1, 'x')",
+			out: "replace into `t1`(`id`, `a`) values (1, 'x')",
+		},
+		{
+			in:      "insert into t1 (id, a) values (0, 'x'), (?, ?)",
+			out:     "insert into `t1`(`id`, `a`) values (null, 'x'), (nullif(?1, 0), ?2)",
+			args:    []interface{}{int64(0), "y"},
+			outArgs: []interface{}{int64(0), "y"},
+		},
+		{
+			in:  "insert into counter(id, cnt) values (1, 1) on duplicate key update cnt = cnt + values(cnt), updated_at = now()",
+			out: "insert into `counter`(`id`, `cnt`) values (1, 1) on conflict do update set `cnt` = `cnt` + `excluded`.`cnt`, `updated_at` = datetime('now', 'localtime')",
+		},
+		{
+			in:  "insert into counter(id, cnt) select id, cnt from t2 on duplicate key update counter.cnt = values(cnt)",
+			out: "insert into `counter`(`id`, `cnt`) select `id`, `cnt` from `t2` where 1 on conflict do update set `cnt` = `excluded`.`cnt`",
+		},
+		{
+			in:  "insert ignore into t1(id, a) values (1, 'x')",
+			out: "insert or ignore into `t1`(`id`, `a`) values (1, 'x')",
+		},
+	}
+
+	converter := NewSQLiteConverter(nil, nil, map[string]map[string]int{"t1": {"id": 0}})
+	for i, tcase := range testCases {
+		t.Run(fmt.Sprintf("testcase-%d", i+1), func(t *testing.T) {
+			sSql, newArgs, err := converter.Convert(tcase.in, tcase.args...)
+			assert.Nil(t, err)
+			assert.Equal(t, tcase.out, sSql)
+			assert.Equal(t, tcase.outArgs, newArgs)
+		})
+	}
+}
+
+func TestConvertSQLiteFuncs(t *testing.T) {
+	testCases := []struct {
+		in, out string
+		args    []interface{}
+	}{
+		{
+			in:  "select ifnull(a, 0), if(a > 1, 'x', 'y'), now(), curdate(), rand(), last_insert_id(), version() from t1",
+			out: "select ifnull(`a`, 0), case when `a` > 1 then 'x' else 'y' end, datetime('now', 'localtime'), date('now', 'localtime'), random(), last_insert_rowid(), sqlite_version() from `t1`",
+		},
+		{
+			in:  "select unix_timestamp(), from_unixtime(ts), from_unixtime(ts, '%Y-%m-%d'), date_format(d, '%Y-%m-%d %H:%i:%s') from t1",
+			out: "select cast(strftime('%s', 'now') as integer), datetime(`ts`, 'unixepoch', 'localtime'), strftime('%Y-%m-%d', `ts`, 'unixepoch', 'localtime'), strftime('%Y-%m-%d %H:%M:%S', `d`) from `t1`",
+		},
+		{
+			in:   "select * from t1 where d > date_sub(now(), interval ? day) and e < adddate(d, 2) and f > d - interval 1 quarter",
+			out:  "select * from `t1` where `d` > datetime(datetime('now', 'localtime'), -(?1) || ' day') and `e` < datetime(`d`, (2) || ' day') and `f` > datetime(`d`, (-(1) * 3) || ' month')",
+			args: []interface{}{7},
+		},
+		{
+			in:  "select b, concat(a, '-', c), group_concat(a separator ';'), group_concat(distinct a) from t1 group by b",
+			out: "select `b`, (`a` || '-' || `c`), group_concat(`a`, ';'), group_concat(distinct `a`) from `t1` group by `b`",
+		},
+	}
+
+	converter := NewSQLiteConverter(nil, nil, nil)
+	for i, tcase := range testCases {
+		t.Run(fmt.Sprintf("testcase-%d", i+1), func(t *testing.T) {
+			sSql, newArgs, err := converter.Convert(tcase.in, tcase.args...)
+			assert.Nil(t, err)
+			assert.Equal(t, tcase.out, sSql)
+			assert.Equal(t, tcase.args, newArgs)
+		})
+	}
+}
+
+func TestConvertSQLiteDDL(t *testing.T) {
+	testCases := []struct {
+		in  string
+		out []string
+	}{
+		{
+			in: "CREATE TABLE IF NOT EXISTS t1 (id bigint(20) unsigned NOT NULL AUTO_INCREMENT, name varchar(64) NOT NULL DEFAULT '' COMMENT 'n', created_at datetime DEFAULT CURRENT_TIMESTAMP, amount decimal(10,2), PRIMARY KEY (id), UNIQUE KEY uk_name (name), KEY idx_created (created_at)) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4",
+			out: []string{
+				"create table if not exists `t1` (`id` integer primary key autoincrement not null, `name` text not null default '', `created_at` text default (datetime('now', 'localtime')), `amount` numeric)",
+				"create unique index if not exists `t1_uk_name` on `t1` (`name`)",
+				"create index if not exists `t1_idx_created` on `t1` (`created_at`)",
+			},
+		},
+		{
+			in:  "create table t2 (a bigint unsigned not null, b varchar(10), primary key (a, b))",
+			out: []string{"create table `t2` (`a` bigint not null, `b` text, primary key (`a`, `b`))"},
+		},
+		{
+			in:  "alter table t1 add column c int default 0",
+			out: []string{"alter table `t1` add column `c` int default 0"},
+		},
+		{
+			in:  "alter table t1 add index idx_c (c)",
+			out: []string{"create index `t1_idx_c` on `t1` (`c`)"},
+		},
+		{
+			in:  "drop index uk_c on t1",
+			out: []string{"drop index `t1_uk_c`"},
+		},
+		{
+			in:  "truncate table t1",
+			out: []string{"delete from `t1`"},
+		},
+	}
+
+	converter := NewSQLiteConverter(nil, nil, nil)
+	for i, tcase := range testCases {
+		t.Run(fmt.Sprintf("testcase-%d", i+1), func(t *testing.T) {
+			stmts, err := converter.ConvertDDL(tcase.in)
+			assert.Nil(t, err)
+			assert.Equal(t, tcase.out, stmts)
+		})
+	}
+
+	_, err := converter.ConvertDDL("alter table t1 modify column c bigint")
+	assert.NotNil(t, err)
+}
@@ -810,6 +810,10 @@ func (tkn *Tokenizer) scanString(delim uint16, typ int) (int, []byte) {
 				// String terminates mid escape character.
 				return LEX_ERROR, buffer.Bytes()
 			}
+			// 与MySQL一致，\%和\_保留反斜杠，用于like中转义通配符
+			if tkn.lastChar == '%' || tkn.lastChar == '_' {
+				buffer.WriteByte('\\')
+			}
 			if decodedChar := sqltypes.SQLDecodeMap[byte(tkn.lastChar)]; decodedChar == sqltypes.DontEscape {
 				ch = tkn.lastChar
 			} else {
@@ -21,10 +21,12 @@ import (
 	"strconv"
 	"strings"
 
+	"sqlproxy/backend"
 	ksError "sqlproxy/core/errors"
 	"sqlproxy/core/golog"
 
 	"github.com/labstack/echo"
+	"gopkg.in/yaml.v2"
 )
 
 func (s *ApiServer) GetAllowIps(c echo.Context) error {
@@ -212,6 +214,49 @@ type DBStatus struct {
 // 	return c.JSON(http.StatusOK, "ok")
 // }
 
+// reload the table metadata of one node
+func (s *ApiServer) ReloadNodeMetadata(c echo.Context) error {
+	args := struct {
+		Node string `json:"node"`
+	}{}
+	err := c.Bind(&args)
+	if err != nil {
+		return err
+	}
+	err = s.proxy.ReloadNodeMetadata(strings.TrimSpace(args.Node))
+	if err != nil {
+		if err == ksError.ErrNodeNotExist {
+			errMsg := fmt.Sprintf("node `%s` isn't exist", args.Node)
+			return c.JSON(http.StatusNotFound, errMsg)
+		}
+		return err
+	}
+	return c.JSON(http.StatusOK, "ok")
+}
+
+// dump the table metadata of one node as yaml, used by `sqlproxy convert -schema`
+func (s *ApiServer) DumpNodeMetadata(c echo.Context) error {
+	node := strings.TrimSpace(c.QueryParam("node"))
+	dump, err := s.proxy.DumpNodeMetadata(node)
+	if err != nil {
+		if err == ksError.ErrNodeNotExist {
+			errMsg := fmt.Sprintf("node `%s` isn't exist", node)
+			return c.JSON(http.StatusNotFound, errMsg)
+		}
+		return err
+	}
+	data, err := yaml.Marshal(dump)
+	if err != nil {
+		return err
+	}
+	return c.Blob(http.StatusOK, "application/x-yaml", data)
+}
+
+// the hit/miss statistics of the conversion cache of each node
+func (s *ApiServer) GetConvertCacheStats(c echo.Context) error {
+	return c.JSON(http.StatusOK, s.proxy.GetConvertCacheStats())
+}
+
 func (s *ApiServer) GetProxyStatus(c echo.Context) error {
 	status := s.proxy.Status()
 	return c.JSON(http.StatusOK, status)
@@ -321,6 +366,117 @@ func (s *ApiServer) DelOneBlackSQL(c echo.Context) error {
 	return c.JSON(http.StatusOK, "ok")
 }
 
+func (s *ApiServer) GetSQLRewrites(c echo.Context) error {
+	rewrites := s.proxy.GetSQLRewrites()
+	return c.JSON(http.StatusOK, rewrites)
+}
+
+func (s *ApiServer) AddSQLRewrite(c echo.Context) error {
+	args := new(backend.SQLRewrite)
+	err := c.Bind(args)
+	if err != nil {
+		return err
+	}
+	err = s.proxy.AddSQLRewrite(args)
+	if err != nil {
+		if err == ksError.ErrNodeNotExist {
+			errMsg := fmt.Sprintf("node `%s` isn't exist", args.Node)
+			return c.JSON(http.StatusNotFound, errMsg)
+		}
+		return err
+	}
+	return c.JSON(http.StatusOK, "ok")
+}
+
+func (s *ApiServer) DelSQLRewrite(c echo.Context) error {
+	args := struct {
+		Node string `json:"node"`
+		SQL  string `json:"sql"`
+	}{}
+
+	err := c.Bind(&args)
+	if err != nil {
+		return err
+	}
+	err = s.proxy.DelSQLRewrite(strings.TrimSpace(args.Node), args.SQL)
+	if err != nil {
+		if err == ksError.ErrRewriteNotExist {
+			errMsg := fmt.Sprintf("`%s` isn't exist in sql rewrites", args.SQL)
+			return c.JSON(http.StatusNotFound, errMsg)
+		}
+		return err
+	}
+	return c.JSON(http.StatusOK, "ok")
+}
+
+func (s *ApiServer) GetFirewallMode(c echo.Context) error {
+	return c.JSON(http.StatusOK, s.proxy.GetFirewallMode())
+}
+
+func (s *ApiServer) ChangeFirewallMode(c echo.Context) error {
+	args := struct {
+		Mode string `json:"mode"`
+	}{}
+
+	err := c.Bind(&args)
+	if err != nil {
+		return err
+	}
+	err = s.proxy.ChangeFirewallMode(args.Mode)
+	if err != nil {
+		if err == ksError.ErrFirewallMode {
+			return c.JSON(http.StatusBadRequest, err.Error())
+		}
+		return err
+	}
+	return c.JSON(http.StatusOK, "ok")
+}
+
+// GetFirewallRules 参数user只看指定用户的，status=pending只看待审核的
+func (s *ApiServer) GetFirewallRules(c echo.Context) error {
+	rules := s.proxy.GetFirewallRules(c.QueryParam("user"), c.QueryParam("status") == "pending")
+	return c.JSON(http.StatusOK, rules)
+}
+
+type firewallRulesArgs struct {
+	User string   `json:"user"`
+	Md5s []string `json:"md5s"`
+}
+
+func (s *ApiServer) ApproveFirewallRules(c echo.Context) error {
+	args := new(firewallRulesArgs)
+	err := c.Bind(args)
+	if err != nil {
+		return err
+	}
+	err = s.proxy.ApproveFirewallRules(args.User, args.Md5s)
+	if err != nil {
+		if err == ksError.ErrFirewallNotExist {
+			errMsg := fmt.Sprintf("fingerprints of user `%s` isn't exist in firewall", args.User)
+			return c.JSON(http.StatusNotFound, errMsg)
+		}
+		return err
+	}
+	return c.JSON(http.StatusOK, "ok")
+}
+
+func (s *ApiServer) DelFirewallRules(c echo.Context) error {
+	args := new(firewallRulesArgs)
+	err := c.Bind(args)
+	if err != nil {
+		return err
+	}
+	err = s.proxy.DelFirewallRules(args.User, args.Md5s)
+	if err != nil {
+		if err == ksError.ErrFirewallNotExist {
+			errMsg := fmt.Sprintf("fingerprints of user `%s` isn't exist in firewall", args.User)
+			return c.JSON(http.StatusNotFound, errMsg)
+		}
+		return err
+	}
+	return c.JSON(http.StatusOK, "ok")
+}
+
 func (s *ApiServer) SwitchSlowSQL(c echo.Context) error {
 	args := struct {
 		Opt string `json:"opt"`
@@ -89,6 +89,10 @@ func (s *ApiServer) RegisterURL() {
 
 	// s.web.PUT("/api/v1/nodes/masters/status", s.ChangeMasterStatus)
 
+	s.web.PUT("/api/v1/nodes/metadata", s.ReloadNodeMetadata)
+	s.web.GET("/api/v1/nodes/metadata", s.DumpNodeMetadata)
+	s.web.GET("/api/v1/nodes/convert_cache", s.GetConvertCacheStats)
+
 	s.web.GET("/api/v1/proxy/status", s.GetProxyStatus)
 	s.web.PUT("/api/v1/proxy/status", s.ChangeProxyStatus)
 
@@ -102,6 +106,16 @@ func (s *ApiServer) RegisterURL() {
 	s.web.POST("/api/v1/proxy/black_sqls", s.AddOneBlackSQL)
 	s.web.DELETE("/api/v1/proxy/black_sqls", s.DelOneBlackSQL)
 
+	s.web.GET("/api/v1/proxy/rewrites", s.GetSQLRewrites)
+	s.web.POST("/api/v1/proxy/rewrites", s.AddSQLRewrite)
+	s.web.DELETE("/api/v1/proxy/rewrites", s.DelSQLRewrite)
+
+	s.web.GET("/api/v1/proxy/firewall/mode", s.GetFirewallMode)
+	s.web.PUT("/api/v1/proxy/firewall/mode", s.ChangeFirewallMode)
+	s.web.GET("/api/v1/proxy/firewall", s.GetFirewallRules)
+	s.web.PUT("/api/v1/proxy/firewall", s.ApproveFirewallRules)
+	s.web.DELETE("/api/v1/proxy/firewall", s.DelFirewallRules)
+
 	s.web.GET("/api/v1/proxy/slow_sql/time", s.GetSlowLogTime)
 	s.web.PUT("/api/v1/proxy/slow_sql/status", s.SwitchSlowSQL)
 	s.web.PUT("/api/v1/proxy/slow_sql/time", s.SetSlowLogTime)